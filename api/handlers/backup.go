@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meghashyamc/wheresthat/logger"
+	"github.com/meghashyamc/wheresthat/services/backup"
+	"github.com/meghashyamc/wheresthat/services/index"
+)
+
+// SetupBackup adds the backup endpoint. Backups pause indexer, which indexes into the default collection, along
+// with indexing into all other collections.
+func SetupBackup(router *gin.Engine, logger logger.Logger, service *backup.Service, indexer *index.Service) {
+	service.Start(indexer)
+	router.POST("/admin/backup", handleBackup(service, logger))
+}
+
+// handleBackup writes a backup archive to the backups path, responding once it is written
+func handleBackup(service *backup.Service, logger logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		written, err := service.Backup(c.Request.Context())
+		if errors.Is(err, backup.ErrBackupInProgress) {
+			c.Abort()
+			writeResponse(c, nil, http.StatusConflict, []string{err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("failed to back up", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusInternalServerError, []string{err.Error()})
+			return
+		}
+
+		writeResponse(c, written, http.StatusCreated, nil)
+	}
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/meghashyamc/wheresthat/config"
+	"github.com/meghashyamc/wheresthat/db/kvdb"
+	"github.com/meghashyamc/wheresthat/db/searchdb"
+	"github.com/meghashyamc/wheresthat/services/backup"
+	"github.com/meghashyamc/wheresthat/services/collection"
+	"github.com/stretchr/testify/require"
+)
+
+const testFileSystemRootBackup = "./.wheresthat_backup_test"
+
+func TestHandleBackup(t *testing.T) {
+	assert := require.New(t)
+	server, cleanup := setupTestServer(assert, "indextest", testFileSystemRootBackup)
+	defer cleanup()
+
+	w := makeTestHTTPRequest(server, assert, http.MethodPost, "/collections", defaultTestRequestHeaders, map[string]any{"name": "notes"}, nil)
+	assert.Equal(http.StatusCreated, w.Code, fmt.Sprintf("response gotten was %s", w.Body.String()))
+
+	indexRequests := map[string]string{
+		"/index":                   testFileSystemRootBackup,
+		"/collections/notes/index": testFileSystemRootBackup + "/designs",
+	}
+	for endpoint, path := range indexRequests {
+		w := makeTestHTTPRequest(server, assert, http.MethodPost, endpoint, defaultTestRequestHeaders, map[string]any{"path": mustGetAbsolutePath(path)}, nil)
+		assert.Equal(http.StatusAccepted, w.Code, fmt.Sprintf("response gotten was %s", w.Body.String()))
+		assertSuccessfulIndexCreation(assert, server, w.Body.Bytes())
+	}
+
+	w = makeTestHTTPRequest(server, assert, http.MethodPost, "/admin/backup", nil, nil, nil)
+	assert.Equal(http.StatusCreated, w.Code, fmt.Sprintf("response gotten was %s", w.Body.String()))
+	written := decodeResponseData[backup.Backup](assert, w.Body.Bytes())
+
+	info, err := os.Stat(written.Path)
+	assert.NoError(err, "backup archive should be written")
+	assert.Equal(info.Size(), written.Size)
+	assert.Equal(searchdb.CurrentSchemaVersion(), written.Manifest.SchemaVersion)
+	assert.Equal([]string{"notes"}, written.Manifest.Collections)
+	archivedFiles := map[string]bool{}
+	for _, file := range written.Manifest.Files {
+		archivedFiles[file.Path] = true
+	}
+	for _, path := range []string{"metadata.db", "search.index/store/root.bolt", "search.index.vectors", "collections/notes/search.index/store/root.bolt"} {
+		assert.True(archivedFiles[path], "backup should have "+path)
+	}
+
+	w = makeTestHTTPRequest(server, assert, http.MethodPost, "/index", defaultTestRequestHeaders, map[string]any{"path": mustGetAbsolutePath(testFileSystemRootBackup)}, nil)
+	assert.Equal(http.StatusAccepted, w.Code, "indexing should be resumed once the backup is written")
+	assertSuccessfulIndexCreation(assert, server, w.Body.Bytes())
+
+	t.Run("Restore", func(t *testing.T) {
+		assert := require.New(t)
+		t.Setenv("STORAGE_PATH", t.TempDir())
+		cfg, err := config.Load("indextest")
+		assert.NoError(err)
+
+		manifest, err := backup.Restore(newTestLogger(), backup.PathsFromConfig(cfg), written.Path)
+		assert.NoError(err)
+		assert.Equal(written.Manifest.CreatedAt, manifest.CreatedAt)
+
+		searchDB, err := searchdb.New(newTestLogger(), cfg)
+		assert.NoError(err)
+		defer searchDB.Close()
+		kvDB, err := kvdb.New(newTestLogger(), cfg)
+		assert.NoError(err)
+		defer kvDB.Close()
+
+		docCount, err := searchDB.GetDocCount()
+		assert.NoError(err)
+		assert.Equal(len(testFiles), int(docCount), "restored index should have all indexed files")
+		indexedFiles, err := kvDB.GetAllKeys(kvdb.FilesBucket)
+		assert.NoError(err)
+		assert.Len(indexedFiles, len(testFiles), "restored metadata should have all indexed files")
+
+		collections, err := collection.New(newTestLogger(), searchDB, kvDB)
+		assert.NoError(err)
+		defer collections.Close()
+		response, err := collections.Search(searchdb.SearchParams{Query: "cache", Collections: []string{"notes"}, Limit: 10})
+		assert.NoError(err)
+		assert.Len(response.Results, 2, "restored collections should be searchable")
+	})
+
+	t.Run("RestoreTamperedArchive", func(t *testing.T) {
+		assert := require.New(t)
+		t.Setenv("STORAGE_PATH", t.TempDir())
+		cfg, err := config.Load("indextest")
+		assert.NoError(err)
+
+		tamperedPath := filepath.Join(t.TempDir(), "tampered.tar.gz")
+		tamperBackupArchive(assert, written.Path, tamperedPath)
+
+		_, err = backup.Restore(newTestLogger(), backup.PathsFromConfig(cfg), tamperedPath)
+		assert.ErrorIs(err, backup.ErrInvalidBackup)
+		_, err = os.Stat(backup.PathsFromConfig(cfg).KVDB)
+		assert.ErrorIs(err, os.ErrNotExist, "nothing should be restored from an invalid archive")
+	})
+}
+
+// tamperBackupArchive copies a backup archive, changing its metadata without updating the manifest
+func tamperBackupArchive(assert *require.Assertions, archivePath string, tamperedPath string) {
+	archive, err := os.Open(archivePath)
+	assert.NoError(err)
+	defer archive.Close()
+	gzipReader, err := gzip.NewReader(archive)
+	assert.NoError(err)
+
+	tampered, err := os.Create(tamperedPath)
+	assert.NoError(err)
+	defer tampered.Close()
+	gzipWriter := gzip.NewWriter(tampered)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(err)
+		content, err := io.ReadAll(tarReader)
+		assert.NoError(err)
+		if header.Name == "metadata.db" {
+			content[len(content)-1] ^= 0xff
+		}
+		assert.NoError(tarWriter.WriteHeader(header))
+		_, err = tarWriter.Write(content)
+		assert.NoError(err)
+	}
+
+	assert.NoError(tarWriter.Close())
+	assert.NoError(gzipWriter.Close())
+}
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/meghashyamc/wheresthat/db/searchdb"
+	"github.com/meghashyamc/wheresthat/logger"
+	"github.com/meghashyamc/wheresthat/services/collection"
+	"github.com/meghashyamc/wheresthat/services/index"
+	"github.com/meghashyamc/wheresthat/validation"
+)
+
+type CreateCollectionRequest struct {
+	Name string `json:"name" validate:"required,alphanum,max=64"`
+	// Roots are the directories that may be indexed into the collection, or empty if any directory may be
+	Roots []string `json:"roots" validate:"max=100,valid_paths"`
+	// Analyzer is "standard" (the default) or "code", which is used for the names and content of text files
+	Analyzer string `json:"analyzer" validate:"omitempty,oneof=standard code"`
+	// RankingProfile is the name of a ranking profile from the config, used when a search does not name one
+	RankingProfile string `json:"ranking_profile" validate:"max=100"`
+}
+
+type UpdateCollectionRequest struct {
+	Roots          []string `json:"roots" validate:"max=100,valid_paths"`
+	RankingProfile string   `json:"ranking_profile" validate:"max=100"`
+}
+
+type CollectionRequest struct {
+	Name string `uri:"name" validate:"required,alphanum,max=64"`
+}
+
+type CollectionsResponse struct {
+	Collections []collection.Collection `json:"collections"`
+}
+
+// SetupCollections adds the collection endpoints. Index requests of collections are told to listeners, and
+// their status is looked up like that of any other index request.
+func SetupCollections(ctx context.Context, router *gin.Engine, logger logger.Logger, service *collection.Service, validator *validation.Validator, listeners ...index.Listener) {
+	service.Start(ctx, listeners...)
+	router.GET("/collections", handleListCollections(service))
+	router.POST("/collections", handleCreateCollection(service, logger, validator))
+	router.GET("/collections/:name", handleGetCollection(service, logger, validator))
+	router.PUT("/collections/:name", handleUpdateCollection(service, logger, validator))
+	router.DELETE("/collections/:name", handleDeleteCollection(service, logger, validator))
+	router.POST("/collections/:name/index", handleIndexCollection(service, logger, validator))
+}
+
+func handleListCollections(service *collection.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		writeResponse(c, CollectionsResponse{Collections: service.List()}, http.StatusOK, nil)
+	}
+}
+
+func handleCreateCollection(service *collection.Service, logger logger.Logger, validator *validation.Validator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request := CreateCollectionRequest{}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			logger.Warn("could not extract expected params from collection request", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusUnprocessableEntity, []string{"failed to extract request body parameters"})
+			return
+		}
+
+		if err := validator.Validate(request); err != nil {
+			logger.Warn("could not validate collection request", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotAcceptable, []string{err.Error()})
+			return
+		}
+
+		created, err := service.Create(collection.Collection{
+			Name:           request.Name,
+			Roots:          request.Roots,
+			Analyzer:       request.Analyzer,
+			RankingProfile: request.RankingProfile,
+		})
+		if errors.Is(err, collection.ErrAlreadyExists) {
+			c.Abort()
+			writeResponse(c, nil, http.StatusConflict, []string{err.Error()})
+			return
+		}
+		if errors.Is(err, searchdb.ErrUnknownRankingProfile) {
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotAcceptable, []string{err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("failed to create collection", "name", request.Name, "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusInternalServerError, []string{err.Error()})
+			return
+		}
+
+		writeResponse(c, created, http.StatusCreated, nil)
+	}
+}
+
+func handleGetCollection(service *collection.Service, logger logger.Logger, validator *validation.Validator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request, ok := bindCollectionRequest(c, logger, validator)
+		if !ok {
+			return
+		}
+
+		found, err := service.Get(request.Name)
+		if err != nil {
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotFound, []string{err.Error()})
+			return
+		}
+
+		writeResponse(c, found, http.StatusOK, nil)
+	}
+}
+
+func handleUpdateCollection(service *collection.Service, logger logger.Logger, validator *validation.Validator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request, ok := bindCollectionRequest(c, logger, validator)
+		if !ok {
+			return
+		}
+
+		updateRequest := UpdateCollectionRequest{}
+		if err := c.ShouldBindJSON(&updateRequest); err != nil {
+			logger.Warn("could not extract expected params from collection request", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusUnprocessableEntity, []string{"failed to extract request body parameters"})
+			return
+		}
+
+		if err := validator.Validate(updateRequest); err != nil {
+			logger.Warn("could not validate collection request", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotAcceptable, []string{err.Error()})
+			return
+		}
+
+		updated, err := service.Update(request.Name, updateRequest.Roots, updateRequest.RankingProfile)
+		if errors.Is(err, collection.ErrNotFound) {
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotFound, []string{err.Error()})
+			return
+		}
+		if errors.Is(err, searchdb.ErrUnknownRankingProfile) {
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotAcceptable, []string{err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("failed to update collection", "name", request.Name, "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusInternalServerError, []string{err.Error()})
+			return
+		}
+
+		writeResponse(c, updated, http.StatusOK, nil)
+	}
+}
+
+func handleDeleteCollection(service *collection.Service, logger logger.Logger, validator *validation.Validator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request, ok := bindCollectionRequest(c, logger, validator)
+		if !ok {
+			return
+		}
+
+		err := service.Delete(request.Name)
+		if errors.Is(err, collection.ErrNotFound) {
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotFound, []string{err.Error()})
+			return
+		}
+		if errors.Is(err, collection.ErrIndexingInProgress) {
+			c.Abort()
+			writeResponse(c, nil, http.StatusConflict, []string{err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("failed to delete collection", "name", request.Name, "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusInternalServerError, []string{err.Error()})
+			return
+		}
+
+		writeResponse(c, nil, http.StatusNoContent, nil)
+	}
+}
+
+// handleIndexCollection starts indexing a directory into a collection. Its status is found at
+// /index/:request_id, like that of requests to index into the default collection.
+func handleIndexCollection(service *collection.Service, logger logger.Logger, validator *validation.Validator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request, ok := bindCollectionRequest(c, logger, validator)
+		if !ok {
+			return
+		}
+
+		indexRequest := IndexRequest{}
+		if err := c.ShouldBindJSON(&indexRequest); err != nil {
+			logger.Warn("could not extract expected params from collection index request", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusUnprocessableEntity, []string{"failed to extract request body parameters"})
+			return
+		}
+		if err := validator.Validate(indexRequest); err != nil {
+			logger.Warn("could not validate collection index request", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotAcceptable, []string{err.Error()})
+			return
+		}
+
+		if err := semanticallyValidateExcludePaths(logger, indexRequest); err != nil {
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotAcceptable, []string{err.Error()})
+			return
+		}
+
+		requestID := uuid.New().String()
+
+		err := service.Index(request.Name, indexRequest.Path, indexRequest.ExcludeFolders, requestID)
+		if errors.Is(err, collection.ErrNotFound) {
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotFound, []string{err.Error()})
+			return
+		}
+		if errors.Is(err, collection.ErrPathNotInRoots) {
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotAcceptable, []string{err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("failed to index collection", "name", request.Name, "err", err.Error())
+			writeResponse(c, nil, http.StatusConflict, []string{"failed to start indexing, possibly because another indexing operation is in progress"})
+			return
+		}
+
+		writeResponse(c, IndexResponse{ID: requestID}, http.StatusAccepted, nil)
+	}
+}
+
+// bindCollectionRequest extracts the name of a collection from the URL, writing an error response if it is
+// missing or invalid
+func bindCollectionRequest(c *gin.Context, logger logger.Logger, validator *validation.Validator) (CollectionRequest, bool) {
+	request := CollectionRequest{}
+	if err := c.ShouldBindUri(&request); err != nil {
+		logger.Warn("could not extract expected params from collection request", "err", err.Error())
+		c.Abort()
+		writeResponse(c, nil, http.StatusUnprocessableEntity, []string{"failed to extract URL parameters"})
+		return request, false
+	}
+
+	if err := validator.Validate(request); err != nil {
+		logger.Warn("could not validate collection request", "err", err.Error())
+		c.Abort()
+		writeResponse(c, nil, http.StatusNotAcceptable, []string{err.Error()})
+		return request, false
+	}
+
+	return request, true
+}
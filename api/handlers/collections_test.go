@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/meghashyamc/wheresthat/services/collection"
+	"github.com/stretchr/testify/require"
+)
+
+const testFileSystemRootCollections = "./.wheresthat_collections_test"
+
+var createCollectionHandlerTestCases = []testCase{
+	{
+		name:           "NoName",
+		requestHeaders: defaultTestRequestHeaders,
+		requestBody:    map[string]any{"analyzer": "standard"},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "InvalidName",
+		requestHeaders: defaultTestRequestHeaders,
+		requestBody:    map[string]any{"name": "../notes"},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "UnknownAnalyzer",
+		requestHeaders: defaultTestRequestHeaders,
+		requestBody:    map[string]any{"name": "notes", "analyzer": "french"},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "RelativeRoot",
+		requestHeaders: defaultTestRequestHeaders,
+		requestBody:    map[string]any{"name": "notes", "roots": []string{"designs"}},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "UnknownRankingProfile",
+		requestHeaders: defaultTestRequestHeaders,
+		requestBody:    map[string]any{"name": "notes", "ranking_profile": "nonexistent"},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "Success",
+		requestHeaders: defaultTestRequestHeaders,
+		requestBody:    map[string]any{"name": "notes", "roots": []string{mustGetAbsolutePath(testFileSystemRootCollections + "/designs")}, "ranking_profile": "shallow"},
+		expectedStatus: http.StatusCreated,
+	},
+	{
+		name:           "DuplicateName",
+		requestHeaders: defaultTestRequestHeaders,
+		requestBody:    map[string]any{"name": "notes"},
+		expectedStatus: http.StatusConflict,
+	},
+	{
+		name:           "DefaultCollectionName",
+		requestHeaders: defaultTestRequestHeaders,
+		requestBody:    map[string]any{"name": "default"},
+		expectedStatus: http.StatusConflict,
+	},
+	{
+		name:           "CodeAnalyzer",
+		requestHeaders: defaultTestRequestHeaders,
+		requestBody:    map[string]any{"name": "code", "analyzer": "code"},
+		expectedStatus: http.StatusCreated,
+	},
+}
+
+func TestHandleCollections(t *testing.T) {
+	assert := require.New(t)
+	server, cleanup := setupTestServer(assert, "indextest", testFileSystemRootCollections)
+	defer cleanup()
+
+	for _, testCase := range createCollectionHandlerTestCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert := require.New(t)
+			w := makeTestHTTPRequest(server, assert, http.MethodPost, "/collections", testCase.requestHeaders, testCase.requestBody, nil)
+			assert.Equal(testCase.expectedStatus, w.Code, fmt.Sprintf("response gotten was %s", w.Body.String()))
+		})
+	}
+
+	t.Run("ListCollections", func(t *testing.T) {
+		assert := require.New(t)
+		w := makeTestHTTPRequest(server, assert, http.MethodGet, "/collections", nil, nil, nil)
+		assert.Equal(http.StatusOK, w.Code)
+		collections := decodeResponseData[CollectionsResponse](assert, w.Body.Bytes()).Collections
+		assert.Len(collections, 2)
+		assert.Equal("code", collections[0].Name)
+		assert.Equal("code", collections[0].Analyzer)
+		assert.Equal("notes", collections[1].Name)
+		assert.Equal("standard", collections[1].Analyzer, "collections should be analyzed with the standard analyzer by default")
+		assert.Equal("shallow", collections[1].RankingProfile)
+	})
+
+	t.Run("IndexOutsideRoots", func(t *testing.T) {
+		assert := require.New(t)
+		requestBody := map[string]any{"path": mustGetAbsolutePath(testFileSystemRootCollections + "/billing")}
+		w := makeTestHTTPRequest(server, assert, http.MethodPost, "/collections/notes/index", defaultTestRequestHeaders, requestBody, nil)
+		assert.Equal(http.StatusNotAcceptable, w.Code, fmt.Sprintf("response gotten was %s", w.Body.String()))
+	})
+
+	t.Run("IndexUnknownCollection", func(t *testing.T) {
+		assert := require.New(t)
+		requestBody := map[string]any{"path": mustGetAbsolutePath(testFileSystemRootCollections)}
+		w := makeTestHTTPRequest(server, assert, http.MethodPost, "/collections/unknown/index", defaultTestRequestHeaders, requestBody, nil)
+		assert.Equal(http.StatusNotFound, w.Code)
+	})
+
+	// Every collection is indexed into its own index, while the default one is indexed as before
+	indexRequests := map[string]string{
+		"/index":                   testFileSystemRootCollections,
+		"/collections/notes/index": testFileSystemRootCollections + "/designs",
+		"/collections/code/index":  testFileSystemRootCollections + "/subdir",
+	}
+	for endpoint, path := range indexRequests {
+		w := makeTestHTTPRequest(server, assert, http.MethodPost, endpoint, defaultTestRequestHeaders, map[string]any{"path": mustGetAbsolutePath(path)}, nil)
+		assert.Equal(http.StatusAccepted, w.Code, fmt.Sprintf("response gotten was %s", w.Body.String()))
+		assertSuccessfulIndexCreation(assert, server, w.Body.Bytes())
+	}
+
+	t.Run("SearchCollection", func(t *testing.T) {
+		assert := require.New(t)
+		w := makeTestHTTPRequest(server, assert, http.MethodGet, "/search", nil, nil, map[string]string{"query": "cache", "collection": "notes"})
+		assert.Equal(http.StatusOK, w.Code, fmt.Sprintf("response gotten was %s", w.Body.String()))
+		results := decodeResponseData[SearchResponse](assert, w.Body.Bytes()).Results
+		assert.Len(results, 2)
+		for _, result := range results {
+			assert.Equal(mustGetAbsolutePath(testFileSystemRootCollections+"/designs"), filepath.Dir(result.Path))
+			assert.Empty(result.Collection, "results of a single collection should not name it")
+		}
+
+		w = makeTestHTTPRequest(server, assert, http.MethodGet, "/search", nil, nil, map[string]string{"query": "cache", "collection": "code"})
+		assert.Equal(http.StatusOK, w.Code)
+		assert.Empty(decodeResponseData[SearchResponse](assert, w.Body.Bytes()).Results, "collections should only hold what was indexed into them")
+	})
+
+	t.Run("SearchSeveralCollections", func(t *testing.T) {
+		assert := require.New(t)
+		w := makeTestHTTPRequest(server, assert, http.MethodGet, "/search", nil, nil, map[string]string{"query": "cache", "collection": "notes,default"})
+		assert.Equal(http.StatusOK, w.Code, fmt.Sprintf("response gotten was %s", w.Body.String()))
+		response := decodeResponseData[SearchResponse](assert, w.Body.Bytes())
+		assert.Equal(4, response.PageDetails.TotalResults)
+
+		resultsByCollection := map[string]int{}
+		for i, result := range response.Results {
+			resultsByCollection[result.Collection]++
+			if i > 0 {
+				assert.GreaterOrEqual(response.Results[i-1].Score, result.Score, "results should be merged by score")
+			}
+		}
+		assert.Equal(map[string]int{"default": 2, "notes": 2}, resultsByCollection)
+	})
+
+	t.Run("SearchSeveralCollectionsInSemanticMode", func(t *testing.T) {
+		assert := require.New(t)
+		w := makeTestHTTPRequest(server, assert, http.MethodGet, "/search", nil, nil, map[string]string{"query": "cache", "collection": "notes,code", "mode": "semantic"})
+		assert.Equal(http.StatusNotAcceptable, w.Code)
+	})
+
+	t.Run("SearchUnknownCollection", func(t *testing.T) {
+		assert := require.New(t)
+		w := makeTestHTTPRequest(server, assert, http.MethodGet, "/search", nil, nil, map[string]string{"query": "cache", "collection": "notes,unknown"})
+		assert.Equal(http.StatusNotFound, w.Code)
+	})
+
+	t.Run("UpdateCollection", func(t *testing.T) {
+		assert := require.New(t)
+		requestBody := map[string]any{"ranking_profile": "nonexistent"}
+		w := makeTestHTTPRequest(server, assert, http.MethodPut, "/collections/notes", defaultTestRequestHeaders, requestBody, nil)
+		assert.Equal(http.StatusNotAcceptable, w.Code)
+
+		requestBody = map[string]any{"roots": []string{mustGetAbsolutePath(testFileSystemRootCollections)}}
+		w = makeTestHTTPRequest(server, assert, http.MethodPut, "/collections/notes", defaultTestRequestHeaders, requestBody, nil)
+		assert.Equal(http.StatusOK, w.Code, fmt.Sprintf("response gotten was %s", w.Body.String()))
+
+		w = makeTestHTTPRequest(server, assert, http.MethodGet, "/collections/notes", nil, nil, nil)
+		assert.Equal(http.StatusOK, w.Code)
+		updated := decodeResponseData[collection.Collection](assert, w.Body.Bytes())
+		assert.Equal([]string{mustGetAbsolutePath(testFileSystemRootCollections)}, updated.Roots)
+		assert.Empty(updated.RankingProfile)
+	})
+
+	t.Run("DeleteCollection", func(t *testing.T) {
+		assert := require.New(t)
+		w := makeTestHTTPRequest(server, assert, http.MethodDelete, "/collections/notes", nil, nil, nil)
+		assert.Equal(http.StatusNoContent, w.Code)
+
+		w = makeTestHTTPRequest(server, assert, http.MethodGet, "/collections/notes", nil, nil, nil)
+		assert.Equal(http.StatusNotFound, w.Code)
+
+		w = makeTestHTTPRequest(server, assert, http.MethodGet, "/search", nil, nil, map[string]string{"query": "cache", "collection": "notes"})
+		assert.Equal(http.StatusNotFound, w.Code, "deleted collections should not be searchable")
+
+		w = makeTestHTTPRequest(server, assert, http.MethodDelete, "/collections/notes", nil, nil, nil)
+		assert.Equal(http.StatusNotFound, w.Code, "deleting a collection twice should fail")
+	})
+}
@@ -16,7 +16,10 @@ import (
 	"github.com/meghashyamc/wheresthat/db/kvdb"
 	"github.com/meghashyamc/wheresthat/db/searchdb"
 	"github.com/meghashyamc/wheresthat/logger"
+	"github.com/meghashyamc/wheresthat/services/backup"
+	"github.com/meghashyamc/wheresthat/services/collection"
 	"github.com/meghashyamc/wheresthat/services/index"
+	"github.com/meghashyamc/wheresthat/services/stats"
 	"github.com/meghashyamc/wheresthat/validation"
 	"github.com/stretchr/testify/require"
 )
@@ -24,16 +27,20 @@ import (
 var defaultTestRequestHeaders = map[string]string{"Content-Type": "application/json"}
 
 var testFiles = map[string]string{
-	"file1.txt":              "This is test content for file1",
-	"file2.go":               "package main\n\nfunc main() {\n\tprint(\"Hello\")\n}",
-	"subdir/file3.md":        "# Test Markdown\n\nThis is a test markdown file",
-	"subdir/file4.json":      `{"key": "value", "number": 42}`,
-	"subdir/nested/file5.py": "def hello():\n    print('Hello World')",
+	"file1.txt":               "This is test content for file1",
+	"file2.go":                "package main\n\nfunc main() {\n\tprint(\"Hello\")\n}",
+	"subdir/file3.md":         "# Test Markdown\n\nThis is a test markdown file",
+	"subdir/file4.json":       `{"key": "value", "number": 42}`,
+	"subdir/nested/file5.py":  "def hello():\n    print('Hello World')",
+	"subdir/query_parser.js":  "function parseQuotedQuery(input) {}",
+	"designs/cache_design.md": "# Cache design\n\nEviction uses a least recently used policy and the cache warmup runs at startup.",
+	"designs/cache_notes.txt": "Notes on cache eviction and warmup timings.",
+	"billing/charges.md":      "Charges that fail are retried by the billing worker with exponential backoff, until the payment goes through.",
 }
 
 type testServer struct {
 	router  *gin.Engine
-	kvDB    index.MetadataStore
+	kvDB    kvdb.Store
 	indexer index.Indexer
 }
 
@@ -73,7 +80,7 @@ func setupTestServer(assert *require.Assertions, testEnv string, testFileSystemR
 	searchDB, err := searchdb.New(testLogger, cfg)
 	assert.NoError(err, "could not create search database")
 
-	kvDB, err := kvdb.New(testLogger, cfg)
+	kvDB, err := kvdb.Open(testLogger, cfg)
 	assert.NoError(err, "could not create kv database")
 	validator, err := validation.New(testLogger)
 	assert.NoError(err, "could not create validator")
@@ -82,11 +89,23 @@ func setupTestServer(assert *require.Assertions, testEnv string, testFileSystemR
 
 	ctx := context.Background()
 
-	SetupIndex(ctx, router, testLogger, searchDB, kvDB, validator)
-	SetupSearch(router, testLogger, searchDB, validator)
+	collections, err := collection.New(testLogger, searchDB, kvDB)
+	assert.NoError(err, "could not open collections")
+
+	savedSearches := SetupSavedSearches(router, testLogger, searchDB, kvDB, validator)
+	webhooks := SetupWebhooks(ctx, router, testLogger, kvDB, cfg, validator)
+	statsService := stats.New(testLogger, kvDB, collections)
+	indexService := SetupIndex(ctx, router, testLogger, searchDB, kvDB, cfg, validator, savedSearches, webhooks, statsService)
+	SetupCollections(ctx, router, testLogger, collections, validator, savedSearches, webhooks, statsService)
+	SetupBackup(router, testLogger, backup.New(testLogger, backup.PathsFromConfig(cfg), kvDB, searchDB, collections), indexService)
+	SetupStats(router, testLogger, statsService, validator)
+	SetupSearch(router, testLogger, collections.Searcher(), validator)
+	SetupSynonyms(router, testLogger, searchDB, validator)
 
 	cleanup := func() {
 		var err error
+		err = collections.Close()
+		assert.NoError(err, "could not close collections")
 		err = searchDB.Close()
 		assert.NoError(err, "could not close search database")
 		err = kvDB.Close()
@@ -142,6 +161,19 @@ func makeTestHTTPRequest(server *testServer, assert *require.Assertions, method
 	return w
 }
 
+// decodeResponseData returns the data of a response
+func decodeResponseData[T any](assert *require.Assertions, responseBytes []byte) T {
+	type dataResponse struct {
+		Data   T        `json:"data"`
+		Errors []string `json:"errors"`
+	}
+	actualResponse := dataResponse{}
+	err := json.Unmarshal(responseBytes, &actualResponse)
+	assert.NoError(err, "could not unmarshal gotten response")
+
+	return actualResponse.Data
+}
+
 func mustGetAbsolutePath(relativePath string) string {
 	absPath, err := filepath.Abs(relativePath)
 	if err != nil {
@@ -8,6 +8,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/meghashyamc/wheresthat/config"
 	"github.com/meghashyamc/wheresthat/logger"
 	"github.com/meghashyamc/wheresthat/services/index"
 	"github.com/meghashyamc/wheresthat/validation"
@@ -31,10 +32,26 @@ type IndexStatusResponse struct {
 	ID     string `json:"request_id"`
 }
 
-func SetupIndex(ctx context.Context, router *gin.Engine, logger logger.Logger, indexer index.Indexer, metadataStore index.MetadataStore, validator *validation.Validator) {
-	service := index.New(ctx, logger, indexer, metadataStore)
+type PurgeIndexRequestsResponse struct {
+	// Purged is the number of finished index requests whose statuses were removed
+	Purged int `json:"purged"`
+}
+
+// SetupIndex adds the index endpoints of the default collection, returning the service indexing into it.
+// Statuses of finished index requests into any collection are removed once they are past the retention in
+// the config, until ctx is done.
+func SetupIndex(ctx context.Context, router *gin.Engine, logger logger.Logger, indexer index.Indexer, metadataStore index.MetadataStore, cfg *config.Config, validator *validation.Validator, listeners ...index.Listener) *index.Service {
+	service := index.New(ctx, logger, indexer, metadataStore, listeners...)
+	service.StartJanitor(ctx, index.Retention{
+		MaxAge:   cfg.GetRequestsMaxAge(),
+		MaxCount: cfg.GetRequestsMaxCount(),
+		Interval: cfg.GetRequestsJanitorInterval(),
+	})
 	router.POST("/index", handleCreateIndex(service, logger, validator))
 	router.GET("/index/:request_id", handleGetIndexStatus(service, logger, validator))
+	router.DELETE("/index", handlePurgeIndexRequests(service, logger))
+
+	return service
 }
 
 func handleCreateIndex(indexService *index.Service, logger logger.Logger, validator *validation.Validator) gin.HandlerFunc {
@@ -121,6 +138,22 @@ func handleGetIndexStatus(indexService *index.Service, logger logger.Logger, val
 	}
 }
 
+// handlePurgeIndexRequests removes the statuses of all finished index requests, leaving those queued or in
+// progress
+func handlePurgeIndexRequests(indexService *index.Service, logger logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		purged, err := indexService.PurgeFinishedRequests()
+		if err != nil {
+			logger.Error("failed to purge index requests", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusInternalServerError, []string{err.Error()})
+			return
+		}
+
+		writeResponse(c, PurgeIndexRequestsResponse{Purged: purged}, http.StatusOK, nil)
+	}
+}
+
 func getResponseStatusFromServiceStatus(status int) int {
 	responseStatus := http.StatusAccepted
 	if status == index.ProgressStatusComplete {
@@ -1,14 +1,21 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/meghashyamc/wheresthat/db/kvdb"
 	"github.com/meghashyamc/wheresthat/db/searchdb"
+	"github.com/meghashyamc/wheresthat/services/index"
 	"github.com/stretchr/testify/require"
 )
 
@@ -110,3 +117,138 @@ func assertSuccessfulIndexCreation(assert *require.Assertions, server *testServe
 	}
 	assert.Fail("timed out waiting for index creation: ", requestID.String())
 }
+
+func TestIndexCheck(t *testing.T) {
+	assert := require.New(t)
+	server, cleanup := setupTestServer(assert, "indextest", testFileSystemRootIndex)
+	defer cleanup()
+
+	w := makeTestHTTPRequest(server, assert, http.MethodPost, "/index", defaultTestRequestHeaders, map[string]any{"path": mustGetAbsolutePath(testFileSystemRootIndex)}, nil)
+	assert.Equal(http.StatusAccepted, w.Code)
+	assertSuccessfulIndexCreation(assert, server, w.Body.Bytes())
+
+	ctx := context.Background()
+	service := index.NewChecker(newTestLogger(), searchdb.DefaultCollection, kvdb.FilesBucket, server.indexer, server.kvDB)
+
+	report, err := service.Check(ctx, false)
+	assert.NoError(err)
+	assert.True(report.Consistent(), "a freshly built index should be consistent")
+	assert.False(report.Outdated)
+	assert.Error(service.Build(mustGetAbsolutePath(testFileSystemRootIndex), nil, uuid.NewString()), "a checker should not accept index requests")
+	assert.Equal(len(testFiles), report.Documents)
+	assert.Equal(len(testFiles), report.Entries)
+
+	// Drift between the index, the metadata and the file system
+	orphan := mustGetAbsolutePath(filepath.Join(testFileSystemRootIndex, "file1.txt"))
+	missing := mustGetAbsolutePath(filepath.Join(testFileSystemRootIndex, "subdir/file3.md"))
+	stale := mustGetAbsolutePath(filepath.Join(testFileSystemRootIndex, "billing/charges.md"))
+	assert.NoError(server.kvDB.Delete(kvdb.FilesBucket, orphan))
+	assert.NoError(server.indexer.DeleteDocuments([]string{missing}))
+	assert.NoError(os.Remove(stale))
+
+	report, err = service.Check(ctx, false)
+	assert.NoError(err)
+	assert.Equal([]string{orphan}, report.Orphans)
+	assert.Equal([]string{missing}, report.Missing)
+	assert.Equal([]string{stale}, report.Stale)
+	assert.Empty(report.Reindexed, "nothing should be repaired without asking for it")
+
+	report, err = service.Check(ctx, true)
+	assert.NoError(err)
+	assert.ElementsMatch([]string{orphan, missing}, report.Reindexed)
+	assert.Equal([]string{stale}, report.Removed)
+	assert.Empty(report.Unrepaired)
+
+	report, err = service.Check(ctx, false)
+	assert.NoError(err)
+	assert.True(report.Consistent(), "a repaired index should be consistent")
+	assert.Equal(len(testFiles)-1, report.Documents)
+
+	w = makeTestHTTPRequest(server, assert, http.MethodGet, "/search", nil, nil, map[string]string{"query": "markdown"})
+	assert.Equal(http.StatusOK, w.Code)
+	assert.NotEmpty(decodeResponseData[SearchResponse](assert, w.Body.Bytes()).Results, "missing documents should be indexed again")
+}
+
+func TestMissingSchemaVersionRecorded(t *testing.T) {
+	assert := require.New(t)
+	server, cleanup := setupTestServer(assert, "indextest", testFileSystemRootIndex)
+	defer cleanup()
+
+	w := makeTestHTTPRequest(server, assert, http.MethodPost, "/index", defaultTestRequestHeaders, map[string]any{"path": mustGetAbsolutePath(testFileSystemRootIndex)}, nil)
+	assert.Equal(http.StatusAccepted, w.Code)
+	assertSuccessfulIndexCreation(assert, server, w.Body.Bytes())
+
+	// Metadata stores from before the schema version was recorded in them do not have it
+	assert.NoError(server.kvDB.Delete(kvdb.BoltDefaultBucket, kvdb.SchemaVersionKey(searchdb.DefaultCollection)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service := index.New(ctx, newTestLogger(), server.indexer, server.kvDB)
+	assert.False(service.IsInProgress(), "an index with the current schema should not be rebuilt")
+
+	storedVersion, err := server.kvDB.Get(kvdb.BoltDefaultBucket, kvdb.SchemaVersionKey(searchdb.DefaultCollection))
+	assert.NoError(err)
+	assert.Equal(strconv.Itoa(server.indexer.SchemaVersion()), storedVersion)
+
+	docCount, err := server.indexer.GetDocCount()
+	assert.NoError(err)
+	assert.Equal(uint64(len(testFiles)), docCount, "indexed documents should be kept")
+}
+
+func TestIndexRequestRetention(t *testing.T) {
+	assert := require.New(t)
+	server, cleanup := setupTestServer(assert, "indextest", testFileSystemRootIndex)
+	defer cleanup()
+
+	var finishedIDs []string
+	for range 2 {
+		w := makeTestHTTPRequest(server, assert, http.MethodPost, "/index", defaultTestRequestHeaders, map[string]any{"path": mustGetAbsolutePath(testFileSystemRootIndex)}, nil)
+		assert.Equal(http.StatusAccepted, w.Code)
+		assertSuccessfulIndexCreation(assert, server, w.Body.Bytes())
+		finishedIDs = append(finishedIDs, decodeResponseData[IndexResponse](assert, w.Body.Bytes()).ID)
+	}
+	// Statuses of requests in progress or queued, including one stored the way statuses used to be
+	runningID, queuedID := uuid.New().String(), uuid.New().String()
+	assert.NoError(server.kvDB.Set(kvdb.RequestsBucket, runningID, fmt.Sprintf(`{"status":%d,"updated_at":"2020-01-01T00:00:00Z"}`, index.ProgressStatusStep2)))
+	assert.NoError(server.kvDB.Set(kvdb.RequestsBucket, queuedID, "0"))
+
+	t.Run("Purge", func(t *testing.T) {
+		assert := require.New(t)
+		w := makeTestHTTPRequest(server, assert, http.MethodDelete, "/index", nil, nil, nil)
+		assert.Equal(http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(len(finishedIDs), decodeResponseData[PurgeIndexRequestsResponse](assert, w.Body.Bytes()).Purged)
+
+		for _, id := range finishedIDs {
+			w := makeTestHTTPRequest(server, assert, http.MethodGet, fmt.Sprintf("/index/%s", id), nil, nil, nil)
+			assert.Equal(http.StatusNotFound, w.Code)
+		}
+		for _, id := range []string{runningID, queuedID} {
+			w := makeTestHTTPRequest(server, assert, http.MethodGet, fmt.Sprintf("/index/%s", id), nil, nil, nil)
+			assert.Equal(http.StatusAccepted, w.Code, "requests that are not finished should never be purged")
+		}
+	})
+
+	t.Run("Janitor", func(t *testing.T) {
+		assert := require.New(t)
+		now := time.Now().UTC()
+		finished := map[string]string{
+			"latest":  fmt.Sprintf(`{"status":%d,"updated_at":%q}`, index.ProgressStatusComplete, now.Add(-time.Hour).Format(time.RFC3339)),
+			"earlier": fmt.Sprintf(`{"status":%d,"updated_at":%q}`, index.ProgressStatusFailed, now.Add(-2*time.Hour).Format(time.RFC3339)),
+			"third":   fmt.Sprintf(`{"status":%d,"updated_at":%q}`, index.ProgressStatusComplete, now.Add(-3*time.Hour).Format(time.RFC3339)),
+			"legacy":  strconv.Itoa(index.ProgressStatusComplete),
+		}
+		assert.NoError(server.kvDB.SetMany(kvdb.RequestsBucket, finished))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		service := index.New(ctx, newTestLogger(), server.indexer, server.kvDB)
+		service.StartJanitor(ctx, index.Retention{MaxAge: 150 * time.Minute, MaxCount: 1, Interval: time.Hour})
+
+		expected := []string{"latest", queuedID, runningID}
+		slices.Sort(expected)
+		assert.Eventually(func() bool {
+			ids, err := server.kvDB.GetAllKeys(kvdb.RequestsBucket)
+			return err == nil && slices.Equal(expected, ids)
+		}, 5*time.Second, 50*time.Millisecond, "only the latest finished request and those not finished should be kept")
+	})
+}
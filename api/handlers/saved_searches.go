@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meghashyamc/wheresthat/logger"
+	"github.com/meghashyamc/wheresthat/services/index"
+	"github.com/meghashyamc/wheresthat/services/savedsearch"
+	"github.com/meghashyamc/wheresthat/services/search"
+	"github.com/meghashyamc/wheresthat/validation"
+)
+
+type CreateSavedSearchRequest struct {
+	Name  string `json:"name" validate:"required,min=1,max=100"`
+	Query string `json:"query" validate:"required,valid_query,min=1,max=1000"`
+	Under string `json:"under" validate:"valid_path"`
+	// Mode is "keyword" (the default), "regex" or "literal"
+	Mode string `json:"mode" validate:"omitempty,oneof=keyword regex literal"`
+	Lang string `json:"lang" validate:"omitempty,oneof=en de fr es it nl pt"`
+}
+
+type SavedSearchRequest struct {
+	ID string `uri:"id" validate:"required,uuid"`
+}
+
+type SavedSearchesResponse struct {
+	SavedSearches []savedsearch.SavedSearch `json:"saved_searches"`
+}
+
+type SavedSearchChangesResponse struct {
+	SavedSearch savedsearch.SavedSearch `json:"saved_search"`
+	// Changes are the files that started or stopped matching the saved search after index updates, with the
+	// latest first
+	Changes []savedsearch.Change `json:"changes"`
+}
+
+// SetupSavedSearches adds the saved search endpoints and returns the service running saved searches, which
+// needs to be told about index updates
+func SetupSavedSearches(router *gin.Engine, logger logger.Logger, searcher search.Searcher, metadataStore index.MetadataStore, validator *validation.Validator) *savedsearch.Service {
+	service := savedsearch.New(logger, searcher, metadataStore)
+	router.GET("/saved-searches", handleListSavedSearches(service, logger))
+	router.POST("/saved-searches", handleCreateSavedSearch(service, logger, validator))
+	router.DELETE("/saved-searches/:id", handleDeleteSavedSearch(service, logger, validator))
+	router.GET("/saved-searches/:id/changes", handleGetSavedSearchChanges(service, logger, validator))
+
+	return service
+}
+
+func handleListSavedSearches(service *savedsearch.Service, logger logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		savedSearches, err := service.List()
+		if err != nil {
+			logger.Error("failed to list saved searches", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusInternalServerError, []string{err.Error()})
+			return
+		}
+
+		writeResponse(c, SavedSearchesResponse{SavedSearches: savedSearches}, http.StatusOK, nil)
+	}
+}
+
+func handleCreateSavedSearch(service *savedsearch.Service, logger logger.Logger, validator *validation.Validator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request := CreateSavedSearchRequest{}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			logger.Warn("could not extract expected params from saved search request", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusUnprocessableEntity, []string{"failed to extract request body parameters"})
+			return
+		}
+
+		if err := validator.Validate(request); err != nil {
+			logger.Warn("could not validate saved search request", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotAcceptable, []string{err.Error()})
+			return
+		}
+
+		savedSearch, err := service.Create(savedsearch.SavedSearch{
+			Name:  request.Name,
+			Query: request.Query,
+			Under: request.Under,
+			Mode:  request.Mode,
+			Lang:  request.Lang,
+		})
+		if errors.Is(err, savedsearch.ErrAlreadyExists) {
+			c.Abort()
+			writeResponse(c, nil, http.StatusConflict, []string{err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("failed to create saved search", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusInternalServerError, []string{err.Error()})
+			return
+		}
+
+		writeResponse(c, savedSearch, http.StatusCreated, nil)
+	}
+}
+
+func handleDeleteSavedSearch(service *savedsearch.Service, logger logger.Logger, validator *validation.Validator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request, ok := bindSavedSearchRequest(c, logger, validator)
+		if !ok {
+			return
+		}
+
+		err := service.Delete(request.ID)
+		if errors.Is(err, savedsearch.ErrNotFound) {
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotFound, []string{err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("failed to delete saved search", "id", request.ID, "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusInternalServerError, []string{err.Error()})
+			return
+		}
+
+		writeResponse(c, nil, http.StatusNoContent, nil)
+	}
+}
+
+func handleGetSavedSearchChanges(service *savedsearch.Service, logger logger.Logger, validator *validation.Validator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request, ok := bindSavedSearchRequest(c, logger, validator)
+		if !ok {
+			return
+		}
+
+		savedSearch, changes, err := service.Changes(request.ID)
+		if errors.Is(err, savedsearch.ErrNotFound) {
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotFound, []string{err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("failed to get saved search changes", "id", request.ID, "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusInternalServerError, []string{err.Error()})
+			return
+		}
+
+		writeResponse(c, SavedSearchChangesResponse{SavedSearch: *savedSearch, Changes: changes}, http.StatusOK, nil)
+	}
+}
+
+// bindSavedSearchRequest extracts the ID of a saved search from the URL, writing an error response if it is
+// missing or invalid
+func bindSavedSearchRequest(c *gin.Context, logger logger.Logger, validator *validation.Validator) (SavedSearchRequest, bool) {
+	request := SavedSearchRequest{}
+	if err := c.ShouldBindUri(&request); err != nil {
+		logger.Warn("could not extract expected params from saved search request", "err", err.Error())
+		c.Abort()
+		writeResponse(c, nil, http.StatusUnprocessableEntity, []string{"failed to extract URL parameters"})
+		return request, false
+	}
+
+	if err := validator.Validate(request); err != nil {
+		logger.Warn("could not validate saved search request", "err", err.Error())
+		c.Abort()
+		writeResponse(c, nil, http.StatusNotAcceptable, []string{err.Error()})
+		return request, false
+	}
+
+	return request, true
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/meghashyamc/wheresthat/services/savedsearch"
+	"github.com/stretchr/testify/require"
+)
+
+const testFileSystemRootSavedSearches = "./.wheresthat_saved_searches_test"
+
+var createSavedSearchHandlerTestCases = []testCase{
+	{
+		name:           "NoQuery",
+		requestHeaders: defaultTestRequestHeaders,
+		requestBody:    map[string]any{"name": "no query"},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "NoName",
+		requestHeaders: defaultTestRequestHeaders,
+		requestBody:    map[string]any{"query": "cache"},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "SemanticMode",
+		requestHeaders: defaultTestRequestHeaders,
+		requestBody:    map[string]any{"name": "semantic", "query": "cache", "mode": "semantic"},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "Success",
+		requestHeaders: defaultTestRequestHeaders,
+		requestBody:    map[string]any{"name": "cache docs", "query": "cache"},
+		expectedStatus: http.StatusCreated,
+	},
+	{
+		name:           "DuplicateName",
+		requestHeaders: defaultTestRequestHeaders,
+		requestBody:    map[string]any{"name": "cache docs", "query": "eviction"},
+		expectedStatus: http.StatusConflict,
+	},
+}
+
+func TestHandleSavedSearches(t *testing.T) {
+	assert := require.New(t)
+	server, cleanup := setupTestServer(assert, "searchtest", testFileSystemRootSavedSearches)
+	defer cleanup()
+
+	indexRequestBody := map[string]any{
+		"path": mustGetAbsolutePath(testFileSystemRootSavedSearches),
+	}
+	w := makeTestHTTPRequest(server, assert, http.MethodPost, "/index", defaultTestRequestHeaders, indexRequestBody, nil)
+	assert.Equal(http.StatusAccepted, w.Code, "index creation should succeed before saving searches")
+	assertSuccessfulIndexCreation(assert, server, w.Body.Bytes())
+
+	var created savedsearch.SavedSearch
+	for _, testCase := range createSavedSearchHandlerTestCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert := require.New(t)
+			w := makeTestHTTPRequest(server, assert, http.MethodPost, "/saved-searches", testCase.requestHeaders, testCase.requestBody, nil)
+			assert.Equal(testCase.expectedStatus, w.Code, fmt.Sprintf("response gotten was %s", w.Body.String()))
+
+			if testCase.expectedStatus == http.StatusCreated {
+				created = decodeResponseData[savedsearch.SavedSearch](assert, w.Body.Bytes())
+				_, err := uuid.Parse(created.ID)
+				assert.NoError(err, "saved search should get a UUID")
+				assert.Equal(2, created.MatchCount, "saved search should be run when it is created")
+			}
+		})
+	}
+
+	t.Run("ListSavedSearches", func(t *testing.T) {
+		assert := require.New(t)
+		w := makeTestHTTPRequest(server, assert, http.MethodGet, "/saved-searches", nil, nil, nil)
+		assert.Equal(http.StatusOK, w.Code)
+		list := decodeResponseData[SavedSearchesResponse](assert, w.Body.Bytes())
+		assert.Len(list.SavedSearches, 1)
+		assert.Equal(created.ID, list.SavedSearches[0].ID)
+		assert.Equal("cache", list.SavedSearches[0].Query)
+	})
+
+	t.Run("NoChangesBeforeIndexUpdates", func(t *testing.T) {
+		assert := require.New(t)
+		w := makeTestHTTPRequest(server, assert, http.MethodGet, fmt.Sprintf("/saved-searches/%s/changes", created.ID), nil, nil, nil)
+		assert.Equal(http.StatusOK, w.Code)
+		changes := decodeResponseData[SavedSearchChangesResponse](assert, w.Body.Bytes())
+		assert.Empty(changes.Changes)
+	})
+
+	// One file stops matching the saved search and another one starts matching it
+	err := os.Remove(filepath.Join(testFileSystemRootSavedSearches, "designs/cache_notes.txt"))
+	assert.NoError(err, "should be able to delete file")
+	err = os.WriteFile(filepath.Join(testFileSystemRootSavedSearches, "designs/cache_sizing.txt"), []byte("Sizing the cache for peak load"), 0644)
+	assert.NoError(err, "should be able to create new file")
+
+	w = makeTestHTTPRequest(server, assert, http.MethodPost, "/index", defaultTestRequestHeaders, indexRequestBody, nil)
+	assert.Equal(http.StatusAccepted, w.Code, "index update should succeed")
+	assertSuccessfulIndexCreation(assert, server, w.Body.Bytes())
+
+	t.Run("ChangesAfterIndexUpdate", func(t *testing.T) {
+		assert := require.New(t)
+
+		// Saved searches are run in the background once the index request completes
+		var changes SavedSearchChangesResponse
+		assert.Eventually(func() bool {
+			w := makeTestHTTPRequest(server, assert, http.MethodGet, fmt.Sprintf("/saved-searches/%s/changes", created.ID), nil, nil, nil)
+			assert.Equal(http.StatusOK, w.Code)
+			changes = decodeResponseData[SavedSearchChangesResponse](assert, w.Body.Bytes())
+			return len(changes.Changes) > 0
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(2, changes.SavedSearch.MatchCount)
+		assert.Len(changes.Changes, 1, "index update should be recorded as one change")
+		assert.Equal([]string{mustGetAbsolutePath(testFileSystemRootSavedSearches + "/designs/cache_sizing.txt")}, changes.Changes[0].Added)
+		assert.Equal([]string{mustGetAbsolutePath(testFileSystemRootSavedSearches + "/designs/cache_notes.txt")}, changes.Changes[0].Removed)
+	})
+
+	t.Run("ChangesOfUnknownSavedSearch", func(t *testing.T) {
+		assert := require.New(t)
+		w := makeTestHTTPRequest(server, assert, http.MethodGet, fmt.Sprintf("/saved-searches/%s/changes", uuid.New()), nil, nil, nil)
+		assert.Equal(http.StatusNotFound, w.Code)
+	})
+
+	t.Run("DeleteInvalidID", func(t *testing.T) {
+		assert := require.New(t)
+		w := makeTestHTTPRequest(server, assert, http.MethodDelete, "/saved-searches/abc", nil, nil, nil)
+		assert.Equal(http.StatusNotAcceptable, w.Code)
+	})
+
+	t.Run("DeleteSavedSearch", func(t *testing.T) {
+		assert := require.New(t)
+		w := makeTestHTTPRequest(server, assert, http.MethodDelete, fmt.Sprintf("/saved-searches/%s", created.ID), nil, nil, nil)
+		assert.Equal(http.StatusNoContent, w.Code)
+
+		w = makeTestHTTPRequest(server, assert, http.MethodDelete, fmt.Sprintf("/saved-searches/%s", created.ID), nil, nil, nil)
+		assert.Equal(http.StatusNotFound, w.Code, "deleting a saved search twice should fail")
+
+		w = makeTestHTTPRequest(server, assert, http.MethodGet, "/saved-searches", nil, nil, nil)
+		assert.Equal(http.StatusOK, w.Code)
+		assert.Empty(decodeResponseData[SavedSearchesResponse](assert, w.Body.Bytes()).SavedSearches)
+	})
+}
@@ -1,21 +1,53 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/meghashyamc/wheresthat/db/searchdb"
 	"github.com/meghashyamc/wheresthat/logger"
+	"github.com/meghashyamc/wheresthat/services/collection"
 	"github.com/meghashyamc/wheresthat/services/search"
 	"github.com/meghashyamc/wheresthat/validation"
 )
 
 const defaultResultsPerPage = 20
+const defaultFileFinderResults = 10
+const defaultGroupSize = 3
+const defaultCompletions = 10
+
+const (
+	fuzzyAuto = "auto"
+	fuzzyOff  = "off"
+)
 
 type SearchRequest struct {
 	Query   string `form:"query" validate:"required,valid_query,min=1,max=1000"`
 	PerPage int    `form:"per_page" validate:"min=0,max=20"`
 	Page    int    `form:"page" validate:"min=0"`
+	Under   string `form:"under" validate:"valid_path"`
+	// Fuzzy is "auto" (the default), "off" or the maximum number of typos allowed in each term
+	Fuzzy string `form:"fuzzy" validate:"omitempty,oneof=auto off 0 1 2"`
+	// AutoCorrect searches for the best spelling suggestion instead if the query has no results
+	AutoCorrect bool `form:"autocorrect"`
+	// Mode is "keyword" (the default), "semantic", "hybrid", "regex" or "literal"
+	Mode string `form:"mode" validate:"omitempty,oneof=keyword semantic hybrid regex literal"`
+	// Ranking is the name of a ranking profile from the config, to use instead of the default one
+	Ranking string `form:"ranking" validate:"max=100"`
+	// Explain adds the rewritten query and an explanation of the score of each result to the response
+	Explain bool `form:"explain"`
+	// GroupBy is "dir", "root" or "ext" to group results, in which case pages are made up of groups
+	GroupBy string `form:"group_by" validate:"omitempty,oneof=dir root ext"`
+	// GroupSize is the number of top results returned for each group
+	GroupSize int `form:"group_size" validate:"min=0,max=20"`
+	// Lang restricts results to documents detected to be in a language
+	Lang string `form:"lang" validate:"omitempty,oneof=en de fr es it nl pt"`
+	// Collection is a comma-separated list of the collections to search, instead of the default one
+	Collection string `form:"collection" validate:"max=1000"`
 }
 
 func (r *SearchRequest) setDefaults() {
@@ -26,16 +58,123 @@ func (r *SearchRequest) setDefaults() {
 	if r.Page == 0 {
 		r.Page = 1
 	}
+
+	if r.Fuzzy == "" {
+		r.Fuzzy = fuzzyAuto
+	}
+
+	if r.GroupSize == 0 {
+		r.GroupSize = defaultGroupSize
+	}
+}
+
+// fuzziness converts a validated fuzzy parameter into the fuzziness used by the search index
+func (r *SearchRequest) fuzziness() int {
+	switch r.Fuzzy {
+	case fuzzyAuto:
+		return searchdb.FuzzinessAuto
+	case fuzzyOff:
+		return 0
+	}
+
+	fuzziness, err := strconv.Atoi(r.Fuzzy)
+	if err != nil {
+		return searchdb.FuzzinessAuto
+	}
+	return fuzziness
+}
+
+// collections returns the names of the collections a search is restricted to
+func (r *SearchRequest) collections() []string {
+	var names []string
+	for _, name := range strings.Split(r.Collection, ",") {
+		if name = strings.TrimSpace(name); len(name) > 0 {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+type SimilarRequest struct {
+	Path     string `form:"path" validate:"required,valid_path_format"`
+	PerPage  int    `form:"per_page" validate:"min=0,max=20"`
+	Page     int    `form:"page" validate:"min=0"`
+	SameRoot bool   `form:"same_root"`
+	SameExt  bool   `form:"same_ext"`
+}
+
+func (r *SimilarRequest) setDefaults() {
+	if r.PerPage == 0 {
+		r.PerPage = defaultResultsPerPage
+	}
+
+	if r.Page == 0 {
+		r.Page = 1
+	}
+}
+
+type FindFilesRequest struct {
+	Query string `form:"query" validate:"required,valid_query,min=1,max=255"`
+	Limit int    `form:"limit" validate:"min=0,max=50"`
+}
+
+func (r *FindFilesRequest) setDefaults() {
+	if r.Limit == 0 {
+		r.Limit = defaultFileFinderResults
+	}
+}
+
+type SuggestRequest struct {
+	Prefix string `form:"prefix" validate:"required,valid_query,min=1,max=255"`
+	Limit  int    `form:"limit" validate:"min=0,max=50"`
+}
+
+func (r *SuggestRequest) setDefaults() {
+	if r.Limit == 0 {
+		r.Limit = defaultCompletions
+	}
+}
+
+type SuggestResponse struct {
+	// Terms complete the last word of the prefix, with the terms in the most documents first
+	Terms []searchdb.TermCompletion `json:"terms"`
+	// Files are the files whose names start with the prefix
+	Files []searchdb.FileCompletion `json:"files"`
+}
+
+type FindFilesResponse struct {
+	Results      []searchdb.Result `json:"results"`
+	TotalResults int               `json:"total_results"`
 }
 
 type SearchResponse struct {
 	Results     []searchdb.Result `json:"results"`
 	PageDetails Pagination        `json:"page_details"`
+	// FuzzyTerms maps query terms to the terms they matched with typos
+	FuzzyTerms map[string][]string `json:"fuzzy_terms,omitempty"`
+	// Synonyms maps query terms to the synonyms they were expanded with
+	Synonyms map[string][]string `json:"synonyms,omitempty"`
+	// Suggestions are corrected versions of a query that had no results
+	Suggestions []string `json:"suggestions,omitempty"`
+	// CorrectedQuery is the suggestion whose results were returned instead of the query's
+	CorrectedQuery string `json:"corrected_query,omitempty"`
+	// Partial is true if a regex or literal search hit its time or file limits
+	Partial bool `json:"partial,omitempty"`
+	// Query is the query the search was rewritten into, when an explanation was asked for
+	Query json.RawMessage `json:"query,omitempty"`
+	// Groups holds the results grouped by directory, root or extension, when grouping was requested
+	Groups []searchdb.Group `json:"groups,omitempty"`
+	// Languages counts the results in each language documents were detected to be in
+	Languages []searchdb.FacetTerm `json:"languages,omitempty"`
 }
 
 func SetupSearch(router *gin.Engine, logger logger.Logger, searcher search.Searcher, validator *validation.Validator) {
 	service := search.New(logger, searcher)
 	router.GET("/search", handleSearch(service, logger, validator))
+	router.GET("/search/files", handleFindFiles(service, logger, validator))
+	router.GET("/search/similar", handleFindSimilar(service, logger, validator))
+	router.GET("/suggest", handleSuggest(service, logger, validator))
 
 }
 
@@ -59,7 +198,33 @@ func handleSearch(service *search.Service, logger logger.Logger, validator *vali
 
 		limit := request.PerPage
 		offset := (request.Page - 1) * request.PerPage
-		results, err := service.Search(request.Query, limit, offset)
+		results, err := service.Search(searchdb.SearchParams{
+			Query:          request.Query,
+			Limit:          limit,
+			Offset:         offset,
+			Under:          request.Under,
+			Fuzziness:      request.fuzziness(),
+			AutoCorrect:    request.AutoCorrect,
+			Mode:           request.Mode,
+			RankingProfile: request.Ranking,
+			Explain:        request.Explain,
+			GroupBy:        request.GroupBy,
+			GroupSize:      request.GroupSize,
+			Lang:           request.Lang,
+			Collections:    request.collections(),
+		})
+		if errors.Is(err, collection.ErrNotFound) {
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotFound, []string{err.Error()})
+			return
+		}
+		if errors.Is(err, searchdb.ErrSemanticSearchDisabled) || errors.Is(err, searchdb.ErrInvalidPattern) ||
+			errors.Is(err, searchdb.ErrGroupingNotSupported) || errors.Is(err, searchdb.ErrUnknownRankingProfile) || errors.Is(err, searchdb.ErrExplainNotSupported) ||
+			errors.Is(err, searchdb.ErrLanguageFilterNotSupported) || errors.Is(err, searchdb.ErrMultiCollectionSearchNotSupported) {
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotAcceptable, []string{err.Error()})
+			return
+		}
 		if err != nil {
 			logger.Error("search failed", "err", err.Error())
 			c.Abort()
@@ -67,6 +232,106 @@ func handleSearch(service *search.Service, logger logger.Logger, validator *vali
 			return
 		}
 
+		// Pages are made up of groups when results are grouped
+		total := int(results.Total)
+		if len(request.GroupBy) > 0 {
+			total = results.TotalGroups
+		}
+
+		searchResponse := SearchResponse{
+			Results: results.Results,
+			PageDetails: calculatePagination(
+				total,
+				limit,
+				offset),
+			FuzzyTerms:     results.FuzzyTerms,
+			Synonyms:       results.Synonyms,
+			Suggestions:    results.Suggestions,
+			CorrectedQuery: results.CorrectedQuery,
+			Partial:        results.Partial,
+			Query:          results.Query,
+			Groups:         results.Groups,
+			Languages:      results.Languages,
+		}
+
+		writeResponse(c, searchResponse, http.StatusOK, nil)
+	}
+}
+
+func handleFindFiles(service *search.Service, logger logger.Logger, validator *validation.Validator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request := FindFilesRequest{}
+		if err := c.ShouldBindQuery(&request); err != nil {
+			logger.Warn("could not extract expected params from find files request", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusUnprocessableEntity, []string{"failed to extract query parameters"})
+			return
+		}
+		request.setDefaults()
+
+		if err := validator.Validate(request); err != nil {
+			logger.Warn("could not validate find files request", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotAcceptable, []string{err.Error()})
+			return
+		}
+
+		results, err := service.FindFiles(request.Query, request.Limit)
+		if err != nil {
+			logger.Error("finding files failed", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusInternalServerError, []string{err.Error()})
+			return
+		}
+
+		findFilesResponse := FindFilesResponse{
+			Results:      results.Results,
+			TotalResults: int(results.Total),
+		}
+
+		writeResponse(c, findFilesResponse, http.StatusOK, nil)
+	}
+}
+
+func handleFindSimilar(service *search.Service, logger logger.Logger, validator *validation.Validator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request := SimilarRequest{}
+		if err := c.ShouldBindQuery(&request); err != nil {
+			logger.Warn("could not extract expected params from similar files request", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusUnprocessableEntity, []string{"failed to extract query parameters"})
+			return
+		}
+		request.setDefaults()
+
+		if err := validator.Validate(request); err != nil {
+			logger.Warn("could not validate similar files request", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotAcceptable, []string{err.Error()})
+			return
+		}
+
+		limit := request.PerPage
+		offset := (request.Page - 1) * request.PerPage
+		results, err := service.FindSimilar(searchdb.SimilarParams{
+			Path:     request.Path,
+			Limit:    limit,
+			Offset:   offset,
+			SameRoot: request.SameRoot,
+			SameExt:  request.SameExt,
+		})
+		if errors.Is(err, searchdb.ErrDocumentNotFound) {
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotFound, []string{"file is not indexed"})
+			return
+		}
+		if err != nil {
+			logger.Error("finding similar files failed", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusInternalServerError, []string{err.Error()})
+			return
+		}
+
 		searchResponse := SearchResponse{
 			Results: results.Results,
 			PageDetails: calculatePagination(
@@ -78,3 +343,38 @@ func handleSearch(service *search.Service, logger logger.Logger, validator *vali
 		writeResponse(c, searchResponse, http.StatusOK, nil)
 	}
 }
+
+func handleSuggest(service *search.Service, logger logger.Logger, validator *validation.Validator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request := SuggestRequest{}
+		if err := c.ShouldBindQuery(&request); err != nil {
+			logger.Warn("could not extract expected params from suggest request", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusUnprocessableEntity, []string{"failed to extract query parameters"})
+			return
+		}
+		request.setDefaults()
+
+		if err := validator.Validate(request); err != nil {
+			logger.Warn("could not validate suggest request", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotAcceptable, []string{err.Error()})
+			return
+		}
+
+		completions, err := service.Complete(request.Prefix, request.Limit)
+		if err != nil {
+			logger.Error("completing prefix failed", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusInternalServerError, []string{err.Error()})
+			return
+		}
+
+		suggestResponse := SuggestResponse{
+			Terms: completions.Terms,
+			Files: completions.Files,
+		}
+
+		writeResponse(c, suggestResponse, http.StatusOK, nil)
+	}
+}
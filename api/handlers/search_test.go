@@ -152,6 +152,467 @@ var searchHandlerTestCases = []testCase{
 			},
 		},
 	},
+	{
+		name:           "SearchPartOfCamelCaseIdentifier",
+		queryParams:    map[string]string{"query": "quoted"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{
+						Path: mustGetAbsolutePath(testFileSystemRootSearch + "/subdir/query_parser.js"),
+					},
+				},
+			},
+		},
+	},
+	{
+		name:           "SearchWholeCamelCaseIdentifier",
+		queryParams:    map[string]string{"query": "parseQuotedQuery"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{
+						Path: mustGetAbsolutePath(testFileSystemRootSearch + "/subdir/query_parser.js"),
+					},
+				},
+			},
+		},
+	},
+	{
+		name:           "SearchPartOfSnakeCaseFilename",
+		queryParams:    map[string]string{"query": "parser"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{
+						Path: mustGetAbsolutePath(testFileSystemRootSearch + "/subdir/query_parser.js"),
+					},
+				},
+			},
+		},
+	},
+	{
+		name:           "SearchDirectoryName",
+		queryParams:    map[string]string{"query": "nested"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{
+						Path: mustGetAbsolutePath(testFileSystemRootSearch + "/subdir/nested/file5.py"),
+					},
+				},
+			},
+		},
+	},
+	{
+		name:           "SearchUnderDirectory",
+		queryParams:    map[string]string{"query": "hello", "under": mustGetAbsolutePath(testFileSystemRootSearch + "/subdir")},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{
+						Path: mustGetAbsolutePath(testFileSystemRootSearch + "/subdir/nested/file5.py"),
+					},
+				},
+			},
+		},
+	},
+	{
+		name:           "SearchUnderRootDirectory",
+		queryParams:    map[string]string{"query": "markdown", "under": "/"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{
+						Path: mustGetAbsolutePath(testFileSystemRootSearch + "/subdir/file3.md"),
+					},
+				},
+			},
+		},
+	},
+	{
+		name:           "SearchUnderNonExistentDirectory",
+		queryParams:    map[string]string{"query": "hello", "under": "/nonexistent"},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "SearchWithTypo",
+		queryParams:    map[string]string{"query": "markdwon"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{
+						Path: mustGetAbsolutePath(testFileSystemRootSearch + "/subdir/file3.md"),
+					},
+				},
+				FuzzyTerms: map[string][]string{"markdwon": {"markdown"}},
+			},
+		},
+	},
+	{
+		name:           "SearchWithTypoFuzzyOff",
+		queryParams:    map[string]string{"query": "markdwon", "fuzzy": "off"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{},
+			},
+		},
+	},
+	{
+		name:           "SearchWithTypoExplicitFuzziness",
+		queryParams:    map[string]string{"query": "markdowm", "fuzzy": "1"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{
+						Path: mustGetAbsolutePath(testFileSystemRootSearch + "/subdir/file3.md"),
+					},
+				},
+				FuzzyTerms: map[string][]string{"markdowm": {"markdown"}},
+			},
+		},
+	},
+	{
+		name:           "SearchWithTyposSuggestions",
+		queryParams:    map[string]string{"query": "helo wrld", "fuzzy": "off"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results:     []searchdb.Result{},
+				Suggestions: []string{"hello world"},
+			},
+		},
+	},
+	{
+		name:           "SearchWithTypoAutoCorrect",
+		queryParams:    map[string]string{"query": "markdwon", "fuzzy": "off", "autocorrect": "true"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{
+						Path: mustGetAbsolutePath(testFileSystemRootSearch + "/subdir/file3.md"),
+					},
+				},
+				Suggestions:    []string{"markdown"},
+				CorrectedQuery: "markdown",
+			},
+		},
+	},
+	{
+		name:           "SearchConceptualQueryKeywordMode",
+		queryParams:    map[string]string{"query": "where do we retry failed payments", "fuzzy": "off"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{},
+			},
+		},
+	},
+	{
+		name:           "SearchConceptualQuerySemanticMode",
+		queryParams:    map[string]string{"query": "where do we retry failed payments", "mode": "semantic", "per_page": "1"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{
+						Path: mustGetAbsolutePath(testFileSystemRootSearch + "/billing/charges.md"),
+					},
+				},
+			},
+		},
+	},
+	{
+		name:           "SearchConceptualQueryHybridMode",
+		queryParams:    map[string]string{"query": "retrying payments", "mode": "hybrid", "per_page": "1"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{
+						Path: mustGetAbsolutePath(testFileSystemRootSearch + "/billing/charges.md"),
+					},
+				},
+			},
+		},
+	},
+	{
+		name:           "SearchSemanticModeUnderDirectory",
+		queryParams:    map[string]string{"query": "retry failed payments", "mode": "semantic", "under": mustGetAbsolutePath(testFileSystemRootSearch + "/designs")},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{},
+			},
+		},
+	},
+	{
+		name:           "SearchInvalidMode",
+		queryParams:    map[string]string{"query": "payments", "mode": "vector"},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "SearchRegex",
+		queryParams:    map[string]string{"query": `print\(.Hello`, "mode": "regex"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{
+						Path:  mustGetAbsolutePath(testFileSystemRootSearch + "/file2.go"),
+						Lines: []searchdb.LineMatch{{Number: 4, Text: "\tprint(\"Hello\")"}},
+					},
+					{
+						Path:  mustGetAbsolutePath(testFileSystemRootSearch + "/subdir/nested/file5.py"),
+						Lines: []searchdb.LineMatch{{Number: 2, Text: "    print('Hello World')"}},
+					},
+				},
+			},
+		},
+	},
+	{
+		name:           "SearchLiteral",
+		queryParams:    map[string]string{"query": `print("Hello")`, "mode": "literal"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{
+						Path:  mustGetAbsolutePath(testFileSystemRootSearch + "/file2.go"),
+						Lines: []searchdb.LineMatch{{Number: 4, Text: "\tprint(\"Hello\")"}},
+					},
+				},
+			},
+		},
+	},
+	{
+		name:           "SearchLiteralPartOfIdentifier",
+		queryParams:    map[string]string{"query": "QuotedQuery(in", "mode": "literal"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{
+						Path:  mustGetAbsolutePath(testFileSystemRootSearch + "/subdir/query_parser.js"),
+						Lines: []searchdb.LineMatch{{Number: 1, Text: "function parseQuotedQuery(input) {}"}},
+					},
+				},
+			},
+		},
+	},
+	{
+		name:           "SearchRegexIsCaseSensitive",
+		queryParams:    map[string]string{"query": `print\(.hello`, "mode": "regex"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{},
+			},
+		},
+	},
+	{
+		name:           "SearchInvalidRegex",
+		queryParams:    map[string]string{"query": "print(", "mode": "regex"},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "SearchInvalidFuzzy",
+		queryParams:    map[string]string{"query": "markdwon", "fuzzy": "3"},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "SearchWithRankingProfile",
+		queryParams:    map[string]string{"query": "hello", "ranking": "shallow"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{
+						Path: mustGetAbsolutePath(testFileSystemRootSearch + "/file2.go"),
+					},
+					{
+						Path: mustGetAbsolutePath(testFileSystemRootSearch + "/subdir/nested/file5.py"),
+					},
+				},
+			},
+		},
+	},
+	{
+		name:           "SearchUnknownRankingProfile",
+		queryParams:    map[string]string{"query": "hello", "ranking": "newest"},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "SearchWithExplanation",
+		queryParams:    map[string]string{"query": "hello", "explain": "true"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{
+						Path:        mustGetAbsolutePath(testFileSystemRootSearch + "/subdir/nested/file5.py"),
+						Explanation: &searchdb.Explanation{},
+					},
+					{
+						Path:        mustGetAbsolutePath(testFileSystemRootSearch + "/file2.go"),
+						Explanation: &searchdb.Explanation{},
+					},
+				},
+				Query: json.RawMessage(`{}`),
+			},
+		},
+	},
+	{
+		name:           "SearchExplanationInSemanticMode",
+		queryParams:    map[string]string{"query": "hello", "explain": "true", "mode": "semantic"},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "SearchGroupByDir",
+		queryParams:    map[string]string{"query": "cache", "group_by": "dir"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{},
+				Groups: []searchdb.Group{
+					{
+						Key:   mustGetAbsolutePath(testFileSystemRootSearch + "/designs"),
+						Total: 2,
+						Results: []searchdb.Result{
+							{Path: mustGetAbsolutePath(testFileSystemRootSearch + "/designs/cache_design.md")},
+							{Path: mustGetAbsolutePath(testFileSystemRootSearch + "/designs/cache_notes.txt")},
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		name:           "SearchGroupByExtWithGroupSize",
+		queryParams:    map[string]string{"query": "hello", "group_by": "ext", "group_size": "1"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{},
+				Groups: []searchdb.Group{
+					{
+						Key:     ".go",
+						Total:   1,
+						Results: []searchdb.Result{{Path: mustGetAbsolutePath(testFileSystemRootSearch + "/file2.go")}},
+					},
+					{
+						Key:     ".py",
+						Total:   1,
+						Results: []searchdb.Result{{Path: mustGetAbsolutePath(testFileSystemRootSearch + "/subdir/nested/file5.py")}},
+					},
+				},
+			},
+		},
+	},
+	{
+		name:           "SearchGroupsWithPagination",
+		queryParams:    map[string]string{"query": "hello", "group_by": "ext", "per_page": "1", "page": "2"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{},
+				Groups: []searchdb.Group{
+					{
+						Key:     ".py",
+						Total:   1,
+						Results: []searchdb.Result{{Path: mustGetAbsolutePath(testFileSystemRootSearch + "/subdir/nested/file5.py")}},
+					},
+				},
+				PageDetails: Pagination{
+					CurrentPage:  2,
+					PageSize:     1,
+					HasPrevPage:  true,
+					HasNextPage:  false,
+					TotalPages:   2,
+					TotalResults: 2,
+				},
+			},
+		},
+	},
+	{
+		name:           "SearchGroupsInSemanticMode",
+		queryParams:    map[string]string{"query": "hello", "group_by": "dir", "mode": "semantic"},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "SearchInvalidGroupBy",
+		queryParams:    map[string]string{"query": "hello", "group_by": "size"},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "SearchStemmedTerm",
+		queryParams:    map[string]string{"query": "retry"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{Path: mustGetAbsolutePath(testFileSystemRootSearch + "/billing/charges.md")},
+				},
+			},
+		},
+	},
+	{
+		name:           "SearchWithLanguageFilter",
+		queryParams:    map[string]string{"query": "cache", "lang": "en"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{Path: mustGetAbsolutePath(testFileSystemRootSearch + "/designs/cache_design.md")},
+				},
+				Languages: []searchdb.FacetTerm{{Term: "en", Count: 1}},
+			},
+		},
+	},
+	{
+		name:           "SearchWithLanguageFilterNoResults",
+		queryParams:    map[string]string{"query": "cache", "lang": "de"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{},
+			},
+		},
+	},
+	{
+		name:           "SearchLanguageFacet",
+		queryParams:    map[string]string{"query": "cache eviction"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{Path: mustGetAbsolutePath(testFileSystemRootSearch + "/designs/cache_notes.txt")},
+					{Path: mustGetAbsolutePath(testFileSystemRootSearch + "/designs/cache_design.md")},
+				},
+				Languages: []searchdb.FacetTerm{{Term: "en", Count: 1}},
+			},
+		},
+	},
+	{
+		name:           "SearchLanguageFilterInSemanticMode",
+		queryParams:    map[string]string{"query": "cache", "lang": "en", "mode": "semantic"},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "SearchInvalidLanguage",
+		queryParams:    map[string]string{"query": "cache", "lang": "xx"},
+		expectedStatus: http.StatusNotAcceptable,
+	},
 	{
 		name:           "SearchNoResults",
 		queryParams:    map[string]string{"query": "nonexistent"},
@@ -211,6 +672,78 @@ var searchHandlerTestCases = []testCase{
 	},
 }
 
+var findFilesHandlerTestCases = []testCase{
+	{
+		name:           "FindFilesNoQuery",
+		queryParams:    map[string]string{},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "FindFilesInvalidLimit",
+		queryParams:    map[string]string{"query": "file", "limit": "51"},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "FindFilesMiddleOfName",
+		queryParams:    map[string]string{"query": "parser"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{
+						Path: mustGetAbsolutePath(testFileSystemRootSearch + "/subdir/query_parser.js"),
+					},
+				},
+			},
+		},
+	},
+	{
+		name:           "FindFilesCharactersInOrder",
+		queryParams:    map[string]string{"query": "fil3"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{
+						Path: mustGetAbsolutePath(testFileSystemRootSearch + "/subdir/file3.md"),
+					},
+				},
+			},
+		},
+	},
+	{
+		name:           "FindFilesInitials",
+		queryParams:    map[string]string{"query": "qp"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{
+						Path: mustGetAbsolutePath(testFileSystemRootSearch + "/subdir/query_parser.js"),
+					},
+				},
+			},
+		},
+	},
+	{
+		name:           "FindFilesWithLimit",
+		queryParams:    map[string]string{"query": "file", "limit": "2"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{
+						Path: mustGetAbsolutePath(testFileSystemRootSearch + "/file2.go"),
+					},
+					{
+						Path: mustGetAbsolutePath(testFileSystemRootSearch + "/subdir/file3.md"),
+					},
+				},
+			},
+		},
+	},
+}
+
 var searchBeforeFileChanges = testCase{
 
 	name:           "SearchBeforeFileChanges",
@@ -246,6 +779,134 @@ var searchAfterFileChanges = testCase{
 	},
 }
 
+var similarHandlerTestCases = []testCase{
+	{
+		name:           "SimilarNoPath",
+		queryParams:    map[string]string{},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "SimilarNotIndexedPath",
+		queryParams:    map[string]string{"path": mustGetAbsolutePath(testFileSystemRootSearch)},
+		expectedStatus: http.StatusNotFound,
+	},
+	{
+		name:           "SimilarNonExistentPath",
+		queryParams:    map[string]string{"path": "/nonexistent/notes.md"},
+		expectedStatus: http.StatusNotFound,
+	},
+	{
+		name:           "SimilarRelativePath",
+		queryParams:    map[string]string{"path": "designs/cache_design.md"},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "SimilarExcludesSourceDocument",
+		queryParams:    map[string]string{"path": mustGetAbsolutePath(testFileSystemRootSearch + "/designs/cache_design.md")},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{
+						Path: mustGetAbsolutePath(testFileSystemRootSearch + "/designs/cache_notes.txt"),
+					},
+				},
+			},
+		},
+	},
+	{
+		name:           "SimilarSameRoot",
+		queryParams:    map[string]string{"path": mustGetAbsolutePath(testFileSystemRootSearch + "/designs/cache_notes.txt"), "same_root": "true"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{
+						Path: mustGetAbsolutePath(testFileSystemRootSearch + "/designs/cache_design.md"),
+					},
+				},
+			},
+		},
+	},
+	{
+		name:           "SimilarSameExtension",
+		queryParams:    map[string]string{"path": mustGetAbsolutePath(testFileSystemRootSearch + "/designs/cache_design.md"), "same_ext": "true"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{},
+			},
+		},
+	},
+}
+
+var similarAfterFileMoved = testCase{
+	name:           "SimilarAfterFileMoved",
+	queryParams:    map[string]string{"path": mustGetAbsolutePath(testFileSystemRootSearch + "/designs/cache_design.md")},
+	expectedStatus: http.StatusOK,
+	expectedResponse: &response{
+		Data: SearchResponse{
+			Results: []searchdb.Result{
+				{
+					Path: mustGetAbsolutePath(testFileSystemRootSearch + "/designs/cache_notes.txt"),
+				},
+			},
+		},
+	},
+}
+
+var suggestHandlerTestCases = []testCase{
+	{
+		name:           "SuggestNoPrefix",
+		queryParams:    map[string]string{},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "SuggestInvalidLimit",
+		queryParams:    map[string]string{"prefix": "cach", "limit": "51"},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "SuggestTermsAndFiles",
+		queryParams:    map[string]string{"prefix": "cach"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SuggestResponse{
+				Terms: []searchdb.TermCompletion{{Term: "cache", Count: 2}, {Term: "cache_design.md", Count: 1}, {Term: "cache_notes.txt", Count: 1}},
+				Files: []searchdb.FileCompletion{
+					{Name: "cache_design.md", Path: mustGetAbsolutePath(testFileSystemRootSearch + "/designs/cache_design.md")},
+					{Name: "cache_notes.txt", Path: mustGetAbsolutePath(testFileSystemRootSearch + "/designs/cache_notes.txt")},
+				},
+			},
+		},
+	},
+	{
+		name:           "SuggestLastWordOfPrefix",
+		queryParams:    map[string]string{"prefix": "cache ev", "limit": "1"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SuggestResponse{
+				Terms: []searchdb.TermCompletion{{Term: "eviction", Count: 2}},
+				Files: []searchdb.FileCompletion{},
+			},
+		},
+	},
+}
+
+var suggestAfterFileChanges = testCase{
+	name:           "SuggestAfterFileChanges",
+	queryParams:    map[string]string{"prefix": "new"},
+	expectedStatus: http.StatusOK,
+	expectedResponse: &response{
+		Data: SuggestResponse{
+			Terms: []searchdb.TermCompletion{{Term: "new", Count: 1}, {Term: "newfile.txt", Count: 1}},
+			Files: []searchdb.FileCompletion{
+				{Name: "newfile.txt", Path: mustGetAbsolutePath(testFileSystemRootSearch + "/newfile.txt")},
+			},
+		},
+	},
+}
+
 func TestHandleSearch(t *testing.T) {
 
 	assert := require.New(t)
@@ -261,10 +922,42 @@ func TestHandleSearch(t *testing.T) {
 
 	for _, testCase := range searchHandlerTestCases {
 		t.Run(testCase.name, func(t *testing.T) {
-			assertSearchResults(t, testCase, server)
+			assertSearchResults(t, "/search", testCase, server)
+		})
+	}
+
+	for _, testCase := range findFilesHandlerTestCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assertSearchResults(t, "/search/files", testCase, server)
+		})
+	}
+
+	for _, testCase := range similarHandlerTestCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assertSearchResults(t, "/search/similar", testCase, server)
+		})
+	}
+
+	// Similar files are found from the terms the file was indexed with, so they are found even once it is gone
+	t.Run(similarAfterFileMoved.name, func(t *testing.T) {
+		path := mustGetAbsolutePath(testFileSystemRootSearch + "/designs/cache_design.md")
+		movedPath := filepath.Join(t.TempDir(), "cache_design.md")
+		assert.NoError(os.Rename(path, movedPath))
+		defer func() { assert.NoError(os.Rename(movedPath, path)) }()
+
+		assertSearchResults(t, "/search/similar", similarAfterFileMoved, server)
+	})
+
+	for _, testCase := range suggestHandlerTestCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assertSuggestions(t, testCase, server)
 		})
 	}
 
+	t.Run("SearchWithSynonyms", func(t *testing.T) {
+		assertSynonymExpansion(t, server)
+	})
+
 	// Testing scenario where the index is created multiple times with and without file changes
 	// Call /index with the same request body again
 	w = makeTestHTTPRequest(server, assert, http.MethodPost, "/index", defaultTestRequestHeaders, indexRequestBody, nil)
@@ -272,7 +965,7 @@ func TestHandleSearch(t *testing.T) {
 	assertSuccessfulIndexCreation(assert, server, w.Body.Bytes())
 
 	t.Run(searchBeforeFileChanges.name, func(t *testing.T) {
-		assertSearchResults(t, searchBeforeFileChanges, server)
+		assertSearchResults(t, "/search", searchBeforeFileChanges, server)
 	})
 
 	makeFileChanges(assert, testFileSystemRootSearch)
@@ -284,7 +977,12 @@ func TestHandleSearch(t *testing.T) {
 
 	// Try searching after file changes
 	t.Run(searchAfterFileChanges.name, func(t *testing.T) {
-		assertSearchResults(t, searchAfterFileChanges, server)
+		assertSearchResults(t, "/search", searchAfterFileChanges, server)
+	})
+
+	// Completions should be refreshed once indexing is done
+	t.Run(suggestAfterFileChanges.name, func(t *testing.T) {
+		assertSuggestions(t, suggestAfterFileChanges, server)
 	})
 
 }
@@ -307,13 +1005,13 @@ func makeFileChanges(assert *require.Assertions, testFileSystemRootSearch string
 	assert.NoError(err, "should be able to create new file")
 
 }
-func assertSearchResults(t *testing.T, testCase testCase, server *testServer) {
+func assertSearchResults(t *testing.T, endpoint string, testCase testCase, server *testServer) {
 	type searchResponse struct {
 		Data   SearchResponse `json:"data"`
 		Errors []string       `json:"errors"`
 	}
 	assert := require.New(t)
-	w := makeTestHTTPRequest(server, assert, http.MethodGet, "/search", testCase.requestHeaders, nil, testCase.queryParams)
+	w := makeTestHTTPRequest(server, assert, http.MethodGet, endpoint, testCase.requestHeaders, nil, testCase.queryParams)
 	responseBytes := w.Body.Bytes()
 	assert.Equal(testCase.expectedStatus, w.Code, fmt.Sprintf("response gotten was %s", string(responseBytes)))
 
@@ -331,6 +1029,45 @@ func assertSearchResults(t *testing.T, testCase testCase, server *testServer) {
 
 	for i, expectedResult := range expectedResponseData.Results {
 		assert.Equal(expectedResult.Path, actualResponse.Data.Results[i].Path)
+		if expectedResult.Lines != nil {
+			assert.Equal(expectedResult.Lines, actualResponse.Data.Results[i].Lines, "should have the matching lines")
+		}
+		if expectedResult.Explanation != nil {
+			explanation := actualResponse.Data.Results[i].Explanation
+			assert.NotNil(explanation, "should explain the score")
+			assert.InDelta(actualResponse.Data.Results[i].Score, explanation.Value, 0.0001, "explanation should add up to the score")
+			assert.NotEmpty(explanation.Children, "explanation should break down the score")
+		}
+	}
+
+	if expectedResponseData.FuzzyTerms != nil {
+		assert.Equal(expectedResponseData.FuzzyTerms, actualResponse.Data.FuzzyTerms, "should report the terms matched with typos")
+	}
+
+	if expectedResponseData.Suggestions != nil {
+		assert.Equal(expectedResponseData.Suggestions, actualResponse.Data.Suggestions, "should suggest corrected queries")
+		assert.Equal(expectedResponseData.CorrectedQuery, actualResponse.Data.CorrectedQuery, "should report the corrected query that was searched for")
+	}
+
+	if expectedResponseData.Languages != nil {
+		assert.Equal(expectedResponseData.Languages, actualResponse.Data.Languages, "should count the results in each language")
+	}
+
+	if expectedResponseData.Query != nil {
+		assert.Contains(string(actualResponse.Data.Query), `"disjuncts"`, "should include the rewritten query")
+	}
+
+	if expectedResponseData.Groups != nil {
+		assert.Equal(len(expectedResponseData.Groups), len(actualResponse.Data.Groups), "should have the expected number of groups")
+		for i, expectedGroup := range expectedResponseData.Groups {
+			actualGroup := actualResponse.Data.Groups[i]
+			assert.Equal(expectedGroup.Key, actualGroup.Key)
+			assert.Equal(expectedGroup.Total, actualGroup.Total, "should count the results in the group")
+			assert.Equal(len(expectedGroup.Results), len(actualGroup.Results), "should have the expected number of results in the group")
+			for j, expectedResult := range expectedGroup.Results {
+				assert.Equal(expectedResult.Path, actualGroup.Results[j].Path)
+			}
+		}
 	}
 
 	if expectedResponseData.PageDetails == (Pagination{}) {
@@ -340,3 +1077,71 @@ func assertSearchResults(t *testing.T, testCase testCase, server *testServer) {
 	assert.Equal(expectedResponseData.PageDetails, actualResponse.Data.PageDetails)
 
 }
+
+func assertSuggestions(t *testing.T, testCase testCase, server *testServer) {
+	type suggestResponse struct {
+		Data   SuggestResponse `json:"data"`
+		Errors []string        `json:"errors"`
+	}
+	assert := require.New(t)
+	w := makeTestHTTPRequest(server, assert, http.MethodGet, "/suggest", testCase.requestHeaders, nil, testCase.queryParams)
+	responseBytes := w.Body.Bytes()
+	assert.Equal(testCase.expectedStatus, w.Code, fmt.Sprintf("response gotten was %s", string(responseBytes)))
+
+	if testCase.expectedResponse == nil {
+		return
+	}
+
+	actualResponse := suggestResponse{}
+	err := json.Unmarshal(responseBytes, &actualResponse)
+	assert.NoError(err, "could not unmarshal gotten response")
+
+	expectedResponseData := testCase.expectedResponse.Data.(SuggestResponse)
+	assert.Equal(expectedResponseData.Terms, actualResponse.Data.Terms, "should complete the last word with the most common terms")
+	assert.Equal(expectedResponseData.Files, actualResponse.Data.Files, "should complete file names")
+}
+
+func assertSynonymExpansion(t *testing.T, server *testServer) {
+	type synonymsResponse struct {
+		Data   SynonymsResponse `json:"data"`
+		Errors []string         `json:"errors"`
+	}
+	assert := require.New(t)
+
+	w := makeTestHTTPRequest(server, assert, http.MethodPut, "/synonyms", defaultTestRequestHeaders, map[string]any{"sets": [][]string{{"invoice"}}}, nil)
+	assert.Equal(http.StatusNotAcceptable, w.Code, "a synonym set needs at least two terms")
+
+	w = makeTestHTTPRequest(server, assert, http.MethodPut, "/synonyms", defaultTestRequestHeaders, map[string]any{"sets": [][]string{{"Invoice", "payment"}, {"k8s", "kubernetes"}}}, nil)
+	assert.Equal(http.StatusOK, w.Code, fmt.Sprintf("response gotten was %s", w.Body.String()))
+
+	w = makeTestHTTPRequest(server, assert, http.MethodGet, "/synonyms", nil, nil, nil)
+	assert.Equal(http.StatusOK, w.Code)
+	listResponse := synonymsResponse{}
+	assert.NoError(json.Unmarshal(w.Body.Bytes(), &listResponse))
+	assert.Equal([][]string{{"invoice", "payment"}, {"k8s", "kubernetes"}}, listResponse.Data.Sets, "synonyms should be lower cased")
+
+	synonymSearch := testCase{
+		queryParams:    map[string]string{"query": "invoice"},
+		expectedStatus: http.StatusOK,
+		expectedResponse: &response{
+			Data: SearchResponse{
+				Results: []searchdb.Result{
+					{Path: mustGetAbsolutePath(testFileSystemRootSearch + "/billing/charges.md")},
+				},
+			},
+		},
+	}
+	assertSearchResults(t, "/search", synonymSearch, server)
+
+	w = makeTestHTTPRequest(server, assert, http.MethodGet, "/search", nil, nil, synonymSearch.queryParams)
+	searchResponse := struct {
+		Data SearchResponse `json:"data"`
+	}{}
+	assert.NoError(json.Unmarshal(w.Body.Bytes(), &searchResponse))
+	assert.Equal(map[string][]string{"invoice": {"payment"}}, searchResponse.Data.Synonyms, "should list the synonyms the query was expanded with")
+
+	result := searchResponse.Data.Results[0]
+	assert.Len(result.Highlights, 1, "should highlight the synonym in the snippet")
+	snippet := []rune(result.Snippet)
+	assert.Equal("payment", string(snippet[result.Highlights[0].Start:result.Highlights[0].End]))
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meghashyamc/wheresthat/db/searchdb"
+	"github.com/meghashyamc/wheresthat/logger"
+	"github.com/meghashyamc/wheresthat/services/collection"
+	"github.com/meghashyamc/wheresthat/services/stats"
+	"github.com/meghashyamc/wheresthat/validation"
+)
+
+type StatsRequest struct {
+	// Collection is the collection to get stats of, instead of the default one
+	Collection string `form:"collection" validate:"omitempty,alphanum,max=64"`
+}
+
+// SetupStats adds the stats endpoint. The service needs to be told about index requests to count them.
+func SetupStats(router *gin.Engine, logger logger.Logger, service *stats.Service, validator *validation.Validator) {
+	router.GET("/stats", handleGetStats(service, logger, validator))
+}
+
+func handleGetStats(service *stats.Service, logger logger.Logger, validator *validation.Validator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request := StatsRequest{}
+		if err := c.ShouldBindQuery(&request); err != nil {
+			logger.Warn("could not extract expected params from stats request", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusUnprocessableEntity, []string{"failed to extract query parameters"})
+			return
+		}
+
+		if err := validator.Validate(request); err != nil {
+			logger.Warn("could not validate stats request", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotAcceptable, []string{err.Error()})
+			return
+		}
+
+		if request.Collection == "" {
+			request.Collection = searchdb.DefaultCollection
+		}
+
+		collectionStats, err := service.Get(request.Collection)
+		if errors.Is(err, collection.ErrNotFound) {
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotFound, []string{err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("failed to get stats", "collection", request.Collection, "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusInternalServerError, []string{err.Error()})
+			return
+		}
+
+		writeResponse(c, collectionStats, http.StatusOK, nil)
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/meghashyamc/wheresthat/services/stats"
+	"github.com/stretchr/testify/require"
+)
+
+const testFileSystemRootStats = "./.wheresthat_stats_test"
+
+func TestHandleStats(t *testing.T) {
+	assert := require.New(t)
+	server, cleanup := setupTestServer(assert, "indextest", testFileSystemRootStats)
+	defer cleanup()
+
+	root := mustGetAbsolutePath(testFileSystemRootStats)
+
+	t.Run("Empty", func(t *testing.T) {
+		assert := require.New(t)
+		w := makeTestHTTPRequest(server, assert, http.MethodGet, "/stats", nil, nil, nil)
+		assert.Equal(http.StatusOK, w.Code, w.Body.String())
+		collectionStats := decodeResponseData[stats.Stats](assert, w.Body.Bytes())
+		assert.Zero(collectionStats.Documents)
+		assert.Zero(collectionStats.Jobs.Completed)
+		metadataBytes, err := server.kvDB.Size()
+		assert.NoError(err)
+		assert.Equal(metadataBytes, collectionStats.Disk.MetadataBytes)
+	})
+
+	w := makeTestHTTPRequest(server, assert, http.MethodPost, "/index", defaultTestRequestHeaders, map[string]any{"path": root}, nil)
+	assert.Equal(http.StatusAccepted, w.Code)
+	assertSuccessfulIndexCreation(assert, server, w.Body.Bytes())
+
+	t.Run("AfterIndexing", func(t *testing.T) {
+		assert := require.New(t)
+		w := makeTestHTTPRequest(server, assert, http.MethodGet, "/stats", nil, nil, nil)
+		assert.Equal(http.StatusOK, w.Code, w.Body.String())
+		collectionStats := decodeResponseData[stats.Stats](assert, w.Body.Bytes())
+
+		var indexedBytes int64
+		extensions := map[string]int{}
+		for path, content := range testFiles {
+			indexedBytes += int64(len(content))
+			extensions[filepath.Ext(path)]++
+		}
+
+		assert.Equal("default", collectionStats.Collection)
+		assert.Equal(uint64(len(testFiles)), collectionStats.Documents)
+		assert.Equal(map[string]int{root: len(testFiles)}, collectionStats.DocumentsByRoot)
+		assert.Equal(extensions, collectionStats.DocumentsByExtension)
+		assert.Equal(indexedBytes, collectionStats.IndexedBytes)
+		assert.Positive(collectionStats.VocabularySize)
+		assert.Len(collectionStats.LargestFiles, len(testFiles))
+		assert.Equal(filepath.Join(root, "billing/charges.md"), collectionStats.LargestFiles[0].Path)
+		assert.Positive(collectionStats.Disk.IndexBytes)
+		assert.Equal(1, collectionStats.Jobs.Completed)
+		assert.Zero(collectionStats.Jobs.Failed)
+		assert.Contains(collectionStats.Jobs.LastIndexedByRoot, root)
+	})
+
+	t.Run("UnknownCollection", func(t *testing.T) {
+		assert := require.New(t)
+		w := makeTestHTTPRequest(server, assert, http.MethodGet, "/stats", nil, nil, map[string]string{"collection": "missing"})
+		assert.Equal(http.StatusNotFound, w.Code, w.Body.String())
+	})
+
+	t.Run("InvalidCollection", func(t *testing.T) {
+		assert := require.New(t)
+		w := makeTestHTTPRequest(server, assert, http.MethodGet, "/stats", nil, nil, map[string]string{"collection": "not/valid"})
+		assert.Equal(http.StatusNotAcceptable, w.Code, w.Body.String())
+	})
+}
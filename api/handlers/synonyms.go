@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meghashyamc/wheresthat/db/searchdb"
+	"github.com/meghashyamc/wheresthat/logger"
+	"github.com/meghashyamc/wheresthat/services/search"
+	"github.com/meghashyamc/wheresthat/validation"
+)
+
+type SynonymsRequest struct {
+	// Sets are groups of words or phrases that mean the same thing, replacing all the current ones
+	Sets [][]string `json:"sets" validate:"required,max=10000,dive,min=2,max=100,dive,required,max=255"`
+}
+
+type SynonymsResponse struct {
+	Sets [][]string `json:"sets"`
+}
+
+func SetupSynonyms(router *gin.Engine, logger logger.Logger, searcher search.Searcher, validator *validation.Validator) {
+	service := search.New(logger, searcher)
+	router.GET("/synonyms", handleListSynonyms(service))
+	router.PUT("/synonyms", handleUpdateSynonyms(service, logger, validator))
+}
+
+func handleListSynonyms(service *search.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		writeResponse(c, SynonymsResponse{Sets: service.ListSynonyms()}, http.StatusOK, nil)
+	}
+}
+
+func handleUpdateSynonyms(service *search.Service, logger logger.Logger, validator *validation.Validator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request := SynonymsRequest{}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			logger.Warn("could not extract expected params from synonyms request", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusUnprocessableEntity, []string{"failed to extract request body parameters"})
+			return
+		}
+
+		if err := validator.Validate(request); err != nil {
+			logger.Warn("could not validate synonyms request", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotAcceptable, []string{err.Error()})
+			return
+		}
+
+		err := service.UpdateSynonyms(request.Sets)
+		if errors.Is(err, searchdb.ErrInvalidSynonyms) {
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotAcceptable, []string{err.Error()})
+			return
+		}
+		if err != nil {
+			c.Abort()
+			writeResponse(c, nil, http.StatusInternalServerError, []string{err.Error()})
+			return
+		}
+
+		writeResponse(c, SynonymsResponse{Sets: service.ListSynonyms()}, http.StatusOK, nil)
+	}
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/meghashyamc/wheresthat/config"
+	"github.com/meghashyamc/wheresthat/logger"
+	"github.com/meghashyamc/wheresthat/services/index"
+	"github.com/meghashyamc/wheresthat/services/webhook"
+	"github.com/meghashyamc/wheresthat/validation"
+)
+
+type CreateWebhookRequest struct {
+	URL string `json:"url" validate:"required,url,max=2000"`
+	// Events are the events posted to the webhook: "index.completed" and/or "index.failed"
+	Events []string `json:"events" validate:"required,min=1,max=2,dive,oneof=index.completed index.failed"`
+	// Secret is the key payloads are signed with
+	Secret string `json:"secret" validate:"required,min=8,max=255"`
+}
+
+type WebhookRequest struct {
+	ID string `uri:"id" validate:"required,uuid"`
+}
+
+type WebhooksResponse struct {
+	Webhooks []webhook.Webhook `json:"webhooks"`
+}
+
+type WebhookDeliveriesResponse struct {
+	// Deliveries are the latest deliveries to the webhook, with the latest first
+	Deliveries []webhook.Delivery `json:"deliveries"`
+}
+
+// SetupWebhooks adds the webhook endpoints and returns the service posting events to webhooks, which needs
+// to be told about index requests
+func SetupWebhooks(ctx context.Context, router *gin.Engine, logger logger.Logger, metadataStore index.MetadataStore, cfg *config.Config, validator *validation.Validator) *webhook.Service {
+	service := webhook.New(ctx, logger, metadataStore, webhook.Options{
+		MaxAttempts:    cfg.GetWebhookMaxAttempts(),
+		InitialBackoff: cfg.GetWebhookInitialBackoff(),
+		Timeout:        cfg.GetWebhookTimeout(),
+	})
+	router.GET("/webhooks", handleListWebhooks(service, logger))
+	router.POST("/webhooks", handleCreateWebhook(service, logger, validator))
+	router.DELETE("/webhooks/:id", handleDeleteWebhook(service, logger, validator))
+	router.GET("/webhooks/:id/deliveries", handleGetWebhookDeliveries(service, logger, validator))
+	router.POST("/webhooks/:id/test", handleSendTestWebhookEvent(service, logger, validator))
+
+	return service
+}
+
+func handleListWebhooks(service *webhook.Service, logger logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		webhooks, err := service.List()
+		if err != nil {
+			logger.Error("failed to list webhooks", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusInternalServerError, []string{err.Error()})
+			return
+		}
+
+		writeResponse(c, WebhooksResponse{Webhooks: webhooks}, http.StatusOK, nil)
+	}
+}
+
+func handleCreateWebhook(service *webhook.Service, logger logger.Logger, validator *validation.Validator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request := CreateWebhookRequest{}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			logger.Warn("could not extract expected params from webhook request", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusUnprocessableEntity, []string{"failed to extract request body parameters"})
+			return
+		}
+
+		if err := validator.Validate(request); err != nil {
+			logger.Warn("could not validate webhook request", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotAcceptable, []string{err.Error()})
+			return
+		}
+
+		created, err := service.Create(request.URL, request.Events, request.Secret)
+		if err != nil {
+			logger.Error("failed to create webhook", "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusInternalServerError, []string{err.Error()})
+			return
+		}
+
+		writeResponse(c, created, http.StatusCreated, nil)
+	}
+}
+
+func handleDeleteWebhook(service *webhook.Service, logger logger.Logger, validator *validation.Validator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request, ok := bindWebhookRequest(c, logger, validator)
+		if !ok {
+			return
+		}
+
+		err := service.Delete(request.ID)
+		if errors.Is(err, webhook.ErrNotFound) {
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotFound, []string{err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("failed to delete webhook", "id", request.ID, "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusInternalServerError, []string{err.Error()})
+			return
+		}
+
+		writeResponse(c, nil, http.StatusNoContent, nil)
+	}
+}
+
+func handleGetWebhookDeliveries(service *webhook.Service, logger logger.Logger, validator *validation.Validator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request, ok := bindWebhookRequest(c, logger, validator)
+		if !ok {
+			return
+		}
+
+		deliveries, err := service.Deliveries(request.ID)
+		if errors.Is(err, webhook.ErrNotFound) {
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotFound, []string{err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("failed to get webhook deliveries", "id", request.ID, "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusInternalServerError, []string{err.Error()})
+			return
+		}
+
+		writeResponse(c, WebhookDeliveriesResponse{Deliveries: deliveries}, http.StatusOK, nil)
+	}
+}
+
+// handleSendTestWebhookEvent posts a test event to a webhook once and responds with the delivery
+func handleSendTestWebhookEvent(service *webhook.Service, logger logger.Logger, validator *validation.Validator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request, ok := bindWebhookRequest(c, logger, validator)
+		if !ok {
+			return
+		}
+
+		delivery, err := service.SendTestEvent(request.ID)
+		if errors.Is(err, webhook.ErrNotFound) {
+			c.Abort()
+			writeResponse(c, nil, http.StatusNotFound, []string{err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("failed to send test webhook event", "id", request.ID, "err", err.Error())
+			c.Abort()
+			writeResponse(c, nil, http.StatusInternalServerError, []string{err.Error()})
+			return
+		}
+
+		writeResponse(c, delivery, http.StatusOK, nil)
+	}
+}
+
+// bindWebhookRequest extracts the ID of a webhook from the URL, writing an error response if it is missing
+// or invalid
+func bindWebhookRequest(c *gin.Context, logger logger.Logger, validator *validation.Validator) (WebhookRequest, bool) {
+	request := WebhookRequest{}
+	if err := c.ShouldBindUri(&request); err != nil {
+		logger.Warn("could not extract expected params from webhook request", "err", err.Error())
+		c.Abort()
+		writeResponse(c, nil, http.StatusUnprocessableEntity, []string{"failed to extract URL parameters"})
+		return request, false
+	}
+
+	if err := validator.Validate(request); err != nil {
+		logger.Warn("could not validate webhook request", "err", err.Error())
+		c.Abort()
+		writeResponse(c, nil, http.StatusNotAcceptable, []string{err.Error()})
+		return request, false
+	}
+
+	return request, true
+}
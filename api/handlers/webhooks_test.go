@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/meghashyamc/wheresthat/services/webhook"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testFileSystemRootWebhooks = "./.wheresthat_webhooks_test"
+	testWebhookSecret          = "test-webhook-secret"
+)
+
+var createWebhookHandlerTestCases = []testCase{
+	{
+		name:           "NoURL",
+		requestHeaders: defaultTestRequestHeaders,
+		requestBody:    map[string]any{"events": []string{webhook.EventIndexCompleted}, "secret": testWebhookSecret},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "InvalidURL",
+		requestHeaders: defaultTestRequestHeaders,
+		requestBody:    map[string]any{"url": "not a url", "events": []string{webhook.EventIndexCompleted}, "secret": testWebhookSecret},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "NoEvents",
+		requestHeaders: defaultTestRequestHeaders,
+		requestBody:    map[string]any{"url": "http://localhost/hook", "events": []string{}, "secret": testWebhookSecret},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "UnknownEvent",
+		requestHeaders: defaultTestRequestHeaders,
+		requestBody:    map[string]any{"url": "http://localhost/hook", "events": []string{"index.started"}, "secret": testWebhookSecret},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:           "SecretTooShort",
+		requestHeaders: defaultTestRequestHeaders,
+		requestBody:    map[string]any{"url": "http://localhost/hook", "events": []string{webhook.EventIndexCompleted}, "secret": "short"},
+		expectedStatus: http.StatusNotAcceptable,
+	},
+}
+
+// webhookReceiver records the payloads posted to it, failing the first few deliveries with a given status
+type webhookReceiver struct {
+	server *httptest.Server
+
+	mu          sync.Mutex
+	failures    int
+	failureCode int
+	payloads    []webhook.Payload
+	// signed is false if any payload had a wrong signature
+	signed bool
+}
+
+func newWebhookReceiver(failures int, failureCode int) *webhookReceiver {
+	receiver := &webhookReceiver{failures: failures, failureCode: failureCode, signed: true}
+	receiver.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receiver.mu.Lock()
+		defer receiver.mu.Unlock()
+
+		if receiver.failures > 0 {
+			receiver.failures--
+			w.WriteHeader(receiver.failureCode)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(webhook.HeaderSignature) != webhook.Sign(testWebhookSecret, body) {
+			receiver.signed = false
+		}
+		var payload webhook.Payload
+		if err := json.Unmarshal(body, &payload); err == nil && payload.Event == r.Header.Get(webhook.HeaderEvent) {
+			receiver.payloads = append(receiver.payloads, payload)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	return receiver
+}
+
+func (r *webhookReceiver) received() ([]webhook.Payload, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]webhook.Payload{}, r.payloads...), r.signed
+}
+
+func TestHandleWebhooks(t *testing.T) {
+	assert := require.New(t)
+	server, cleanup := setupTestServer(assert, "indextest", testFileSystemRootWebhooks)
+	defer cleanup()
+
+	for _, testCase := range createWebhookHandlerTestCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert := require.New(t)
+			w := makeTestHTTPRequest(server, assert, http.MethodPost, "/webhooks", testCase.requestHeaders, testCase.requestBody, nil)
+			assert.Equal(testCase.expectedStatus, w.Code, fmt.Sprintf("response gotten was %s", w.Body.String()))
+		})
+	}
+
+	receiver := newWebhookReceiver(0, 0)
+	defer receiver.server.Close()
+	flakyReceiver := newWebhookReceiver(2, http.StatusServiceUnavailable)
+	defer flakyReceiver.server.Close()
+	rejectingReceiver := newWebhookReceiver(100, http.StatusBadRequest)
+	defer rejectingReceiver.server.Close()
+
+	hook := createTestWebhook(assert, server, receiver.server.URL, webhook.EventIndexCompleted, webhook.EventIndexFailed)
+	flakyHook := createTestWebhook(assert, server, flakyReceiver.server.URL, webhook.EventIndexFailed)
+	rejectingHook := createTestWebhook(assert, server, rejectingReceiver.server.URL, webhook.EventIndexFailed)
+
+	t.Run("ListWebhooks", func(t *testing.T) {
+		assert := require.New(t)
+		w := makeTestHTTPRequest(server, assert, http.MethodGet, "/webhooks", nil, nil, nil)
+		assert.Equal(http.StatusOK, w.Code)
+		assert.NotContains(w.Body.String(), testWebhookSecret, "secrets should not be returned")
+		webhooks := decodeResponseData[WebhooksResponse](assert, w.Body.Bytes()).Webhooks
+		assert.Equal([]string{hook.ID, flakyHook.ID, rejectingHook.ID}, []string{webhooks[0].ID, webhooks[1].ID, webhooks[2].ID})
+	})
+
+	t.Run("SendTestEvent", func(t *testing.T) {
+		assert := require.New(t)
+		delivery := sendTestWebhookEvent(assert, server, hook.ID)
+		assert.True(delivery.Success)
+		assert.Equal(1, delivery.Attempts)
+		assert.Equal(http.StatusNoContent, delivery.StatusCode)
+
+		payloads, signed := receiver.received()
+		assert.True(signed, "payloads should be signed with the secret")
+		assert.Len(payloads, 1)
+		assert.Equal(webhook.EventTest, payloads[0].Event)
+		assert.Equal(delivery.ID, payloads[0].DeliveryID)
+	})
+
+	t.Run("SendTestEventNotRetried", func(t *testing.T) {
+		assert := require.New(t)
+		delivery := sendTestWebhookEvent(assert, server, flakyHook.ID)
+		assert.False(delivery.Success)
+		assert.Equal(1, delivery.Attempts, "test events should only be attempted once")
+		assert.Equal(http.StatusServiceUnavailable, delivery.StatusCode)
+	})
+
+	t.Run("SendTestEventRejected", func(t *testing.T) {
+		assert := require.New(t)
+		delivery := sendTestWebhookEvent(assert, server, rejectingHook.ID)
+		assert.False(delivery.Success)
+		assert.Equal(1, delivery.Attempts, "client errors should not be retried")
+		assert.Equal(http.StatusBadRequest, delivery.StatusCode)
+		assert.NotEmpty(delivery.Error)
+	})
+
+	t.Run("SendTestEventToUnknownWebhook", func(t *testing.T) {
+		assert := require.New(t)
+		w := makeTestHTTPRequest(server, assert, http.MethodPost, fmt.Sprintf("/webhooks/%s/test", uuid.New()), nil, nil, nil)
+		assert.Equal(http.StatusNotFound, w.Code)
+	})
+
+	retriedReceiver := newWebhookReceiver(2, http.StatusServiceUnavailable)
+	defer retriedReceiver.server.Close()
+	retriedHook := createTestWebhook(assert, server, retriedReceiver.server.URL, webhook.EventIndexCompleted)
+
+	indexRequestBody := map[string]any{"path": mustGetAbsolutePath(testFileSystemRootWebhooks)}
+	w := makeTestHTTPRequest(server, assert, http.MethodPost, "/index", defaultTestRequestHeaders, indexRequestBody, nil)
+	assert.Equal(http.StatusAccepted, w.Code, "index creation should succeed")
+	assertSuccessfulIndexCreation(assert, server, w.Body.Bytes())
+
+	t.Run("IndexCompletedEvent", func(t *testing.T) {
+		assert := require.New(t)
+
+		// Events about index requests are delivered in the background
+		var deliveries []webhook.Delivery
+		for startTime := time.Now(); time.Since(startTime) < 5*time.Second && len(deliveries) < 2; time.Sleep(50 * time.Millisecond) {
+			deliveries = getWebhookDeliveries(assert, server, hook.ID)
+		}
+		assert.Len(deliveries, 2, "the index request and test event should be in the delivery log")
+		assert.Equal(webhook.EventIndexCompleted, deliveries[0].Event)
+		assert.True(deliveries[0].Success)
+
+		payloads, signed := receiver.received()
+		assert.True(signed, "payloads should be signed with the secret")
+		assert.Len(payloads, 2)
+		job := payloads[1].Job
+		assert.NotNil(job, "payload should describe the index request")
+		assert.Equal(deliveries[0].RequestID, job.RequestID)
+		assert.Equal("completed", job.Status)
+		assert.Equal(len(testFiles), job.FilesIndexed)
+		assert.Equal(0, job.FilesDeleted)
+
+		assert.Len(getWebhookDeliveries(assert, server, flakyHook.ID), 1, "webhooks should only get the events they subscribed to")
+	})
+
+	t.Run("IndexCompletedEventWithRetries", func(t *testing.T) {
+		assert := require.New(t)
+
+		var deliveries []webhook.Delivery
+		for startTime := time.Now(); time.Since(startTime) < 5*time.Second && len(deliveries) < 1; time.Sleep(50 * time.Millisecond) {
+			deliveries = getWebhookDeliveries(assert, server, retriedHook.ID)
+		}
+		assert.Len(deliveries, 1)
+		assert.True(deliveries[0].Success, "server errors should be retried")
+		assert.Equal(3, deliveries[0].Attempts)
+	})
+
+	t.Run("DeleteWebhook", func(t *testing.T) {
+		assert := require.New(t)
+		w := makeTestHTTPRequest(server, assert, http.MethodDelete, fmt.Sprintf("/webhooks/%s", hook.ID), nil, nil, nil)
+		assert.Equal(http.StatusNoContent, w.Code)
+
+		w = makeTestHTTPRequest(server, assert, http.MethodGet, fmt.Sprintf("/webhooks/%s/deliveries", hook.ID), nil, nil, nil)
+		assert.Equal(http.StatusNotFound, w.Code, "deliveries of a deleted webhook should be gone")
+
+		w = makeTestHTTPRequest(server, assert, http.MethodDelete, "/webhooks/abc", nil, nil, nil)
+		assert.Equal(http.StatusNotAcceptable, w.Code)
+	})
+}
+
+func createTestWebhook(assert *require.Assertions, server *testServer, url string, events ...string) webhook.Webhook {
+	requestBody := map[string]any{"url": url, "events": events, "secret": testWebhookSecret}
+	w := makeTestHTTPRequest(server, assert, http.MethodPost, "/webhooks", defaultTestRequestHeaders, requestBody, nil)
+	assert.Equal(http.StatusCreated, w.Code, fmt.Sprintf("response gotten was %s", w.Body.String()))
+
+	return decodeResponseData[webhook.Webhook](assert, w.Body.Bytes())
+}
+
+func sendTestWebhookEvent(assert *require.Assertions, server *testServer, id string) webhook.Delivery {
+	w := makeTestHTTPRequest(server, assert, http.MethodPost, fmt.Sprintf("/webhooks/%s/test", id), nil, nil, nil)
+	assert.Equal(http.StatusOK, w.Code, fmt.Sprintf("response gotten was %s", w.Body.String()))
+
+	return decodeResponseData[webhook.Delivery](assert, w.Body.Bytes())
+}
+
+func getWebhookDeliveries(assert *require.Assertions, server *testServer, id string) []webhook.Delivery {
+	w := makeTestHTTPRequest(server, assert, http.MethodGet, fmt.Sprintf("/webhooks/%s/deliveries", id), nil, nil, nil)
+	assert.Equal(http.StatusOK, w.Code)
+
+	return decodeResponseData[WebhookDeliveriesResponse](assert, w.Body.Bytes()).Deliveries
+}
@@ -23,8 +23,15 @@ func (s *server) setupRoutes(ctx context.Context, router *gin.Engine) {
 		c.Redirect(http.StatusMovedPermanently, "/ui/index.html")
 	})
 
-	handlers.SetupIndex(ctx, router, s.logger, s.indexer, s.metadataStore, s.validator)
+	// Saved searches are run again, webhooks are notified and stats are updated whenever an index request is done
+	savedSearches := handlers.SetupSavedSearches(router, s.logger, s.searcher, s.metadataStore, s.validator)
+	webhooks := handlers.SetupWebhooks(ctx, router, s.logger, s.metadataStore, s.config, s.validator)
+	indexService := handlers.SetupIndex(ctx, router, s.logger, s.indexer, s.metadataStore, s.config, s.validator, savedSearches, webhooks, s.stats)
+	handlers.SetupCollections(ctx, router, s.logger, s.collections, s.validator, savedSearches, webhooks, s.stats)
+	handlers.SetupBackup(router, s.logger, s.backups, indexService)
+	handlers.SetupStats(router, s.logger, s.stats, s.validator)
 	handlers.SetupSearch(router, s.logger, s.searcher, s.validator)
+	handlers.SetupSynonyms(router, s.logger, s.searcher, s.validator)
 
 }
 
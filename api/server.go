@@ -15,8 +15,11 @@ import (
 	"github.com/meghashyamc/wheresthat/db/kvdb"
 	"github.com/meghashyamc/wheresthat/db/searchdb"
 	"github.com/meghashyamc/wheresthat/logger"
+	"github.com/meghashyamc/wheresthat/services/backup"
+	"github.com/meghashyamc/wheresthat/services/collection"
 	"github.com/meghashyamc/wheresthat/services/index"
 	"github.com/meghashyamc/wheresthat/services/search"
+	"github.com/meghashyamc/wheresthat/services/stats"
 	"github.com/meghashyamc/wheresthat/validation"
 )
 
@@ -24,6 +27,9 @@ type server struct {
 	router        *gin.Engine
 	httpServer    *http.Server
 	metadataStore index.MetadataStore
+	collections   *collection.Service
+	backups       *backup.Service
+	stats         *stats.Service
 	searcher      search.Searcher
 	indexer       index.Indexer
 	validator     *validation.Validator
@@ -50,21 +56,30 @@ func Run(ctx context.Context, cfg *config.Config) error {
 }
 
 func (s *server) setupDependencies() error {
-	var err error
-	s.metadataStore, err = kvdb.New(s.logger, s.config)
+	kvDB, err := kvdb.Open(s.logger, s.config)
 	if err != nil {
 		s.logger.Error("error creating kvDB", "err", err.Error())
 		return err
 	}
+	s.metadataStore = kvDB
 
 	searchDB, err := searchdb.New(s.logger, s.config)
 	if err != nil {
 		s.logger.Error("error creating searchDB", "err", err.Error())
 		return err
 	}
-	s.searcher = searchDB
 	s.indexer = searchDB
 
+	s.collections, err = collection.New(s.logger, searchDB, s.metadataStore)
+	if err != nil {
+		s.logger.Error("error opening collections", "err", err.Error())
+		return err
+	}
+	// Searches naming collections search them instead of the default one
+	s.searcher = s.collections.Searcher()
+	s.backups = backup.New(s.logger, backup.PathsFromConfig(s.config), kvDB, searchDB, s.collections)
+	s.stats = stats.New(s.logger, kvDB, s.collections)
+
 	s.validator, err = validation.New(s.logger)
 	if err != nil {
 		s.logger.Error("error creating validator", "err", err.Error())
@@ -126,6 +141,9 @@ func (s *server) closeDependencies() {
 	if err := s.metadataStore.Close(); err != nil {
 		s.logger.Error("error closing kvDB", "err", err.Error())
 	}
+	if err := s.collections.Close(); err != nil {
+		s.logger.Error("error closing collections", "err", err.Error())
+	}
 	if err := s.indexer.Close(); err != nil {
 		s.logger.Error("error closing searchDB", "err", err.Error())
 	}
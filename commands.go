@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/meghashyamc/wheresthat/config"
+	"github.com/meghashyamc/wheresthat/db/kvdb"
+	"github.com/meghashyamc/wheresthat/db/searchdb"
+	"github.com/meghashyamc/wheresthat/logger"
+	"github.com/meghashyamc/wheresthat/services/backup"
+	"github.com/meghashyamc/wheresthat/services/collection"
+	"github.com/meghashyamc/wheresthat/services/index"
+)
+
+const usage = `usage:
+  wheresthat                  start the server
+  wheresthat restore ARCHIVE  restore a backup archive written by POST /admin/backup
+  wheresthat fsck [--repair]  check indexes against the metadata of indexed files and the file system
+
+Commands must be run while the server is stopped.`
+
+var (
+	errInconsistent = errors.New("indexes are inconsistent, run with --repair to repair them")
+	// Commands work on the metadata on disk, which there is none of if it is kept in memory
+	errMemoryBackend = errors.New("metadata is kept in memory by the server, so commands can not work on it")
+)
+
+// runCommand runs a command given on the command line instead of the server
+func runCommand(cfg *config.Config, args []string) error {
+	if cfg.GetKVDBBackend() == kvdb.BackendMemory {
+		return errMemoryBackend
+	}
+
+	switch args[0] {
+	case "restore":
+		if len(args) != 2 {
+			return fmt.Errorf("restore needs the path of a backup archive\n%s", usage)
+		}
+		return restore(cfg, args[1])
+	case "fsck":
+		flags := flag.NewFlagSet("fsck", flag.ContinueOnError)
+		repair := flags.Bool("repair", false, "index again or remove the files that were found to be inconsistent")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		return fsck(cfg, *repair)
+	default:
+		return fmt.Errorf("unknown command %s\n%s", args[0], usage)
+	}
+}
+
+// restore restores a backup archive. The metadata store is held open meanwhile, which fails if the server is
+// running and keeps it from being started until the restore is done.
+func restore(cfg *config.Config, archivePath string) error {
+	logger := logger.New()
+
+	kvDB, err := kvdb.New(logger, cfg)
+	if err != nil {
+		return fmt.Errorf("could not open metadata store, the server must be stopped to restore a backup: %w", err)
+	}
+	defer kvDB.Close()
+
+	manifest, err := backup.Restore(logger, backup.PathsFromConfig(cfg), archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to restore backup: %w", err)
+	}
+
+	fmt.Printf("restored backup created at %s with %d collections\n", manifest.CreatedAt.Format("2006-01-02 15:04:05 MST"), len(manifest.Collections))
+
+	return nil
+}
+
+// fsck checks the index of every collection against the metadata of its indexed files and the file system,
+// printing what it finds and repairing it if repair is true
+func fsck(cfg *config.Config, repair bool) error {
+	logger := logger.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	kvDB, err := kvdb.New(logger, cfg)
+	if err != nil {
+		return fmt.Errorf("could not open metadata store, the server must be stopped to check indexes: %w", err)
+	}
+	defer kvDB.Close()
+
+	searchDB, err := searchdb.New(logger, cfg)
+	if err != nil {
+		return fmt.Errorf("could not open index: %w", err)
+	}
+	defer searchDB.Close()
+
+	collections, err := collection.New(logger, searchDB, kvDB)
+	if err != nil {
+		return fmt.Errorf("could not open collections: %w", err)
+	}
+	defer collections.Close()
+
+	// Indexing is not started, so that indexes built with an older schema are reported instead of rebuilt
+	report, err := index.NewChecker(logger, searchdb.DefaultCollection, kvdb.FilesBucket, searchDB, kvDB).Check(ctx, repair)
+	if err != nil {
+		return fmt.Errorf("failed to check index: %w", err)
+	}
+	reports := []*index.CheckReport{report}
+
+	collectionReports, err := collections.Check(ctx, repair)
+	reports = append(reports, collectionReports...)
+	for _, report := range reports {
+		printCheckReport(report)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, report := range reports {
+		if len(report.Unrepaired) > 0 || (!repair && !report.Consistent()) {
+			if repair {
+				return errors.New("some files could not be repaired")
+			}
+			return errInconsistent
+		}
+	}
+
+	return nil
+}
+
+func printCheckReport(report *index.CheckReport) {
+	fmt.Printf("collection %s: %d documents, %d indexed files\n", report.Collection, report.Documents, report.Entries)
+
+	sections := []struct {
+		label string
+		paths []string
+	}{
+		{"orphan (in the index but not in the metadata)", report.Orphans},
+		{"missing (in the metadata but not in the index)", report.Missing},
+		{"stale (in the metadata but no longer on disk)", report.Stale},
+		{"indexed again", report.Reindexed},
+		{"removed", report.Removed},
+		{"could not be repaired", report.Unrepaired},
+	}
+	for _, section := range sections {
+		for _, path := range section.paths {
+			fmt.Printf("  %s: %s\n", section.label, path)
+		}
+	}
+	if report.Outdated {
+		fmt.Println("  built with an older schema, it is rebuilt when the server starts")
+	}
+	if report.Consistent() {
+		fmt.Println("  ok")
+	}
+}
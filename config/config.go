@@ -5,6 +5,8 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -12,6 +14,60 @@ import (
 const keyEnv = "ENV"
 const envLocal = "local"
 
+// DefaultRankingProfileName is the name of the ranking profile used when no other one is configured
+const DefaultRankingProfileName = "default"
+
+// RankingProfile holds the boosts used to rank keyword search results. Boosts of the query clauses weigh
+// where a term matched, while the function-score style boosts scale the score of each result.
+type RankingProfile struct {
+	Content       float64 `mapstructure:"content"`
+	FileName      float64 `mapstructure:"file_name"`
+	Path          float64 `mapstructure:"path"`
+	QuotedPhrase  float64 `mapstructure:"quoted_phrase"`
+	RegularPhrase float64 `mapstructure:"regular_phrase"`
+	PartialMatch  float64 `mapstructure:"partial_match"`
+	FuzzyContent  float64 `mapstructure:"fuzzy_content"`
+	FuzzyFileName float64 `mapstructure:"fuzzy_file_name"`
+	// Stemmed multiplies the content boost for matches of other forms of query terms, like running for run
+	Stemmed float64 `mapstructure:"stemmed"`
+	// Synonym multiplies the boosts of matches of synonyms of query terms
+	Synonym float64 `mapstructure:"synonym"`
+
+	// ExactFileName multiplies the score of files whose name, with or without its extension, is the query
+	ExactFileName float64 `mapstructure:"exact_file_name"`
+	// Recency is how much the score of a file modified just now is increased by, as a fraction. The increase
+	// halves every RecencyHalfLife since the file was modified.
+	Recency         float64       `mapstructure:"recency"`
+	RecencyHalfLife time.Duration `mapstructure:"recency_half_life"`
+	// DepthPenalty is the fraction of the score taken off for each directory between a file and its root,
+	// up to MaxDepthPenalty
+	DepthPenalty    float64 `mapstructure:"depth_penalty"`
+	MaxDepthPenalty float64 `mapstructure:"max_depth_penalty"`
+	// VendorPenalty is the fraction of the score taken off for files under any of VendorDirs
+	VendorPenalty float64  `mapstructure:"vendor_penalty"`
+	VendorDirs    []string `mapstructure:"vendor_dirs"`
+}
+
+// defaultRankingProfile is the profile that configured profiles start from, so that they only need to set
+// the boosts they change. It has none of the function-score style boosts, so results are ranked by the query
+// clauses alone unless a profile turns some of them on.
+var defaultRankingProfile = RankingProfile{
+	Content:       3.0,
+	FileName:      2.0,
+	Path:          1.0,
+	QuotedPhrase:  6.0,
+	RegularPhrase: 5.0,
+	PartialMatch:  1.5,
+	// Fuzzy matches score lower than exact and partial matches
+	FuzzyContent:    1.0,
+	FuzzyFileName:   0.75,
+	Stemmed:         0.8,
+	Synonym:         0.6,
+	RecencyHalfLife: 30 * 24 * time.Hour,
+	MaxDepthPenalty: 0.2,
+	VendorDirs:      []string{"vendor", "node_modules", "third_party", ".git"},
+}
+
 type Config struct {
 	config *viper.Viper
 }
@@ -34,6 +90,7 @@ func Load(env string) (*Config, error) {
 		}
 	}
 	viperConfig.AutomaticEnv()
+	setDefaults(viperConfig)
 
 	cfg := &Config{
 		config: viperConfig,
@@ -60,6 +117,16 @@ func (c *Config) GetKVDBPath() string {
 	return kvdbPath
 }
 
+// GetKVDBBackend returns where metadata is kept, which is "bolt" for a file or "memory" for memory only
+func (c *Config) GetKVDBBackend() string {
+	backend := c.config.GetString("KVDB_BACKEND")
+	if len(backend) == 0 {
+		backend = c.config.GetString("database.kvdb_backend")
+	}
+
+	return backend
+}
+
 func (c *Config) GetIndexPath() string {
 	indexPath := c.config.GetString("INDEX_PATH")
 	if len(indexPath) == 0 {
@@ -78,6 +145,229 @@ func (c *Config) GetStoragePath() string {
 	return storagePath
 }
 
+// GetCollectionsPath returns the directory the indexes of collections other than the default one are kept in,
+// within the storage path
+func (c *Config) GetCollectionsPath() string {
+	collectionsPath := c.config.GetString("COLLECTIONS_PATH")
+	if len(collectionsPath) == 0 {
+		collectionsPath = c.config.GetString("database.collections_path")
+	}
+
+	return collectionsPath
+}
+
+// GetBackupsPath returns the directory backup archives are written to, within the storage path
+func (c *Config) GetBackupsPath() string {
+	backupsPath := c.config.GetString("BACKUPS_PATH")
+	if len(backupsPath) == 0 {
+		backupsPath = c.config.GetString("database.backups_path")
+	}
+
+	return backupsPath
+}
+
+// GetSynonymsPath returns the path of the synonyms file, within the storage path
+func (c *Config) GetSynonymsPath() string {
+	synonymsPath := c.config.GetString("SYNONYMS_PATH")
+	if len(synonymsPath) == 0 {
+		synonymsPath = c.config.GetString("search.synonyms.path")
+	}
+
+	return synonymsPath
+}
+
+// GetFuzzyMinTermLengthForOneEdit returns the length from which query terms also match terms one edit away
+func (c *Config) GetFuzzyMinTermLengthForOneEdit() int {
+	minLength := c.config.GetInt("FUZZY_MIN_TERM_LENGTH_ONE_EDIT")
+	if minLength == 0 {
+		minLength = c.config.GetInt("search.fuzzy.min_term_length_one_edit")
+	}
+
+	return minLength
+}
+
+// GetFuzzyMinTermLengthForTwoEdits returns the length from which query terms also match terms two edits away
+func (c *Config) GetFuzzyMinTermLengthForTwoEdits() int {
+	minLength := c.config.GetInt("FUZZY_MIN_TERM_LENGTH_TWO_EDITS")
+	if minLength == 0 {
+		minLength = c.config.GetInt("search.fuzzy.min_term_length_two_edits")
+	}
+
+	return minLength
+}
+
+// IsSemanticSearchEnabled returns true if document passages should be embedded for semantic search
+func (c *Config) IsSemanticSearchEnabled() bool {
+	if c.config.IsSet("SEMANTIC_SEARCH_ENABLED") {
+		return c.config.GetBool("SEMANTIC_SEARCH_ENABLED")
+	}
+
+	return c.config.GetBool("search.semantic.enabled")
+}
+
+// IsContentStoreEnabled returns true if the extracted content of indexed files is stored, compressed, so that
+// snippets are taken from what was indexed rather than from the files
+func (c *Config) IsContentStoreEnabled() bool {
+	if c.config.IsSet("CONTENT_STORE_ENABLED") {
+		return c.config.GetBool("CONTENT_STORE_ENABLED")
+	}
+
+	return c.config.GetBool("search.content_store.enabled")
+}
+
+// GetContentStoreCompression returns how stored content is compressed, which is "zstd" or "snappy"
+func (c *Config) GetContentStoreCompression() string {
+	compression := c.config.GetString("CONTENT_STORE_COMPRESSION")
+	if len(compression) == 0 {
+		compression = c.config.GetString("search.content_store.compression")
+	}
+
+	return compression
+}
+
+// GetContentStoreMaxBytesPerRoot returns how many bytes of compressed content are stored for the files of each
+// indexed root, or 0 for no limit
+func (c *Config) GetContentStoreMaxBytesPerRoot() int64 {
+	maxBytes := c.config.GetInt64("CONTENT_STORE_MAX_BYTES_PER_ROOT")
+	if maxBytes == 0 {
+		maxBytes = c.config.GetInt64("search.content_store.max_bytes_per_root")
+	}
+
+	return maxBytes
+}
+
+// GetGrepMaxCandidates returns the maximum number of files scanned by a regex or literal search
+func (c *Config) GetGrepMaxCandidates() int {
+	maxCandidates := c.config.GetInt("GREP_MAX_CANDIDATES")
+	if maxCandidates == 0 {
+		maxCandidates = c.config.GetInt("search.grep.max_candidates")
+	}
+
+	return maxCandidates
+}
+
+// GetGrepTimeout returns how long a regex or literal search may spend scanning files
+func (c *Config) GetGrepTimeout() time.Duration {
+	timeout := c.config.GetDuration("GREP_TIMEOUT")
+	if timeout == 0 {
+		timeout = c.config.GetDuration("search.grep.timeout")
+	}
+
+	return timeout
+}
+
+// GetRankingProfiles returns the ranking profiles by name. There is always a profile named
+// DefaultRankingProfileName, which uses the built-in boosts unless it is configured.
+func (c *Config) GetRankingProfiles() (map[string]RankingProfile, error) {
+	profiles := map[string]RankingProfile{DefaultRankingProfileName: defaultRankingProfile}
+
+	for name := range c.config.GetStringMap("search.ranking.profiles") {
+		profile := defaultRankingProfile
+		profile.VendorDirs = slices.Clone(defaultRankingProfile.VendorDirs)
+		if err := c.config.UnmarshalKey("search.ranking.profiles."+name, &profile); err != nil {
+			return nil, fmt.Errorf("could not read ranking profile %s: %w", name, err)
+		}
+		profiles[name] = profile
+	}
+
+	return profiles, nil
+}
+
+// GetDefaultRankingProfile returns the name of the ranking profile used by searches that do not pick one
+func (c *Config) GetDefaultRankingProfile() string {
+	profile := c.config.GetString("RANKING_PROFILE")
+	if len(profile) == 0 {
+		profile = c.config.GetString("search.ranking.default_profile")
+	}
+
+	return profile
+}
+
+// GetWebhookMaxAttempts returns how many times delivering an event to a webhook is attempted
+func (c *Config) GetWebhookMaxAttempts() int {
+	maxAttempts := c.config.GetInt("WEBHOOK_MAX_ATTEMPTS")
+	if maxAttempts == 0 {
+		maxAttempts = c.config.GetInt("webhooks.max_attempts")
+	}
+
+	return maxAttempts
+}
+
+// GetWebhookInitialBackoff returns how long to wait before retrying a failed delivery to a webhook for the
+// first time. The wait doubles with each retry.
+func (c *Config) GetWebhookInitialBackoff() time.Duration {
+	backoff := c.config.GetDuration("WEBHOOK_INITIAL_BACKOFF")
+	if backoff == 0 {
+		backoff = c.config.GetDuration("webhooks.initial_backoff")
+	}
+
+	return backoff
+}
+
+// GetWebhookTimeout returns how long a webhook has to respond to each delivery attempt
+func (c *Config) GetWebhookTimeout() time.Duration {
+	timeout := c.config.GetDuration("WEBHOOK_TIMEOUT")
+	if timeout == 0 {
+		timeout = c.config.GetDuration("webhooks.timeout")
+	}
+
+	return timeout
+}
+
+// GetRequestsMaxAge returns how long after they finish the statuses of index requests are kept, or 0 to keep
+// them however old they are
+func (c *Config) GetRequestsMaxAge() time.Duration {
+	maxAge := c.config.GetDuration("REQUESTS_MAX_AGE")
+	if maxAge == 0 {
+		maxAge = c.config.GetDuration("index.requests.max_age")
+	}
+
+	return maxAge
+}
+
+// GetRequestsMaxCount returns how many statuses of finished index requests are kept, or 0 to keep any number
+func (c *Config) GetRequestsMaxCount() int {
+	maxCount := c.config.GetInt("REQUESTS_MAX_COUNT")
+	if maxCount == 0 {
+		maxCount = c.config.GetInt("index.requests.max_count")
+	}
+
+	return maxCount
+}
+
+// GetRequestsJanitorInterval returns how often statuses of index requests past their retention are removed
+func (c *Config) GetRequestsJanitorInterval() time.Duration {
+	interval := c.config.GetDuration("REQUESTS_JANITOR_INTERVAL")
+	if interval == 0 {
+		interval = c.config.GetDuration("index.requests.janitor_interval")
+	}
+
+	return interval
+}
+
+// setDefaults sets values for settings that may be left out of config files
+func setDefaults(viperConfig *viper.Viper) {
+	viperConfig.SetDefault("database.kvdb_backend", "bolt")
+	viperConfig.SetDefault("database.collections_path", "/collections")
+	viperConfig.SetDefault("database.backups_path", "/backups")
+	viperConfig.SetDefault("search.fuzzy.min_term_length_one_edit", 5)
+	viperConfig.SetDefault("search.fuzzy.min_term_length_two_edits", 8)
+	viperConfig.SetDefault("search.semantic.enabled", false)
+	viperConfig.SetDefault("search.content_store.enabled", false)
+	viperConfig.SetDefault("search.content_store.compression", "zstd")
+	viperConfig.SetDefault("search.content_store.max_bytes_per_root", 256<<20)
+	viperConfig.SetDefault("search.grep.max_candidates", 1000)
+	viperConfig.SetDefault("search.grep.timeout", "5s")
+	viperConfig.SetDefault("search.ranking.default_profile", DefaultRankingProfileName)
+	viperConfig.SetDefault("search.synonyms.path", "/synonyms.txt")
+	viperConfig.SetDefault("index.requests.max_age", "720h")
+	viperConfig.SetDefault("index.requests.max_count", 1000)
+	viperConfig.SetDefault("index.requests.janitor_interval", "1h")
+	viperConfig.SetDefault("webhooks.max_attempts", 5)
+	viperConfig.SetDefault("webhooks.initial_backoff", "1s")
+	viperConfig.SetDefault("webhooks.timeout", "10s")
+}
+
 func getProjectRoot() (string, error) {
 	currentDir, err := os.Getwd()
 	if err != nil {
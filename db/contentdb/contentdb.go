@@ -0,0 +1,311 @@
+package contentdb
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/meghashyamc/wheresthat/logger"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Compression algorithms that content can be stored with
+const (
+	CompressionSnappy = "snappy"
+	CompressionZstd   = "zstd"
+)
+
+// Each stored value starts with a byte telling how the rest of it is compressed, so that content stored before
+// the compression setting changed can still be read
+const (
+	codecSnappy byte = 1
+	codecZstd   byte = 2
+)
+
+var (
+	contentsBucket = []byte("contents")
+	// rootsBucket holds the root each document was indexed under, which its content counts against
+	rootsBucket = []byte("roots")
+)
+
+var ErrUnknownCompression = errors.New("unknown compression")
+
+type Options struct {
+	// Compression is CompressionSnappy or CompressionZstd
+	Compression string
+	// MaxBytesPerRoot is how many bytes of compressed content are stored for the documents of each root, or 0
+	// for no limit. Documents that do not fit are not stored.
+	MaxBytesPerRoot int64
+}
+
+// Entry is the extracted content of a document, to be stored under its ID
+type Entry struct {
+	ID      string
+	Root    string
+	Content string
+}
+
+// ContentDB stores the extracted content of documents compressed in a bbolt file, so that it can be read back
+// without the files the documents came from
+type ContentDB struct {
+	store   *bolt.DB
+	logger  logger.Logger
+	options Options
+	codec   byte
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+
+	// mu is held while content is written, and guards usage
+	mu sync.Mutex
+	// usage is the number of bytes stored for the documents of each root
+	usage map[string]int64
+}
+
+// New opens the content database at path, creating it if it does not exist yet
+func New(logger logger.Logger, path string, options Options) (*ContentDB, error) {
+	var codec byte
+	switch options.Compression {
+	case CompressionSnappy:
+		codec = codecSnappy
+	case CompressionZstd:
+		codec = codecZstd
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCompression, options.Compression)
+	}
+
+	store, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		logger.Error("could not open content database", "path", path, "err", err.Error())
+		return nil, err
+	}
+
+	// The encoder and decoder are only used through EncodeAll and DecodeAll, which may be called concurrently
+	encoder, _ := zstd.NewWriter(nil)
+	decoder, _ := zstd.NewReader(nil)
+	c := &ContentDB{
+		store:   store,
+		logger:  logger,
+		options: options,
+		codec:   codec,
+		encoder: encoder,
+		decoder: decoder,
+		usage:   map[string]int64{},
+	}
+
+	err = store.Update(func(tx *bolt.Tx) error {
+		contents, err := tx.CreateBucketIfNotExists(contentsBucket)
+		if err != nil {
+			return err
+		}
+		roots, err := tx.CreateBucketIfNotExists(rootsBucket)
+		if err != nil {
+			return err
+		}
+
+		return roots.ForEach(func(id, root []byte) error {
+			c.usage[string(root)] += int64(len(contents.Get(id)))
+			return nil
+		})
+	})
+	if err != nil {
+		logger.Error("could not read content database", "path", path, "err", err.Error())
+		c.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Put stores the content of documents, replacing any stored before under the same IDs. Documents whose root
+// would go over its budget are not stored, and any content stored for them before is removed since it is out
+// of date. It returns the number of documents that were stored.
+func (c *ContentDB) Put(entries []Entry) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	usage := maps.Clone(c.usage)
+	stored := 0
+	err := c.store.Update(func(tx *bolt.Tx) error {
+		contents, roots := tx.Bucket(contentsBucket), tx.Bucket(rootsBucket)
+
+		for _, entry := range entries {
+			id := []byte(entry.ID)
+			if oldRoot := roots.Get(id); oldRoot != nil {
+				usage[string(oldRoot)] -= int64(len(contents.Get(id)))
+			}
+
+			value := c.compress(entry.Content)
+			if c.options.MaxBytesPerRoot > 0 && usage[entry.Root]+int64(len(value)) > c.options.MaxBytesPerRoot {
+				if err := contents.Delete(id); err != nil {
+					return err
+				}
+				if err := roots.Delete(id); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := contents.Put(id, value); err != nil {
+				return err
+			}
+			if err := roots.Put(id, []byte(entry.Root)); err != nil {
+				return err
+			}
+			usage[entry.Root] += int64(len(value))
+			stored++
+		}
+
+		return nil
+	})
+	if err != nil {
+		c.logger.Error("could not store content", "err", err.Error())
+		return 0, err
+	}
+	c.usage = usage
+
+	if skipped := len(entries) - stored; skipped > 0 {
+		c.logger.Warn("content of some documents was not stored since their root is over its budget", "skipped", skipped, "max_bytes_per_root", c.options.MaxBytesPerRoot)
+	}
+
+	return stored, nil
+}
+
+// Get returns the content stored for a document, and false if none is
+func (c *ContentDB) Get(id string) (string, bool, error) {
+	var value []byte
+	err := c.store.View(func(tx *bolt.Tx) error {
+		if stored := tx.Bucket(contentsBucket).Get([]byte(id)); stored != nil {
+			value = append([]byte{}, stored...)
+		}
+		return nil
+	})
+	if err != nil || value == nil {
+		return "", false, err
+	}
+
+	content, err := c.decompress(value)
+	if err != nil {
+		c.logger.Error("could not decompress content", "id", id, "err", err.Error())
+		return "", false, err
+	}
+
+	return content, true, nil
+}
+
+// Delete removes the content stored for documents, if any
+func (c *ContentDB) Delete(ids []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	freed := map[string]int64{}
+	err := c.store.Update(func(tx *bolt.Tx) error {
+		contents, roots := tx.Bucket(contentsBucket), tx.Bucket(rootsBucket)
+
+		for _, id := range ids {
+			root := roots.Get([]byte(id))
+			if root == nil {
+				continue
+			}
+			freed[string(root)] += int64(len(contents.Get([]byte(id))))
+
+			if err := contents.Delete([]byte(id)); err != nil {
+				return err
+			}
+			if err := roots.Delete([]byte(id)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		c.logger.Error("could not delete content", "err", err.Error())
+		return err
+	}
+
+	for root, size := range freed {
+		c.usage[root] -= size
+	}
+
+	return nil
+}
+
+// Usage returns the number of bytes of compressed content stored for the documents of each root
+func (c *ContentDB) Usage() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	usage := make(map[string]int64, len(c.usage))
+	for root, size := range c.usage {
+		if size > 0 {
+			usage[root] = size
+		}
+	}
+
+	return usage
+}
+
+// Snapshot writes a consistent copy of the content database to path
+func (c *ContentDB) Snapshot(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		c.logger.Error("could not create content database snapshot", "path", path, "err", err.Error())
+		return err
+	}
+
+	err = c.store.View(func(tx *bolt.Tx) error {
+		_, err := tx.WriteTo(file)
+		return err
+	})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		c.logger.Error("could not write content database snapshot", "path", path, "err", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (c *ContentDB) Close() error {
+	c.decoder.Close()
+	c.encoder.Close()
+
+	return c.store.Close()
+}
+
+func (c *ContentDB) compress(content string) []byte {
+	if c.codec == codecSnappy {
+		return append([]byte{codecSnappy}, snappy.Encode(nil, []byte(content))...)
+	}
+
+	return c.encoder.EncodeAll([]byte(content), []byte{codecZstd})
+}
+
+func (c *ContentDB) decompress(value []byte) (string, error) {
+	if len(value) == 0 {
+		return "", errors.New("stored content is empty")
+	}
+
+	var content []byte
+	var err error
+	switch value[0] {
+	case codecSnappy:
+		content, err = snappy.Decode(nil, value[1:])
+	case codecZstd:
+		content, err = c.decoder.DecodeAll(value[1:], nil)
+	default:
+		err = fmt.Errorf("%w: codec %d", ErrUnknownCompression, value[0])
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
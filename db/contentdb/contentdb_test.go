@@ -0,0 +1,127 @@
+package contentdb
+
+import (
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestContentDB(t *testing.T, path string, options Options) *ContentDB {
+	c, err := New(slog.New(slog.NewTextHandler(os.Stderr, nil)), path, options)
+	require.NoError(t, err)
+	t.Cleanup(func() { c.Close() })
+
+	return c
+}
+
+func TestPutGet(t *testing.T) {
+	for _, compression := range []string{CompressionSnappy, CompressionZstd} {
+		t.Run(compression, func(t *testing.T) {
+			assert := require.New(t)
+			c := newTestContentDB(t, filepath.Join(t.TempDir(), "content.db"), Options{Compression: compression})
+
+			content := strings.Repeat("the cache is evicted when it is full. ", 100)
+			stored, err := c.Put([]Entry{{ID: "/notes/cache.md", Root: "/notes", Content: content}, {ID: "/notes/empty.md", Root: "/notes"}})
+			assert.NoError(err)
+			assert.Equal(2, stored)
+
+			got, ok, err := c.Get("/notes/cache.md")
+			assert.NoError(err)
+			assert.True(ok)
+			assert.Equal(content, got)
+			assert.Less(c.Usage()["/notes"], int64(len(content)), "content should be stored compressed")
+
+			got, ok, err = c.Get("/notes/empty.md")
+			assert.NoError(err)
+			assert.True(ok, "empty content should be stored")
+			assert.Empty(got)
+
+			_, ok, err = c.Get("/notes/missing.md")
+			assert.NoError(err)
+			assert.False(ok)
+		})
+	}
+}
+
+// randomText returns text that does not compress, so that its stored size is known
+func randomText(r *rand.Rand, length int) string {
+	text := make([]byte, length)
+	for i := range text {
+		text[i] = byte('a' + r.IntN(26))
+	}
+
+	return string(text)
+}
+
+func TestBudget(t *testing.T) {
+	assert := require.New(t)
+	r := rand.New(rand.NewPCG(1, 2))
+	c := newTestContentDB(t, filepath.Join(t.TempDir(), "content.db"), Options{Compression: CompressionSnappy, MaxBytesPerRoot: 100})
+
+	stored, err := c.Put([]Entry{
+		{ID: "/notes/a.md", Root: "/notes", Content: randomText(r, 60)},
+		{ID: "/notes/b.md", Root: "/notes", Content: randomText(r, 60)},
+		{ID: "/code/main.go", Root: "/code", Content: randomText(r, 60)},
+	})
+	assert.NoError(err)
+	assert.Equal(2, stored, "content that does not fit in the budget of its root should not be stored")
+	_, ok, err := c.Get("/notes/b.md")
+	assert.NoError(err)
+	assert.False(ok)
+	_, ok, err = c.Get("/code/main.go")
+	assert.NoError(err)
+	assert.True(ok, "each root should have a budget of its own")
+
+	// Replacing content frees what was stored for it before
+	stored, err = c.Put([]Entry{{ID: "/notes/a.md", Root: "/notes", Content: randomText(r, 90)}})
+	assert.NoError(err)
+	assert.Equal(1, stored)
+	assert.LessOrEqual(c.Usage()["/notes"], int64(100))
+
+	// Content that no longer fits is removed rather than left out of date
+	stored, err = c.Put([]Entry{{ID: "/notes/a.md", Root: "/notes", Content: randomText(r, 200)}})
+	assert.NoError(err)
+	assert.Zero(stored)
+	_, ok, err = c.Get("/notes/a.md")
+	assert.NoError(err)
+	assert.False(ok)
+	assert.NotContains(c.Usage(), "/notes")
+
+	assert.NoError(c.Delete([]string{"/code/main.go", "/code/missing.go"}))
+	assert.Empty(c.Usage())
+}
+
+func TestReopen(t *testing.T) {
+	assert := require.New(t)
+	path := filepath.Join(t.TempDir(), "content.db")
+
+	c, err := New(slog.New(slog.NewTextHandler(os.Stderr, nil)), path, Options{Compression: CompressionSnappy})
+	assert.NoError(err)
+	_, err = c.Put([]Entry{{ID: "/notes/cache.md", Root: "/notes", Content: "cache eviction"}})
+	assert.NoError(err)
+	usage := c.Usage()
+	assert.NoError(c.Close())
+
+	c = newTestContentDB(t, path, Options{Compression: CompressionZstd})
+	assert.Equal(usage, c.Usage(), "usage should be computed again when the database is opened")
+	content, ok, err := c.Get("/notes/cache.md")
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal("cache eviction", content, "content stored with another compression should still be read")
+
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.db")
+	assert.NoError(c.Snapshot(snapshotPath))
+	snapshot := newTestContentDB(t, snapshotPath, Options{Compression: CompressionZstd})
+	content, ok, err = snapshot.Get("/notes/cache.md")
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal("cache eviction", content)
+
+	_, err = New(slog.New(slog.NewTextHandler(os.Stderr, nil)), filepath.Join(t.TempDir(), "other.db"), Options{Compression: "gzip"})
+	assert.ErrorIs(err, ErrUnknownCompression)
+}
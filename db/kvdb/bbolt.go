@@ -1,14 +1,17 @@
 package kvdb
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"time"
 
 	"github.com/meghashyamc/wheresthat/config"
 	"github.com/meghashyamc/wheresthat/logger"
 	bolt "go.etcd.io/bbolt"
+	bolterrors "go.etcd.io/bbolt/errors"
 )
 
 type BoltDB struct {
@@ -20,9 +23,29 @@ const (
 	BoltDefaultBucket = "default"
 	RequestsBucket    = "requests"
 	FilesBucket       = "files"
-	lastIndexTimeKey  = "__last_index_time__"
+	// SavedSearchesBucket holds saved searches by ID, along with their matches and changes to them
+	SavedSearchesBucket = "saved_searches"
+	// WebhooksBucket holds webhooks by ID, along with their latest deliveries
+	WebhooksBucket = "webhooks"
+	// CollectionsBucket holds the settings of collections other than the default one by name. Files indexed
+	// into a collection are kept in a bucket of their own, created along with the collection.
+	CollectionsBucket = "collections"
+	// StatsBucket holds the outcomes of index requests into each collection by collection name
+	StatsBucket      = "stats"
+	lastIndexTimeKey = "__last_index_time__"
+	// schemaVersionKeyPrefix is followed by the name of a collection in the keys of BoltDefaultBucket holding
+	// the schema version of its index
+	schemaVersionKeyPrefix = "__schema_version__/"
 )
 
+// initialBuckets are created when a store is opened, if they do not exist yet
+var initialBuckets = []string{BoltDefaultBucket, RequestsBucket, FilesBucket, SavedSearchesBucket, WebhooksBucket, CollectionsBucket, StatsBucket}
+
+// SchemaVersionKey returns the key in BoltDefaultBucket holding the schema version of the index of a collection
+func SchemaVersionKey(collection string) string {
+	return schemaVersionKeyPrefix + collection
+}
+
 func New(logger logger.Logger, cfg *config.Config) (*BoltDB, error) {
 	kvDBPath := filepath.Join(cfg.GetStoragePath(), cfg.GetKVDBPath())
 	if err := os.MkdirAll(filepath.Dir(kvDBPath), 0755); err != nil {
@@ -52,15 +75,10 @@ func New(logger logger.Logger, cfg *config.Config) (*BoltDB, error) {
 }
 
 func (b *BoltDB) initBuckets() error {
-
-	if err := b.initBucket(BoltDefaultBucket); err != nil {
-		return err
-	}
-	if err := b.initBucket(RequestsBucket); err != nil {
-		return err
-	}
-	if err := b.initBucket(FilesBucket); err != nil {
-		return err
+	for _, bucketName := range initialBuckets {
+		if err := b.initBucket(bucketName); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -76,6 +94,23 @@ func (b *BoltDB) initBucket(bucketName string) error {
 	})
 }
 
+// CreateBucket creates a bucket if it does not exist yet
+func (b *BoltDB) CreateBucket(bucketName string) error {
+	return b.initBucket(bucketName)
+}
+
+// DeleteBucket deletes a bucket along with all its keys, if it exists
+func (b *BoltDB) DeleteBucket(bucketName string) error {
+	return b.store.Update(func(tx *bolt.Tx) error {
+		err := tx.DeleteBucket([]byte(bucketName))
+		if err != nil && !errors.Is(err, bolterrors.ErrBucketNotFound) {
+			b.logger.Error("failed to delete bucket", "bucket", bucketName, "err", err.Error())
+			return fmt.Errorf("failed to delete bucket: %w", err)
+		}
+		return nil
+	})
+}
+
 func (b *BoltDB) Set(bucketName string, key string, value string) error {
 	if err := b.validateKey(key); err != nil {
 		return err
@@ -99,6 +134,41 @@ func (b *BoltDB) Set(bucketName string, key string, value string) error {
 	})
 }
 
+// SetMany sets several keys in a single transaction, so that either all of them are set or none are
+func (b *BoltDB) SetMany(bucketName string, entries map[string]string) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		if err := b.validateKey(key); err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+	// Keys put in order fill pages sequentially instead of splitting them
+	slices.Sort(keys)
+
+	if bucketName == "" {
+		bucketName = BoltDefaultBucket
+	}
+	return b.store.Update(func(tx *bolt.Tx) error {
+		bucket, err := b.getBucket(tx, bucketName)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			if err := bucket.Put([]byte(key), []byte(entries[key])); err != nil {
+				b.logger.Error("failed to set key", "key", key, "err", err.Error())
+				return fmt.Errorf("failed to set key %s: %w", key, err)
+			}
+		}
+
+		return nil
+	})
+}
+
 func (b *BoltDB) Get(bucketName string, key string) (string, error) {
 	if err := b.validateKey(key); err != nil {
 		return "", err
@@ -135,6 +205,41 @@ func (b *BoltDB) Get(bucketName string, key string) (string, error) {
 	return string(value), nil
 }
 
+// Snapshot writes a copy of the database to path. The copy is consistent, since it is written within a single
+// read transaction, while the database goes on being read and written.
+func (b *BoltDB) Snapshot(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		b.logger.Error("failed to create database snapshot", "path", path, "err", err.Error())
+		return fmt.Errorf("failed to create database snapshot: %w", err)
+	}
+
+	err = b.store.View(func(tx *bolt.Tx) error {
+		_, err := tx.WriteTo(file)
+		return err
+	})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		b.logger.Error("failed to write database snapshot", "path", path, "err", err.Error())
+		return fmt.Errorf("failed to write database snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// Size returns the size of the database file
+func (b *BoltDB) Size() (int64, error) {
+	info, err := os.Stat(b.store.Path())
+	if err != nil {
+		b.logger.Error("failed to get size of database", "path", b.store.Path(), "err", err.Error())
+		return 0, fmt.Errorf("failed to get size of database: %w", err)
+	}
+
+	return info.Size(), nil
+}
+
 func (b *BoltDB) Close() error {
 	if b.store != nil {
 		return b.store.Close()
@@ -166,6 +271,65 @@ func (b *BoltDB) Delete(bucketName string, key string) error {
 	})
 }
 
+// DeleteMany deletes several keys in a single transaction. Keys that are not set are ignored.
+func (b *BoltDB) DeleteMany(bucketName string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	for _, key := range keys {
+		if err := b.validateKey(key); err != nil {
+			return err
+		}
+	}
+
+	if bucketName == "" {
+		bucketName = BoltDefaultBucket
+	}
+	return b.store.Update(func(tx *bolt.Tx) error {
+		bucket, err := b.getBucket(tx, bucketName)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			if err := bucket.Delete([]byte(key)); err != nil {
+				b.logger.Error("failed to delete key", "key", key, "err", err.Error())
+				return fmt.Errorf("failed to delete key %s: %w", key, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// GetAll returns every key in a bucket along with its value, read in a single transaction
+func (b *BoltDB) GetAll(bucketName string) (map[string]string, error) {
+	entries := map[string]string{}
+
+	if bucketName == "" {
+		bucketName = BoltDefaultBucket
+	}
+
+	err := b.store.View(func(tx *bolt.Tx) error {
+		bucket, err := b.getBucket(tx, bucketName)
+		if err != nil {
+			return err
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			entries[string(k)] = string(v)
+			return nil
+		})
+	})
+
+	if err != nil {
+		b.logger.Error("failed to get all entries", "err", err.Error())
+		return nil, fmt.Errorf("failed to get all entries: %w", err)
+	}
+
+	return entries, nil
+}
+
 func (b *BoltDB) GetAllKeys(bucketName string) ([]string, error) {
 	var keys []string
 
@@ -208,7 +372,7 @@ func (b *BoltDB) getBucket(tx *bolt.Tx, bucketName string) (*bolt.Bucket, error)
 	bucket := tx.Bucket([]byte(bucketName))
 	if bucket == nil {
 		b.logger.Error("bucket not found", "bucket", bucketName)
-		return nil, fmt.Errorf("bucket not found")
+		return nil, ErrBucketNotFound
 	}
 	return bucket, nil
 }
@@ -0,0 +1,67 @@
+package kvdb
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func newTestLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+}
+
+func newTestBoltDB(t testing.TB) *BoltDB {
+	return openTestBoltDB(t, filepath.Join(t.TempDir(), "metadata.db"))
+}
+
+func openTestBoltDB(t testing.TB, path string) *BoltDB {
+	store, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	require.NoError(t, err)
+
+	boltDB := &BoltDB{store: store, logger: newTestLogger()}
+	require.NoError(t, boltDB.initBuckets())
+	t.Cleanup(func() { boltDB.Close() })
+
+	return boltDB
+}
+
+// Each benchmark iteration records the metadata of this many indexed files
+const benchmarkBatchSize = 500
+
+func benchmarkEntries() map[string]string {
+	entries := make(map[string]string, benchmarkBatchSize)
+	for i := range benchmarkBatchSize {
+		entries[fmt.Sprintf("/files/dir%d/file%d.txt", i%20, i)] = `{"last_indexed":"2025-01-01T00:00:00Z"}`
+	}
+	return entries
+}
+
+func BenchmarkSet(b *testing.B) {
+	boltDB := newTestBoltDB(b)
+	entries := benchmarkEntries()
+
+	for b.Loop() {
+		for key, value := range entries {
+			if err := boltDB.Set(FilesBucket, key, value); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkSetMany(b *testing.B) {
+	boltDB := newTestBoltDB(b)
+	entries := benchmarkEntries()
+
+	for b.Loop() {
+		if err := boltDB.SetMany(FilesBucket, entries); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package kvdb
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoltDBConformance(t *testing.T) {
+	testStoreConformance(t, func(t *testing.T) Store { return newTestBoltDB(t) })
+}
+
+func TestMemoryDBConformance(t *testing.T) {
+	testStoreConformance(t, func(t *testing.T) Store { return NewMemoryDB(newTestLogger()) })
+}
+
+// testStoreConformance checks that a backend behaves the way every store must
+func testStoreConformance(t *testing.T, newStore func(t *testing.T) Store) {
+	t.Run("SetGetDelete", func(t *testing.T) {
+		assert := require.New(t)
+		store := newStore(t)
+
+		assert.NoError(store.Set(FilesBucket, "/a", "1"))
+		assert.NoError(store.Set(FilesBucket, "/a", "2"))
+		value, err := store.Get(FilesBucket, "/a")
+		assert.NoError(err)
+		assert.Equal("2", value)
+
+		_, err = store.Get(RequestsBucket, "/a")
+		var notFoundErr *NotFoundError
+		assert.ErrorAs(err, &notFoundErr, "keys should only be set in their own bucket")
+
+		assert.NoError(store.Delete(FilesBucket, "/a"))
+		assert.NoError(store.Delete(FilesBucket, "/a"), "deleting a key that is not set should succeed")
+		_, err = store.Get(FilesBucket, "/a")
+		assert.ErrorAs(err, &notFoundErr)
+		assert.Equal("/a", notFoundErr.Key)
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		assert := require.New(t)
+		store := newStore(t)
+
+		var invalidKeyErr *InvalidKeyError
+		assert.ErrorAs(store.Set(FilesBucket, "", "1"), &invalidKeyErr)
+		_, err := store.Get(FilesBucket, "")
+		assert.ErrorAs(err, &invalidKeyErr)
+		assert.ErrorAs(store.Delete(FilesBucket, ""), &invalidKeyErr)
+		assert.ErrorAs(store.DeleteMany(FilesBucket, []string{"/a", ""}), &invalidKeyErr)
+
+		assert.ErrorAs(store.SetMany(FilesBucket, map[string]string{"/d": "4", "": "5"}), &invalidKeyErr)
+		_, err = store.Get(FilesBucket, "/d")
+		var notFoundErr *NotFoundError
+		assert.ErrorAs(err, &notFoundErr, "no keys should be set if any of them is invalid")
+	})
+
+	t.Run("DefaultBucket", func(t *testing.T) {
+		assert := require.New(t)
+		store := newStore(t)
+
+		assert.NoError(store.Set("", "key", "value"))
+		value, err := store.Get(BoltDefaultBucket, "key")
+		assert.NoError(err)
+		assert.Equal("value", value)
+		keys, err := store.GetAllKeys("")
+		assert.NoError(err)
+		assert.Equal([]string{"key"}, keys)
+	})
+
+	t.Run("Buckets", func(t *testing.T) {
+		assert := require.New(t)
+		store := newStore(t)
+
+		assert.ErrorIs(store.Set("missing", "key", "value"), ErrBucketNotFound)
+		_, err := store.Get("missing", "key")
+		assert.ErrorIs(err, ErrBucketNotFound)
+		_, err = store.GetAllKeys("missing")
+		assert.ErrorIs(err, ErrBucketNotFound)
+		_, err = store.GetAll("missing")
+		assert.ErrorIs(err, ErrBucketNotFound)
+
+		assert.NoError(store.CreateBucket("extra"))
+		assert.NoError(store.Set("extra", "key", "value"))
+		assert.NoError(store.CreateBucket("extra"), "creating a bucket that exists should succeed")
+		value, err := store.Get("extra", "key")
+		assert.NoError(err)
+		assert.Equal("value", value, "creating a bucket that exists should keep its keys")
+
+		assert.NoError(store.DeleteBucket("extra"))
+		assert.NoError(store.DeleteBucket("extra"), "deleting a bucket that does not exist should succeed")
+		_, err = store.Get("extra", "key")
+		assert.ErrorIs(err, ErrBucketNotFound)
+
+		assert.NoError(store.CreateBucket("extra"))
+		keys, err := store.GetAllKeys("extra")
+		assert.NoError(err)
+		assert.Empty(keys, "a bucket created again should not have the keys of the deleted one")
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		assert := require.New(t)
+		store := newStore(t)
+
+		assert.NoError(store.SetMany(FilesBucket, map[string]string{"/c": "3", "/a": "1", "/b": "2"}))
+		entries, err := store.GetAll(FilesBucket)
+		assert.NoError(err)
+		assert.Equal(map[string]string{"/a": "1", "/b": "2", "/c": "3"}, entries)
+		keys, err := store.GetAllKeys(FilesBucket)
+		assert.NoError(err)
+		assert.Equal([]string{"/a", "/b", "/c"}, keys, "keys should be in byte order")
+
+		entries["/a"] = "changed"
+		value, err := store.Get(FilesBucket, "/a")
+		assert.NoError(err)
+		assert.Equal("1", value, "changing entries that were read should not change the store")
+
+		assert.NoError(store.DeleteMany(FilesBucket, []string{"/a", "/c", "/missing"}))
+		entries, err = store.GetAll(FilesBucket)
+		assert.NoError(err)
+		assert.Equal(map[string]string{"/b": "2"}, entries)
+
+		assert.NoError(store.SetMany(FilesBucket, nil))
+		assert.NoError(store.DeleteMany(FilesBucket, nil))
+	})
+
+	t.Run("Snapshot", func(t *testing.T) {
+		assert := require.New(t)
+		store := newStore(t)
+
+		assert.NoError(store.CreateBucket("extra"))
+		assert.NoError(store.SetMany("extra", map[string]string{"/a": "1", "/b": "2"}))
+		assert.NoError(store.Set(RequestsBucket, "request", "100"))
+
+		path := filepath.Join(t.TempDir(), "snapshot.db")
+		assert.NoError(store.Snapshot(path))
+		assert.NoError(store.Set(RequestsBucket, "request", "-1"))
+
+		snapshot := openTestBoltDB(t, path)
+		entries, err := snapshot.GetAll("extra")
+		assert.NoError(err)
+		assert.Equal(map[string]string{"/a": "1", "/b": "2"}, entries)
+		value, err := snapshot.Get(RequestsBucket, "request")
+		assert.NoError(err)
+		assert.Equal("100", value, "changes after a snapshot should not be in it")
+
+		size, err := store.Size()
+		assert.NoError(err)
+		assert.GreaterOrEqual(size, int64(0))
+	})
+}
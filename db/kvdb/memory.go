@@ -0,0 +1,235 @@
+package kvdb
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/meghashyamc/wheresthat/logger"
+	bolt "go.etcd.io/bbolt"
+)
+
+// MemoryDB is a store that keeps metadata in memory, for deployments and tests that should leave nothing on
+// disk. It behaves the same way as BoltDB.
+type MemoryDB struct {
+	logger logger.Logger
+	// mu guards buckets, and is held for the whole of each operation so that batches are atomic
+	mu      sync.RWMutex
+	buckets map[string]map[string]string
+}
+
+func NewMemoryDB(logger logger.Logger) *MemoryDB {
+	m := &MemoryDB{
+		logger:  logger,
+		buckets: map[string]map[string]string{},
+	}
+	for _, bucketName := range initialBuckets {
+		m.buckets[bucketName] = map[string]string{}
+	}
+
+	return m
+}
+
+// CreateBucket creates a bucket if it does not exist yet
+func (m *MemoryDB) CreateBucket(bucketName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.buckets[bucketName]; !ok {
+		m.buckets[bucketName] = map[string]string{}
+	}
+	return nil
+}
+
+// DeleteBucket deletes a bucket along with all its keys, if it exists
+func (m *MemoryDB) DeleteBucket(bucketName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.buckets, bucketName)
+	return nil
+}
+
+func (m *MemoryDB) Set(bucketName string, key string, value string) error {
+	return m.SetMany(bucketName, map[string]string{key: value})
+}
+
+// SetMany sets several keys at once, so that either all of them are set or none are
+func (m *MemoryDB) SetMany(bucketName string, entries map[string]string) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	for key := range entries {
+		if err := m.validateKey(key); err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bucket, err := m.getBucket(bucketName)
+	if err != nil {
+		return err
+	}
+	maps.Copy(bucket, entries)
+
+	return nil
+}
+
+func (m *MemoryDB) Get(bucketName string, key string) (string, error) {
+	if err := m.validateKey(key); err != nil {
+		return "", err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	bucket, err := m.getBucket(bucketName)
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := bucket[key]
+	if !ok {
+		m.logger.Warn("key not found", "key", key)
+		return "", &NotFoundError{Key: key}
+	}
+
+	return value, nil
+}
+
+func (m *MemoryDB) Delete(bucketName string, key string) error {
+	return m.DeleteMany(bucketName, []string{key})
+}
+
+// DeleteMany deletes several keys at once. Keys that are not set are ignored.
+func (m *MemoryDB) DeleteMany(bucketName string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	for _, key := range keys {
+		if err := m.validateKey(key); err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bucket, err := m.getBucket(bucketName)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		delete(bucket, key)
+	}
+
+	return nil
+}
+
+func (m *MemoryDB) GetAllKeys(bucketName string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	bucket, err := m.getBucket(bucketName)
+	if err != nil {
+		m.logger.Error("failed to get all keys", "err", err.Error())
+		return nil, fmt.Errorf("failed to get all keys: %w", err)
+	}
+
+	// Keys are sorted the way BoltDB returns them
+	return slices.Sorted(maps.Keys(bucket)), nil
+}
+
+// GetAll returns every key in a bucket along with its value
+func (m *MemoryDB) GetAll(bucketName string) (map[string]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	bucket, err := m.getBucket(bucketName)
+	if err != nil {
+		m.logger.Error("failed to get all entries", "err", err.Error())
+		return nil, fmt.Errorf("failed to get all entries: %w", err)
+	}
+
+	return maps.Clone(bucket), nil
+}
+
+// Snapshot writes the contents of the store to path as a bbolt file, so that backups of it can be restored
+// into a BoltDB
+func (m *MemoryDB) Snapshot(path string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		m.logger.Error("failed to create database snapshot", "path", path, "err", err.Error())
+		return fmt.Errorf("failed to create database snapshot: %w", err)
+	}
+	store, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		m.logger.Error("failed to create database snapshot", "path", path, "err", err.Error())
+		return fmt.Errorf("failed to create database snapshot: %w", err)
+	}
+
+	err = store.Update(func(tx *bolt.Tx) error {
+		for bucketName, entries := range m.buckets {
+			bucket, err := tx.CreateBucket([]byte(bucketName))
+			if err != nil {
+				return err
+			}
+			for _, key := range slices.Sorted(maps.Keys(entries)) {
+				if err := bucket.Put([]byte(key), []byte(entries[key])); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if closeErr := store.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		m.logger.Error("failed to write database snapshot", "path", path, "err", err.Error())
+		return fmt.Errorf("failed to write database snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// Size returns 0, since nothing is kept on disk
+func (m *MemoryDB) Size() (int64, error) {
+	return 0, nil
+}
+
+func (m *MemoryDB) Close() error {
+	return nil
+}
+
+func (m *MemoryDB) validateKey(key string) error {
+	if key == "" {
+		m.logger.Error("key cannot be empty", "key", key)
+		return &InvalidKeyError{
+			Key:    key,
+			Reason: "key cannot be empty",
+		}
+	}
+	return nil
+}
+
+// getBucket returns a bucket, which must be called with m.mu held
+func (m *MemoryDB) getBucket(bucketName string) (map[string]string, error) {
+	if bucketName == "" {
+		bucketName = BoltDefaultBucket
+	}
+
+	bucket, ok := m.buckets[bucketName]
+	if !ok {
+		m.logger.Error("bucket not found", "bucket", bucketName)
+		return nil, ErrBucketNotFound
+	}
+	return bucket, nil
+}
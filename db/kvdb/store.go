@@ -0,0 +1,51 @@
+package kvdb
+
+import (
+	"fmt"
+
+	"github.com/meghashyamc/wheresthat/config"
+	"github.com/meghashyamc/wheresthat/logger"
+)
+
+// Backends that can be selected in the config
+const (
+	// BackendBolt keeps metadata in a bbolt file under the storage path
+	BackendBolt = "bolt"
+	// BackendMemory keeps metadata in memory only, so that it is gone once the server stops
+	BackendMemory = "memory"
+)
+
+// Store is a key-value store of metadata, with keys grouped into buckets. Buckets other than the initial ones
+// must be created before their keys are read or written, and an empty bucket name stands for
+// BoltDefaultBucket. Every backend behaves the same way, as checked by the conformance tests.
+type Store interface {
+	Set(bucket string, key string, value string) error
+	// Get returns a *NotFoundError if the key is not set
+	Get(bucket string, key string) (string, error)
+	Delete(bucket string, key string) error
+	// GetAllKeys returns the keys of a bucket in byte order
+	GetAllKeys(bucket string) ([]string, error)
+	SetMany(bucket string, entries map[string]string) error
+	DeleteMany(bucket string, keys []string) error
+	GetAll(bucket string) (map[string]string, error)
+	CreateBucket(bucket string) error
+	DeleteBucket(bucket string) error
+	// Snapshot writes a consistent copy of the store to path as a bbolt file, whatever the backend
+	Snapshot(path string) error
+	// Size returns the size of the store on disk
+	Size() (int64, error)
+	Close() error
+}
+
+// Open opens the store of the backend selected in the config
+func Open(logger logger.Logger, cfg *config.Config) (Store, error) {
+	switch backend := cfg.GetKVDBBackend(); backend {
+	case BackendBolt:
+		return New(logger, cfg)
+	case BackendMemory:
+		logger.Warn("metadata is kept in memory and will be lost when the server stops")
+		return NewMemoryDB(logger), nil
+	default:
+		return nil, fmt.Errorf("unknown key-value database backend %s, expected %s or %s", backend, BackendBolt, BackendMemory)
+	}
+}
@@ -9,6 +9,8 @@ import (
 var (
 	ErrNotFound   = errors.New("key not found")
 	ErrInvalidKey = errors.New("invalid key")
+	// ErrBucketNotFound is returned for reads and writes of buckets that were never created or were deleted
+	ErrBucketNotFound = errors.New("bucket not found")
 )
 
 type InvalidKeyError struct {
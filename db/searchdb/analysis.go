@@ -0,0 +1,260 @@
+package searchdb
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/v2/analysis"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/v2/analysis/token/ngram"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/regexp"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/single"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/registry"
+)
+
+const (
+	codeAnalyzerName         = "code"
+	codeTokenizerName        = "code_tokenizer"
+	codeIdentifierFilterName = "code_identifier"
+
+	nameNgramAnalyzerName = "name_ngram"
+	nameNgramFilterName   = "name_ngram"
+
+	pathHierarchyAnalyzerName = "path_hierarchy"
+	pathHierarchyFilterName   = "path_hierarchy"
+)
+
+// File names are broken into n-grams of these lengths so that any part of a name can be matched
+const (
+	nameNgramMinLength = 2
+	nameNgramMaxLength = 3
+)
+
+// Identifiers may be joined by '_', '-' or '.', e.g. parse_query, kebab-case or fmt.Println
+const codeTokenizerRegexp = `[\p{L}\p{N}_]+(?:[-.][\p{L}\p{N}_]+)*`
+
+func init() {
+	if err := registry.RegisterTokenFilter(codeIdentifierFilterName, codeIdentifierFilterConstructor); err != nil {
+		panic(err)
+	}
+	if err := registry.RegisterTokenFilter(pathHierarchyFilterName, pathHierarchyFilterConstructor); err != nil {
+		panic(err)
+	}
+}
+
+// codeIdentifierFilter splits identifiers such as parseQuotedQuery, snake_case or kebab-case into their parts.
+// The original token is kept at the position of the first part so that both the whole identifier and
+// each of its parts can be searched for, and phrase queries across the parts still work.
+type codeIdentifierFilter struct{}
+
+func codeIdentifierFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	return &codeIdentifierFilter{}, nil
+}
+
+func (f *codeIdentifierFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	output := make(analysis.TokenStream, 0, len(input))
+
+	// Number of extra positions taken up by the parts of identifiers seen so far
+	positionShift := 0
+	for _, token := range input {
+		token.Position += positionShift
+		output = append(output, token)
+
+		parts := splitIdentifier(token.Term)
+		if len(parts) == 0 || (len(parts) == 1 && parts[0].end-parts[0].start == len(token.Term)) {
+			continue
+		}
+
+		for i, part := range parts {
+			output = append(output, &analysis.Token{
+				Term:     token.Term[part.start:part.end],
+				Start:    token.Start + part.start,
+				End:      token.Start + part.end,
+				Position: token.Position + i,
+				Type:     token.Type,
+			})
+		}
+		positionShift += len(parts) - 1
+	}
+
+	return output
+}
+
+// identifierPart holds the byte offsets of a part of an identifier
+type identifierPart struct {
+	start int
+	end   int
+}
+
+// splitIdentifier splits a term at '_', '-' and '.' separators, at lower to upper case transitions
+// (parseQuery), at the end of acronyms (HTTPServer) and at letter/digit boundaries (utf8Decode).
+func splitIdentifier(term []byte) []identifierPart {
+	var parts []identifierPart
+
+	partStart := -1
+	var prev rune
+	for i := 0; i < len(term); {
+		r, size := utf8.DecodeRune(term[i:])
+
+		if r == '_' || r == '-' || r == '.' {
+			if partStart >= 0 {
+				parts = append(parts, identifierPart{start: partStart, end: i})
+				partStart = -1
+			}
+			i += size
+			continue
+		}
+
+		if partStart >= 0 && isIdentifierBoundary(prev, r, term[i+size:]) {
+			parts = append(parts, identifierPart{start: partStart, end: i})
+			partStart = i
+		}
+		if partStart < 0 {
+			partStart = i
+		}
+
+		prev = r
+		i += size
+	}
+
+	if partStart >= 0 {
+		parts = append(parts, identifierPart{start: partStart, end: len(term)})
+	}
+
+	return parts
+}
+
+func isIdentifierBoundary(prev rune, current rune, rest []byte) bool {
+	switch {
+	case unicode.IsDigit(prev) != unicode.IsDigit(current):
+		return true
+	case unicode.IsLower(prev) && unicode.IsUpper(current):
+		return true
+	case unicode.IsUpper(prev) && unicode.IsUpper(current):
+		// The last upper case letter of an acronym starts a new word if a lower case letter follows
+		next, _ := utf8.DecodeRune(rest)
+		return unicode.IsLower(next)
+	}
+
+	return false
+}
+
+func addCodeAnalyzer(indexMapping *mapping.IndexMappingImpl) error {
+
+	if err := indexMapping.AddCustomTokenizer(codeTokenizerName, map[string]interface{}{
+		"type":   regexp.Name,
+		"regexp": codeTokenizerRegexp,
+	}); err != nil {
+		return err
+	}
+
+	return indexMapping.AddCustomAnalyzer(codeAnalyzerName, map[string]interface{}{
+		"type":          custom.Name,
+		"tokenizer":     codeTokenizerName,
+		"token_filters": []string{codeIdentifierFilterName, lowercase.Name},
+	})
+}
+
+func addNameNgramAnalyzer(indexMapping *mapping.IndexMappingImpl) error {
+
+	if err := indexMapping.AddCustomTokenFilter(nameNgramFilterName, map[string]interface{}{
+		"type": ngram.Name,
+		"min":  nameNgramMinLength,
+		"max":  nameNgramMaxLength,
+	}); err != nil {
+		return err
+	}
+
+	// The whole name is a single token, so n-grams span word boundaries just like the name itself
+	return indexMapping.AddCustomAnalyzer(nameNgramAnalyzerName, map[string]interface{}{
+		"type":          custom.Name,
+		"tokenizer":     single.Name,
+		"token_filters": []string{lowercase.Name, nameNgramFilterName},
+	})
+}
+
+// nameNgrams returns the longest n-grams of the (lower case) input that the name n-gram analyzer produces
+func nameNgrams(input string) []string {
+	runes := []rune(input)
+	size := min(len(runes), nameNgramMaxLength)
+	if size < nameNgramMinLength {
+		return nil
+	}
+
+	ngrams := make([]string, 0, len(runes)-size+1)
+	seen := make(map[string]struct{}, len(runes)-size+1)
+	for i := 0; i+size <= len(runes); i++ {
+		ngram := string(runes[i : i+size])
+		if _, ok := seen[ngram]; ok {
+			continue
+		}
+		seen[ngram] = struct{}{}
+		ngrams = append(ngrams, ngram)
+	}
+
+	return ngrams
+}
+
+// pathHierarchyFilter turns a path into its lower case segments, so that words in a path can be matched.
+// For absolute paths it also keeps each ancestor directory and the path itself as they are, so that
+// everything under a directory can be found with a single term lookup.
+type pathHierarchyFilter struct{}
+
+func pathHierarchyFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	return &pathHierarchyFilter{}, nil
+}
+
+func (f *pathHierarchyFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	output := make(analysis.TokenStream, 0, len(input))
+
+	for _, token := range input {
+		path := string(token.Term)
+		isAbsolute := strings.HasPrefix(path, "/")
+		position := token.Position
+
+		segmentStart := 0
+		for segmentStart < len(path) {
+			segmentEnd := strings.IndexByte(path[segmentStart:], '/')
+			if segmentEnd < 0 {
+				segmentEnd = len(path)
+			} else {
+				segmentEnd += segmentStart
+			}
+
+			if segmentEnd > segmentStart {
+				output = append(output, &analysis.Token{
+					Term:     []byte(strings.ToLower(path[segmentStart:segmentEnd])),
+					Start:    token.Start + segmentStart,
+					End:      token.Start + segmentEnd,
+					Position: position,
+					Type:     token.Type,
+				})
+				if isAbsolute {
+					output = append(output, &analysis.Token{
+						Term:     []byte(path[:segmentEnd]),
+						Start:    token.Start,
+						End:      token.Start + segmentEnd,
+						Position: position,
+						Type:     token.Type,
+					})
+				}
+				position++
+			}
+
+			segmentStart = segmentEnd + 1
+		}
+	}
+
+	return output
+}
+
+func addPathHierarchyAnalyzer(indexMapping *mapping.IndexMappingImpl) error {
+	return indexMapping.AddCustomAnalyzer(pathHierarchyAnalyzerName, map[string]interface{}{
+		"type":          custom.Name,
+		"tokenizer":     single.Name,
+		"token_filters": []string{pathHierarchyFilterName},
+	})
+}
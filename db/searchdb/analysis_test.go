@@ -0,0 +1,104 @@
+package searchdb
+
+import (
+	"testing"
+
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/standard"
+	"github.com/stretchr/testify/require"
+)
+
+var codeAnalyzerTestCases = []struct {
+	name           string
+	input          string
+	expectedTokens []string
+}{
+	{
+		name:           "CamelCase",
+		input:          "parseQuotedQuery",
+		expectedTokens: []string{"parsequotedquery", "parse", "quoted", "query"},
+	},
+	{
+		name:           "PascalCaseWithAcronym",
+		input:          "HTTPServer",
+		expectedTokens: []string{"httpserver", "http", "server"},
+	},
+	{
+		name:           "SnakeCase",
+		input:          "snake_case_name",
+		expectedTokens: []string{"snake_case_name", "snake", "case", "name"},
+	},
+	{
+		name:           "KebabCase",
+		input:          "kebab-case",
+		expectedTokens: []string{"kebab-case", "kebab", "case"},
+	},
+	{
+		name:           "DigitBoundaries",
+		input:          "utf8Decode",
+		expectedTokens: []string{"utf8decode", "utf", "8", "decode"},
+	},
+	{
+		name:           "FileName",
+		input:          "file2.go",
+		expectedTokens: []string{"file2.go", "file", "2", "go"},
+	},
+	{
+		name:           "LeadingUnderscore",
+		input:          "_private",
+		expectedTokens: []string{"_private", "private"},
+	},
+	{
+		name:           "PlainWords",
+		input:          "plain words",
+		expectedTokens: []string{"plain", "words"},
+	},
+}
+
+func TestCodeAnalyzer(t *testing.T) {
+	indexMapping, err := createIndexMapping(standard.Name)
+	require.NoError(t, err, "should be able to create index mapping")
+
+	for _, testCase := range codeAnalyzerTestCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert := require.New(t)
+			tokenStream, err := indexMapping.AnalyzeText(codeAnalyzerName, []byte(testCase.input))
+			assert.NoError(err, "should be able to analyze text")
+
+			tokens := make([]string, 0, len(tokenStream))
+			for _, token := range tokenStream {
+				tokens = append(tokens, string(token.Term))
+			}
+			assert.Equal(testCase.expectedTokens, tokens, "tokens should match")
+		})
+	}
+}
+
+func TestCodeAnalyzerPositions(t *testing.T) {
+	assert := require.New(t)
+	indexMapping, err := createIndexMapping(standard.Name)
+	assert.NoError(err, "should be able to create index mapping")
+
+	tokenStream, err := indexMapping.AnalyzeText(codeAnalyzerName, []byte("parseQuery next"))
+	assert.NoError(err, "should be able to analyze text")
+
+	positions := map[string]int{}
+	for _, token := range tokenStream {
+		positions[string(token.Term)] = token.Position
+	}
+	assert.Equal(map[string]int{"parsequery": 1, "parse": 1, "query": 2, "next": 3}, positions, "parts should take up consecutive positions")
+}
+
+func TestPathHierarchyAnalyzer(t *testing.T) {
+	assert := require.New(t)
+	indexMapping, err := createIndexMapping(standard.Name)
+	assert.NoError(err, "should be able to create index mapping")
+
+	tokenStream, err := indexMapping.AnalyzeText(pathHierarchyAnalyzerName, []byte("/data/Invoices/2024.pdf"))
+	assert.NoError(err, "should be able to analyze text")
+
+	tokens := make([]string, 0, len(tokenStream))
+	for _, token := range tokenStream {
+		tokens = append(tokens, string(token.Term))
+	}
+	assert.Equal([]string{"data", "/data", "invoices", "/data/Invoices", "2024.pdf", "/data/Invoices/2024.pdf"}, tokens, "tokens should have lower case segments and ancestor directories")
+}
@@ -0,0 +1,256 @@
+package searchdb
+
+import (
+	"container/heap"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Only this many completions of each kind are returned, however many are asked for
+const maxCompletions = 50
+
+// Completions are the ways a prefix typed into the search box can be completed
+type Completions struct {
+	// Terms complete the last word of the prefix, with the most common terms first
+	Terms []TermCompletion `json:"terms"`
+	// Files are the files whose names start with the prefix
+	Files []FileCompletion `json:"files"`
+}
+
+type TermCompletion struct {
+	Term string `json:"term"`
+	// Count is the number of documents the term is in, counting its content and name separately
+	Count int `json:"count"`
+}
+
+type FileCompletion struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// completionIndex holds the vocabulary of the index in memory, so that prefixes are completed without
+// reading the index. It is built from scratch whenever the index changes and never modified afterwards.
+type completionIndex struct {
+	terms *trieNode
+	// files is sorted by the lower case name, and then by path
+	files []completionFile
+}
+
+type completionFile struct {
+	lowerName string
+	FileCompletion
+}
+
+// trieNode is a node of a trie of terms. Each node keeps the highest count of the terms below it, so the
+// most common completions of a prefix are found without visiting all of them.
+type trieNode struct {
+	// labels holds the byte leading to each child, in order
+	labels   []byte
+	children []*trieNode
+	// count is the count of the term ending at this node, or 0 if no term does
+	count int
+	// maxCount is the highest count of a term ending at this node or below it
+	maxCount int
+}
+
+func (n *trieNode) child(label byte) *trieNode {
+	i := sort.Search(len(n.labels), func(i int) bool { return n.labels[i] >= label })
+	if i < len(n.labels) && n.labels[i] == label {
+		return n.children[i]
+	}
+	return nil
+}
+
+// add adds count to the count of a term
+func (n *trieNode) add(term string, count int) {
+	nodes := make([]*trieNode, 0, len(term)+1)
+	node := n
+	nodes = append(nodes, node)
+	for i := 0; i < len(term); i++ {
+		next := node.child(term[i])
+		if next == nil {
+			next = &trieNode{}
+			at := sort.Search(len(node.labels), func(j int) bool { return node.labels[j] >= term[i] })
+			node.labels = append(node.labels[:at], append([]byte{term[i]}, node.labels[at:]...)...)
+			node.children = append(node.children[:at], append([]*trieNode{next}, node.children[at:]...)...)
+		}
+		node = next
+		nodes = append(nodes, node)
+	}
+
+	node.count += count
+	for _, ancestor := range nodes {
+		ancestor.maxCount = max(ancestor.maxCount, node.count)
+	}
+}
+
+// completionCandidate is either a term, or a node whose terms are yet to be looked at
+type completionCandidate struct {
+	text   string
+	node   *trieNode
+	isTerm bool
+}
+
+func (c completionCandidate) priority() int {
+	if c.isTerm {
+		return c.node.count
+	}
+	return c.node.maxCount
+}
+
+// completionHeap orders candidates by count. Ties are broken alphabetically, and a term comes before a
+// node with the same text, so that terms with the same count are found in alphabetical order.
+type completionHeap []completionCandidate
+
+func (h completionHeap) Len() int { return len(h) }
+func (h completionHeap) Less(i, j int) bool {
+	if h[i].priority() != h[j].priority() {
+		return h[i].priority() > h[j].priority()
+	}
+	if h[i].text != h[j].text {
+		return h[i].text < h[j].text
+	}
+	return h[i].isTerm && !h[j].isTerm
+}
+func (h completionHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *completionHeap) Push(x any)   { *h = append(*h, x.(completionCandidate)) }
+func (h *completionHeap) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// complete returns up to limit terms starting with a prefix, with the highest counts first
+func (n *trieNode) complete(prefix string, limit int) []TermCompletion {
+	node := n
+	for i := 0; i < len(prefix) && node != nil; i++ {
+		node = node.child(prefix[i])
+	}
+	if node == nil {
+		return nil
+	}
+
+	var completions []TermCompletion
+	candidates := &completionHeap{{text: prefix, node: node}}
+	for candidates.Len() > 0 && len(completions) < limit {
+		candidate := heap.Pop(candidates).(completionCandidate)
+		if candidate.isTerm {
+			completions = append(completions, TermCompletion{Term: candidate.text, Count: candidate.node.count})
+			continue
+		}
+
+		if candidate.node.count > 0 {
+			heap.Push(candidates, completionCandidate{text: candidate.text, node: candidate.node, isTerm: true})
+		}
+		for i, child := range candidate.node.children {
+			heap.Push(candidates, completionCandidate{text: candidate.text + string(candidate.node.labels[i]), node: child})
+		}
+	}
+
+	return completions
+}
+
+// completeFiles returns up to limit files whose names start with a prefix
+func (c *completionIndex) completeFiles(prefix string, limit int) []FileCompletion {
+	start := sort.Search(len(c.files), func(i int) bool { return c.files[i].lowerName >= prefix })
+
+	var completions []FileCompletion
+	for i := start; i < len(c.files) && len(completions) < limit && strings.HasPrefix(c.files[i].lowerName, prefix); i++ {
+		completions = append(completions, c.files[i].FileCompletion)
+	}
+
+	return completions
+}
+
+// Complete returns the most common terms completing the last word of a prefix, along with the files whose
+// names start with the prefix
+func (b *BleveDB) Complete(prefix string, limit int) (*Completions, error) {
+	completions := b.completions.Load()
+	if completions == nil {
+		return nil, fmt.Errorf("completions are not loaded")
+	}
+
+	prefix = strings.ToLower(strings.TrimLeft(prefix, " "))
+	limit = min(limit, maxCompletions)
+
+	result := &Completions{Terms: []TermCompletion{}, Files: []FileCompletion{}}
+	if len(prefix) == 0 {
+		return result, nil
+	}
+
+	if words := strings.Fields(prefix); len(words) > 0 && !strings.HasSuffix(prefix, " ") {
+		if terms := completions.terms.complete(words[len(words)-1], limit); terms != nil {
+			result.Terms = terms
+		}
+	}
+	if files := completions.completeFiles(prefix, limit); files != nil {
+		result.Files = files
+	}
+
+	return result, nil
+}
+
+// loadCompletions reads the vocabulary of the content and name fields, along with the paths of all files,
+// into a new completion index. It must be called with b.mu held.
+func (b *BleveDB) loadCompletions() error {
+	start := time.Now()
+	completions := &completionIndex{terms: &trieNode{}}
+
+	for _, field := range []string{indexFieldContent, indexFieldName} {
+		err := b.visitFieldTerms(field, func(term string, count int) {
+			completions.terms.add(term, count)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Paths are indexed whole, so their terms are the paths of all files
+	err := b.visitFieldTerms(indexFieldPath, func(path string, _ int) {
+		name := filepath.Base(path)
+		completions.files = append(completions.files, completionFile{
+			lowerName:      strings.ToLower(name),
+			FileCompletion: FileCompletion{Name: name, Path: path},
+		})
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(completions.files, func(i, j int) bool {
+		if completions.files[i].lowerName != completions.files[j].lowerName {
+			return completions.files[i].lowerName < completions.files[j].lowerName
+		}
+		return completions.files[i].Path < completions.files[j].Path
+	})
+
+	b.completions.Store(completions)
+	b.logger.Info("loaded completions", "files", len(completions.files), "duration", time.Since(start).String())
+
+	return nil
+}
+
+// visitFieldTerms calls visit with each term of a field and the number of documents it is in
+func (b *BleveDB) visitFieldTerms(field string, visit func(term string, count int)) error {
+	dict, err := b.index.FieldDict(field)
+	if err != nil {
+		b.logger.Error("could not read field terms", "field", field, "err", err.Error())
+		return fmt.Errorf("could not read %s terms: %w", field, err)
+	}
+	defer dict.Close()
+
+	for {
+		entry, err := dict.Next()
+		if err != nil {
+			b.logger.Error("could not read field terms", "field", field, "err", err.Error())
+			return fmt.Errorf("could not read %s terms: %w", field, err)
+		}
+		if entry == nil {
+			return nil
+		}
+		visit(entry.Term, int(entry.Count))
+	}
+}
@@ -0,0 +1,41 @@
+package searchdb
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrieComplete(t *testing.T) {
+	assert := require.New(t)
+
+	trie := &trieNode{}
+	trie.add("cache", 5)
+	trie.add("cached", 2)
+	trie.add("cachet", 2)
+	trie.add("cat", 9)
+	trie.add("cacheable", 1)
+	trie.add("cached", 1)
+
+	assert.Equal([]TermCompletion{
+		{Term: "cache", Count: 5},
+		{Term: "cached", Count: 3},
+		{Term: "cachet", Count: 2},
+	}, trie.complete("cach", 3), "terms should be ordered by count and then alphabetically")
+
+	assert.Equal([]TermCompletion{{Term: "cat", Count: 9}, {Term: "cache", Count: 5}}, trie.complete("ca", 2))
+	assert.Empty(trie.complete("dog", 3))
+}
+
+func BenchmarkTrieComplete(b *testing.B) {
+	trie := &trieNode{}
+	for i := range 200000 {
+		trie.add(fmt.Sprintf("term%d", i), i%1000)
+	}
+
+	b.ResetTimer()
+	for b.Loop() {
+		trie.complete("term1", 10)
+	}
+}
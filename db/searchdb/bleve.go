@@ -1,13 +1,19 @@
 package searchdb
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"mime"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/blevesearch/bleve/v2"
@@ -17,53 +23,348 @@ import (
 	"github.com/blevesearch/bleve/v2/search"
 	"github.com/blevesearch/bleve/v2/search/query"
 	"github.com/meghashyamc/wheresthat/config"
+	"github.com/meghashyamc/wheresthat/db/contentdb"
+	"github.com/meghashyamc/wheresthat/db/vectordb"
 	"github.com/meghashyamc/wheresthat/logger"
 )
 
 const IndexingBatchSize = 100
 const snippetContext = 100
 
+// schemaVersion must be incremented whenever the index mapping changes, so that
+// indexes built with an older mapping are detected and rebuilt
+const schemaVersion = 7
+
+var internalKeySchemaVersion = []byte("schema_version")
+
+// The vector database for semantic search is stored next to the index, at the index path with this suffix
+const vectorsPathSuffix = ".vectors"
+
+// Stored content is kept in a file of this name in the index directory, so that it goes wherever the index does
+const contentFileName = "content.db"
+
 const (
 	indexFieldContent = "content"
 	indexFieldName    = "name"
-	indexFieldPath    = "path"
-	indexFieldSize    = "size"
-	indexFieldModTime = "mod_time"
+	// indexFieldNameNgram is a sub-field of name, used to match any part of a file name
+	indexFieldNameNgram = "name_ngram"
+	indexFieldPath      = "path"
+	// indexFieldPathHierarchy is a sub-field of path holding its segments and ancestor directories
+	indexFieldPathHierarchy = "path_hierarchy"
+	indexFieldSize          = "size"
+	indexFieldModTime       = "mod_time"
+	indexFieldType          = "type"
+	indexFieldRoot          = "root"
+	indexFieldExt           = "ext"
+	indexFieldDir           = "dir"
+	indexFieldLang          = "lang"
 )
 
-const (
-	boostForContent       = 3.0
-	boostForFileName      = 2.0
-	boostForPath          = 1.0
-	boostForQuotedPhrase  = 6.0
-	boostForRegularPhrase = 5.0
-	boostForPartialMatch  = 1.5
-)
+// Query text is always analyzed with the standard analyzer. Code documents keep the original
+// identifiers alongside their parts, so they match both whole identifiers and individual words.
+const queryAnalyzer = standard.Name
 
 type BleveDB struct {
+	// name is the name of the collection the index holds
+	name      string
 	indexPath string
-	logger    logger.Logger
-	index     bleve.Index
+	// collectionsPath is the directory the indexes of other collections are kept in
+	collectionsPath string
+	// textAnalyzer analyzes the names and content of text documents
+	textAnalyzer string
+	logger       logger.Logger
+	// mu guards index, which is replaced when the index is recreated
+	mu       sync.RWMutex
+	index    bleve.Index
+	outdated bool
+	// vectors holds embeddings of document passages for semantic search, or is nil if it is not enabled
+	vectors *vectordb.VectorDB
+	// contents holds the compressed content of documents for snippets, or is nil if contentOptions is nil
+	contents       *contentdb.ContentDB
+	contentOptions *contentdb.Options
+	synonyms       *synonyms
+	// completions, stats and languages are replaced, rather than modified, whenever the index is done changing
+	completions atomic.Pointer[completionIndex]
+	stats       atomic.Pointer[IndexStats]
+	languages   atomic.Pointer[[]string]
+	// liveStats are kept up to date as documents are indexed and deleted, and stats is replaced with them
+	liveStats liveStats
+
+	fuzzyMinTermLengthForOneEdit  int
+	fuzzyMinTermLengthForTwoEdits int
+	grepMaxCandidates             int
+	grepTimeout                   time.Duration
+	rankingProfiles               map[string]config.RankingProfile
+	defaultRankingProfile         string
+}
+
+// queryOptions holds the settings of a single search request that affect how its query is built
+type queryOptions struct {
+	fuzziness int
+	profile   config.RankingProfile
+	// languages are those whose language-specific content is searched
+	languages []string
 }
 
 func New(logger logger.Logger, cfg *config.Config) (*BleveDB, error) {
 
-	mapping := createIndexMapping()
-	indexPath := filepath.Join(cfg.GetStoragePath(), cfg.GetIndexPath())
+	rankingProfiles, err := cfg.GetRankingProfiles()
+	if err != nil {
+		logger.Error("could not read ranking profiles", "err", err.Error())
+		return nil, err
+	}
+	defaultRankingProfile := cfg.GetDefaultRankingProfile()
+	if _, ok := rankingProfiles[defaultRankingProfile]; !ok {
+		logger.Error("default ranking profile is not configured", "profile", defaultRankingProfile)
+		return nil, fmt.Errorf("%w: %s", ErrUnknownRankingProfile, defaultRankingProfile)
+	}
+
+	synonymsPath := filepath.Join(cfg.GetStoragePath(), cfg.GetSynonymsPath())
+	synonyms, err := loadSynonyms(synonymsPath)
+	if err != nil {
+		logger.Error("could not load synonyms", "path", synonymsPath, "err", err.Error())
+		return nil, err
+	}
+
+	b := &BleveDB{
+		name:                          DefaultCollection,
+		indexPath:                     filepath.Join(cfg.GetStoragePath(), cfg.GetIndexPath()),
+		collectionsPath:               filepath.Join(cfg.GetStoragePath(), cfg.GetCollectionsPath()),
+		textAnalyzer:                  standard.Name,
+		logger:                        logger,
+		fuzzyMinTermLengthForOneEdit:  cfg.GetFuzzyMinTermLengthForOneEdit(),
+		fuzzyMinTermLengthForTwoEdits: cfg.GetFuzzyMinTermLengthForTwoEdits(),
+		grepMaxCandidates:             cfg.GetGrepMaxCandidates(),
+		grepTimeout:                   cfg.GetGrepTimeout(),
+		rankingProfiles:               rankingProfiles,
+		defaultRankingProfile:         defaultRankingProfile,
+		synonyms:                      synonyms,
+	}
+	if cfg.IsContentStoreEnabled() {
+		b.contentOptions = &contentdb.Options{
+			Compression:     cfg.GetContentStoreCompression(),
+			MaxBytesPerRoot: cfg.GetContentStoreMaxBytesPerRoot(),
+		}
+	}
+
+	if err := b.open(cfg.IsSemanticSearchEnabled()); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// open opens the index at the index path, creating it if it does not exist yet, along with the vector
+// database if semantic search is enabled and the stored content if it is enabled
+func (b *BleveDB) open(semantic bool) error {
+	if err := b.restoreReplacedIndex(); err != nil {
+		b.logger.Error("could not restore index", "path", b.indexPath, "err", err.Error())
+		return err
+	}
+	// Rebuilds that were interrupted are started over, if they are still needed
+	if err := removeIndexFiles(b.indexPath + rebuildPathSuffix); err != nil {
+		b.logger.Warn("could not remove interrupted index rebuild", "path", b.indexPath+rebuildPathSuffix, "err", err.Error())
+	}
+
+	index, err := bleve.Open(b.indexPath)
+	if err != nil {
+		if !errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+			b.logger.Error("could not open index", "path", b.indexPath, "err", err.Error())
+			return err
+		}
+		if index, err = b.createIndex(); err != nil {
+			return err
+		}
+	}
+	// Hits of searches across collections are told apart by the name of the index they came from
+	index.SetName(b.name)
+	b.index = index
+
+	version, err := index.GetInternal(internalKeySchemaVersion)
+	if err != nil {
+		b.logger.Error("could not read index schema version", "err", err.Error())
+		index.Close()
+		return err
+	}
+	if string(version) != strconv.Itoa(schemaVersion) {
+		b.logger.Warn("index was built with an older schema and will be rebuilt by the next indexing request", "collection", b.name, "index_schema_version", string(version), "schema_version", schemaVersion)
+		b.outdated = true
+	}
+
+	if semantic {
+		if err := b.openVectors(); err != nil {
+			index.Close()
+			return err
+		}
+	}
+	if b.contentOptions != nil {
+		if b.contents, err = b.openContents(b.indexPath); err != nil {
+			index.Close()
+			return err
+		}
+	}
+
+	if err := b.loadCompletions(); err != nil {
+		b.closeContents()
+		index.Close()
+		return err
+	}
+	if err := b.loadStats(); err != nil {
+		b.closeContents()
+		index.Close()
+		return err
+	}
+	if err := b.loadLanguages(); err != nil {
+		b.closeContents()
+		index.Close()
+		return err
+	}
+
+	return nil
+}
+
+// openVectors opens the vector database stored next to the index. Documents indexed without semantic
+// search have no embeddings, so the index is rebuilt if it has documents but the vector database does not.
+func (b *BleveDB) openVectors() error {
+	vectors, err := vectordb.New(b.logger, b.indexPath+vectorsPathSuffix)
+	if err != nil {
+		b.logger.Error("could not open vector index", "err", err.Error())
+		return err
+	}
+	b.vectors = vectors
+
+	docCount, err := b.index.DocCount()
+	if err != nil {
+		b.logger.Error("could not count indexed documents", "err", err.Error())
+		return err
+	}
+	if docCount > 0 && !vectors.Loaded() {
+		b.logger.Warn("index has no vector index for semantic search and will be rebuilt by the next indexing request")
+		b.outdated = true
+	}
+
+	return nil
+}
+
+// openContents opens the stored content of the index at indexPath. Files indexed before content was stored
+// have none, so their snippets go on being read from the files until they are indexed again.
+func (b *BleveDB) openContents(indexPath string) (*contentdb.ContentDB, error) {
+	contents, err := contentdb.New(b.logger, filepath.Join(indexPath, contentFileName), *b.contentOptions)
+	if err != nil {
+		b.logger.Error("could not open stored content", "path", indexPath, "err", err.Error())
+		return nil, err
+	}
+
+	return contents, nil
+}
+
+// closeContents closes the stored content, if it is open
+func (b *BleveDB) closeContents() error {
+	if b.contents == nil {
+		return nil
+	}
+
+	if err := b.contents.Close(); err != nil {
+		b.logger.Error("could not close stored content", "err", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (b *BleveDB) createIndex() (bleve.Index, error) {
+	return b.createIndexAt(b.indexPath)
+}
+
+// createIndexAt creates an empty index with the current schema at a path
+func (b *BleveDB) createIndexAt(indexPath string) (bleve.Index, error) {
+	mapping, err := createIndexMapping(b.textAnalyzer)
+	if err != nil {
+		b.logger.Error("could not create index mapping", "err", err.Error())
+		return nil, err
+	}
+
 	index, err := bleve.New(indexPath, mapping)
 	if err != nil {
-		index, err = bleve.Open(indexPath)
-		if err != nil {
-			logger.Error("could not open index", "err", err.Error())
-			return nil, err
+		b.logger.Error("could not create index", "err", err.Error())
+		return nil, err
+	}
+
+	if err := index.SetInternal(internalKeySchemaVersion, []byte(strconv.Itoa(schemaVersion))); err != nil {
+		b.logger.Error("could not store index schema version", "err", err.Error())
+		index.Close()
+		return nil, err
+	}
+
+	return index, nil
+}
+
+// IsOutdated returns true if the index was built with an older schema than the current one
+func (b *BleveDB) IsOutdated() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.outdated
+}
+
+// Recreate deletes all documents by replacing the index with an empty one that uses the current schema
+func (b *BleveDB) Recreate() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.vectors != nil {
+		if err := b.vectors.Reset(); err != nil {
+			return err
 		}
 	}
-	return &BleveDB{indexPath: indexPath, logger: logger, index: index}, nil
+
+	if err := b.closeContents(); err != nil {
+		return err
+	}
+	if err := b.index.Close(); err != nil {
+		b.logger.Error("could not close search index", "err", err.Error())
+		return err
+	}
+
+	if err := os.RemoveAll(b.indexPath); err != nil {
+		b.logger.Error("could not remove search index", "path", b.indexPath, "err", err.Error())
+		return err
+	}
+
+	index, err := b.createIndex()
+	if err != nil {
+		return err
+	}
+	index.SetName(b.name)
+	if b.contentOptions != nil {
+		if b.contents, err = b.openContents(b.indexPath); err != nil {
+			index.Close()
+			return err
+		}
+	}
+
+	b.index = index
+	b.outdated = false
+	b.completions.Store(&completionIndex{terms: &trieNode{}})
+	b.liveStats.reset()
+	b.stats.Store(&IndexStats{DocumentsByRoot: map[string]int{}, DocumentsByExtension: map[string]int{}, ComputedAt: time.Now().UTC()})
+	b.languages.Store(nil)
+	b.logger.Info("recreated search index", "schema_version", schemaVersion)
+
+	return nil
 }
 
 func (b *BleveDB) BuildIndex(documents []*Document) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
 
-	batch := b.index.NewBatch()
+	return b.indexDocuments(b.index, b.vectors, b.contents, &b.liveStats, documents)
+}
+
+// indexDocuments indexes documents into an index in batches, along with their embeddings if vectors is not nil
+// and their content if contents is not nil. The changes are tracked in live if it is not nil.
+func (b *BleveDB) indexDocuments(index bleve.Index, vectors *vectordb.VectorDB, contents *contentdb.ContentDB, live *liveStats, documents []*Document) error {
+	batch := index.NewBatch()
+	batchStart := 0
 
 	for i, doc := range documents {
 
@@ -75,19 +376,17 @@ func (b *BleveDB) BuildIndex(documents []*Document) error {
 
 		// Execute batch when it reaches the batch size
 		if (i+1)%IndexingBatchSize == 0 {
-			err = b.index.Batch(batch)
-			if err != nil {
-				b.logger.Error("could not index document", "err", err.Error())
+			if err := b.indexBatch(index, batch, vectors, contents, live, documents[batchStart:i+1]); err != nil {
 				return err
 			}
 			b.logger.Info("successfully indexed batch of documents", "documents_indexed", fmt.Sprintf("%d/%d", i+1, len(documents)))
-			batch = b.index.NewBatch()
+			batch = index.NewBatch()
+			batchStart = i + 1
 		}
 	}
 
 	if batch.Size() > 0 {
-		if err := b.index.Batch(batch); err != nil {
-			b.logger.Error("could not index document", "err", err.Error())
+		if err := b.indexBatch(index, batch, vectors, contents, live, documents[batchStart:]); err != nil {
 			return err
 		}
 		b.logger.Info("successfully indexed last, remaining batch of documents")
@@ -96,97 +395,382 @@ func (b *BleveDB) BuildIndex(documents []*Document) error {
 	return nil
 }
 
-func createIndexMapping() mapping.IndexMapping {
+// indexBatch executes a batch of documents, and only once it is in the index adds their embeddings and stores
+// their content, so that neither has documents the index does not. The stats of the documents the batch replaces
+// are read before it is executed, so that live can be changed by the difference.
+func (b *BleveDB) indexBatch(index bleve.Index, batch *bleve.Batch, vectors *vectordb.VectorDB, contents *contentdb.ContentDB, live *liveStats, documents []*Document) error {
+	var documentIDs []string
+	var before []documentStats
+	if live != nil {
+		documentIDs = make([]string, len(documents))
+		for i, doc := range documents {
+			documentIDs[i] = doc.ID
+		}
+		var err error
+		if before, err = b.readDocumentStats(index, documentIDs); err != nil {
+			b.logger.Warn("could not read stats of indexed documents, they are computed again on flush", "err", err.Error())
+			live.markStale()
+			live = nil
+		}
+	}
+
+	if err := index.Batch(batch); err != nil {
+		b.logger.Error("could not index document", "err", err.Error())
+		if live != nil {
+			live.markStale()
+		}
+		return err
+	}
+
+	if live != nil {
+		after, err := b.readDocumentStats(index, documentIDs)
+		if err != nil {
+			b.logger.Warn("could not read stats of indexed documents, they are computed again on flush", "err", err.Error())
+			live.markStale()
+		} else {
+			live.trackChange(before, after)
+		}
+	}
+
+	if vectors != nil {
+		for _, doc := range documents {
+			vectors.Index(doc.ID, doc.Content)
+		}
+	}
+
+	if contents != nil {
+		entries := make([]contentdb.Entry, len(documents))
+		for i, doc := range documents {
+			entries[i] = contentdb.Entry{ID: doc.ID, Root: doc.Root, Content: doc.Content}
+		}
+		// The documents are in the index already, so the batch has not failed. Content that could not be stored
+		// is removed instead, so that snippets and pattern searches read the files rather than stale content.
+		if _, err := contents.Put(entries); err != nil {
+			b.logger.Warn("could not store content of indexed documents, their files are read instead", "err", err.Error())
+			documentIDs := make([]string, len(documents))
+			for i, doc := range documents {
+				documentIDs[i] = doc.ID
+			}
+			if err := contents.Delete(documentIDs); err != nil {
+				b.logger.Error("could not remove stale content of indexed documents", "err", err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+// createIndexMapping creates the mapping of an index whose text documents are analyzed with textAnalyzer
+func createIndexMapping(textAnalyzer string) (*mapping.IndexMappingImpl, error) {
 
 	indexMapping := bleve.NewIndexMapping()
+	if err := addCodeAnalyzer(indexMapping); err != nil {
+		return nil, err
+	}
+	if err := addNameNgramAnalyzer(indexMapping); err != nil {
+		return nil, err
+	}
+	if err := addPathHierarchyAnalyzer(indexMapping); err != nil {
+		return nil, err
+	}
+
+	// The document type decides which analyzer is used for a file's name and content
+	indexMapping.DefaultType = DocumentTypeText
+	indexMapping.AddDocumentMapping(DocumentTypeText, createDocumentMapping(textAnalyzer, ""))
+	indexMapping.AddDocumentMapping(DocumentTypeCode, createDocumentMapping(codeAnalyzerName, ""))
+	for _, lang := range Languages {
+		indexMapping.AddDocumentMapping(textDocumentTypeForLanguage(lang), createDocumentMapping(textAnalyzer, lang))
+	}
+
+	return indexMapping, nil
+}
+
+// createDocumentMapping creates the mapping of documents whose name and content are analyzed with textAnalyzer.
+// The content of documents in a language is also indexed into a sub-field, analyzed with the language's analyzer.
+func createDocumentMapping(textAnalyzer string, lang string) *mapping.DocumentMapping {
 	docMapping := bleve.NewDocumentMapping()
 
 	// Path field - not analyzed (exact match)
 	pathFieldMapping := bleve.NewTextFieldMapping()
 	pathFieldMapping.Analyzer = keyword.Name
-	docMapping.AddFieldMappingsAt(indexFieldPath, pathFieldMapping)
+
+	// Path hierarchy - only indexed, for matching directory names and filtering by directory
+	pathHierarchyFieldMapping := bleve.NewTextFieldMapping()
+	pathHierarchyFieldMapping.Name = indexFieldPathHierarchy
+	pathHierarchyFieldMapping.Analyzer = pathHierarchyAnalyzerName
+	pathHierarchyFieldMapping.Store = false
+	pathHierarchyFieldMapping.IncludeInAll = false
+	pathHierarchyFieldMapping.IncludeTermVectors = false
+	pathHierarchyFieldMapping.DocValues = false
+	docMapping.AddFieldMappingsAt(indexFieldPath, pathFieldMapping, pathHierarchyFieldMapping)
 
 	// Name field - analyzed for partial matching
 	nameFieldMapping := bleve.NewTextFieldMapping()
-	nameFieldMapping.Analyzer = standard.Name
-	docMapping.AddFieldMappingsAt(indexFieldName, nameFieldMapping)
+	nameFieldMapping.Analyzer = textAnalyzer
+
+	// Name n-grams - only indexed, for substring matching of file names
+	nameNgramFieldMapping := bleve.NewTextFieldMapping()
+	nameNgramFieldMapping.Name = indexFieldNameNgram
+	nameNgramFieldMapping.Analyzer = nameNgramAnalyzerName
+	nameNgramFieldMapping.Store = false
+	nameNgramFieldMapping.IncludeInAll = false
+	nameNgramFieldMapping.IncludeTermVectors = false
+	nameNgramFieldMapping.DocValues = false
+	docMapping.AddFieldMappingsAt(indexFieldName, nameFieldMapping, nameNgramFieldMapping)
 
 	// Content field - analyzed for full-text search
 	contentFieldMapping := bleve.NewTextFieldMapping()
-	contentFieldMapping.Analyzer = standard.Name
+	contentFieldMapping.Analyzer = textAnalyzer
 	contentFieldMapping.Store = false // Don't store full content in index
 	contentFieldMapping.Index = true  // But do index it for searching
-	docMapping.AddFieldMappingsAt(indexFieldContent, contentFieldMapping)
+	contentFieldMappings := []*mapping.FieldMapping{contentFieldMapping}
+
+	if len(lang) > 0 {
+		languageContentFieldMapping := bleve.NewTextFieldMapping()
+		languageContentFieldMapping.Name = languageContentField(lang)
+		languageContentFieldMapping.Analyzer = lang
+		languageContentFieldMapping.Store = false
+		languageContentFieldMapping.IncludeInAll = false
+		languageContentFieldMapping.DocValues = false
+		contentFieldMappings = append(contentFieldMappings, languageContentFieldMapping)
+	}
+	docMapping.AddFieldMappingsAt(indexFieldContent, contentFieldMappings...)
 
 	sizeFieldMapping := bleve.NewNumericFieldMapping()
 	docMapping.AddFieldMappingsAt(indexFieldSize, sizeFieldMapping)
 
-	indexMapping.AddDocumentMapping("_default", docMapping)
+	typeFieldMapping := bleve.NewTextFieldMapping()
+	typeFieldMapping.Analyzer = keyword.Name
+	docMapping.AddFieldMappingsAt(indexFieldType, typeFieldMapping)
+
+	rootFieldMapping := bleve.NewTextFieldMapping()
+	rootFieldMapping.Analyzer = keyword.Name
+	docMapping.AddFieldMappingsAt(indexFieldRoot, rootFieldMapping)
+
+	extFieldMapping := bleve.NewTextFieldMapping()
+	extFieldMapping.Analyzer = keyword.Name
+	docMapping.AddFieldMappingsAt(indexFieldExt, extFieldMapping)
+
+	dirFieldMapping := bleve.NewTextFieldMapping()
+	dirFieldMapping.Analyzer = keyword.Name
+	docMapping.AddFieldMappingsAt(indexFieldDir, dirFieldMapping)
+
+	langFieldMapping := bleve.NewTextFieldMapping()
+	langFieldMapping.Analyzer = keyword.Name
+	docMapping.AddFieldMappingsAt(indexFieldLang, langFieldMapping)
 
-	return indexMapping
+	return docMapping
 }
 
-func (b *BleveDB) Search(queryString string, limit int, offset int) (*Response, error) {
+func (b *BleveDB) Search(params SearchParams) (*Response, error) {
 	start := time.Now()
 
-	if len(strings.TrimSpace(queryString)) == 0 {
+	if len(strings.TrimSpace(params.Query)) == 0 {
 		return &Response{}, nil
 	}
 
-	searchQuery := b.buildSearchQuery(queryString)
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var response *Response
+	var err error
+	switch {
+	case params.Explain && len(params.Mode) > 0 && params.Mode != SearchModeKeyword:
+		err = ErrExplainNotSupported
+	case len(params.Lang) > 0 && (params.Mode == SearchModeSemantic || params.Mode == SearchModeHybrid):
+		err = ErrLanguageFilterNotSupported
+	case len(params.GroupBy) > 0:
+		response, err = b.searchGroups(params)
+	case params.Mode == SearchModeSemantic:
+		response, err = b.searchSemantic(params)
+	case params.Mode == SearchModeHybrid:
+		response, err = b.searchHybrid(params)
+	case params.Mode == SearchModeRegex || params.Mode == SearchModeLiteral:
+		response, err = b.searchPattern(params)
+	default:
+		response, err = b.searchKeywords(params)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	response.SearchTime = time.Since(start).String()
 
-	searchRequest := bleve.NewSearchRequestOptions(searchQuery, limit, offset, false)
+	return response, nil
+}
 
-	searchRequest.Fields = []string{indexFieldPath, indexFieldName, indexFieldSize, indexFieldModTime}
+// searchKeywords finds documents matching the terms of a query, ranked by BM25 scaled by the boosts of the
+// ranking profile
+func (b *BleveDB) searchKeywords(params SearchParams) (*Response, error) {
+	return searchKeywords([]*BleveDB{b}, params)
+}
+
+// searchKeywords finds documents matching the terms of a query in one or more collections, which are searched
+// together through an index alias if there are several. The query is built with the ranking profile of the
+// first collection, and the scores of hits are then scaled by the boosts of their own collection's profile.
+func searchKeywords(collections []*BleveDB, params SearchParams) (*Response, error) {
+	b := collections[0]
+	profiles := make(map[string]config.RankingProfile, len(collections))
+	indexes := make([]bleve.Index, len(collections))
+	var languages []string
+	for i, collection := range collections {
+		profile, err := collection.rankingProfile(params.RankingProfile)
+		if err != nil {
+			return nil, err
+		}
+		profiles[collection.name] = profile
+		indexes[i] = collection.index
+		for _, lang := range collection.queryLanguages(params) {
+			if !slices.Contains(languages, lang) {
+				languages = append(languages, lang)
+			}
+		}
+	}
+
+	options := queryOptions{fuzziness: params.Fuzziness, profile: profiles[b.name], languages: languages}
+	searchQuery := withFilters(b.buildSearchQuery(params.Query, options), buildFilterQueries(params))
+	expansions := b.expandSynonyms(params.Query)
+	synonymTerms := b.synonymTerms(expansions)
+
+	// The top matches are fetched from the start, since re-ranking them may change which are on the page
+	searchRequest := bleve.NewSearchRequestOptions(searchQuery, rankingHits(params.Offset+params.Limit, slices.Collect(maps.Values(profiles))...), 0, params.Explain)
+
+	searchRequest.Fields = []string{indexFieldPath, indexFieldName, indexFieldSize, indexFieldModTime, indexFieldRoot}
+	// Locations of matched terms are used for snippets and to find terms that were matched fuzzily
+	searchRequest.IncludeLocations = true
 
 	// Enable highlighting for content field
 	searchRequest.Highlight = bleve.NewHighlight()
 	searchRequest.Highlight.AddField(indexFieldContent)
 
-	searchResult, err := b.index.Search(searchRequest)
+	searchRequest.AddFacet(languageFacetName, bleve.NewFacetRequest(indexFieldLang, len(Languages)))
+
+	var searchIndex bleve.Index = b.index
+	if len(indexes) > 1 {
+		searchIndex = bleve.NewIndexAlias(indexes...)
+	}
+
+	searchResult, err := searchIndex.Search(searchRequest)
 	if err != nil {
 		b.logger.Error("search failed", "err", err.Error())
 		return nil, fmt.Errorf("search failed: %w", err)
 	}
 
-	results := make([]Result, len(searchResult.Hits))
-	for i, hit := range searchResult.Hits {
-		result := Result{
-			ID:    hit.ID,
-			Score: hit.Score,
+	hits := searchResult.Hits
+	rankHitsByProfile(hits, func(hit *search.DocumentMatch) config.RankingProfile {
+		if profile, ok := profiles[hit.Index]; ok {
+			return profile
 		}
+		return options.profile
+	}, params.Query, time.Now())
+	hits = hits[min(params.Offset, len(hits)):min(params.Offset+params.Limit, len(hits))]
 
-		if path, ok := hit.Fields[indexFieldPath].(string); ok {
-			result.Path = path
-		}
-		if name, ok := hit.Fields[indexFieldName].(string); ok {
-			result.Name = name
-		}
-		if size, ok := hit.Fields[indexFieldSize].(float64); ok {
-			result.Size = int64(size)
-		}
-		if modTime, ok := hit.Fields[indexFieldModTime].(string); ok {
-			result.ModTime = modTime
-		}
+	results := make([]Result, len(hits))
+	for i, hit := range hits {
+		result := newResult(hit)
 
 		// Extract snippet if content matches exist
-		result.Snippet = b.extractSnippet(result.Path, hit.Locations)
+		result.Snippet, result.Highlights = b.extractSnippet(result.Path, hit.Locations, synonymTerms)
+		result.Explanation = newExplanation(hit.Expl)
+		if len(collections) > 1 {
+			result.Collection = hit.Index
+		}
 
 		results[i] = result
 	}
 
-	searchTime := time.Since(start)
-
 	response := &Response{
 		Results:    results,
 		Total:      searchResult.Total,
-		MaxScore:   searchResult.MaxScore,
-		SearchTime: searchTime.String(),
+		FuzzyTerms: b.findFuzzyTerms(params.Query, options, hits),
+		Synonyms:   expansions,
+		Languages:  newFacetTerms(searchResult.Facets[languageFacetName]),
+	}
+	if len(searchResult.Hits) > 0 {
+		response.MaxScore = searchResult.Hits[0].Score
+	}
+	if params.Explain {
+		response.Query = b.explainQuery(searchQuery)
 	}
 
 	return response, nil
 }
 
+// newResult creates a result from the stored fields of a hit
+func newResult(hit *search.DocumentMatch) Result {
+	result := Result{
+		ID:    hit.ID,
+		Score: hit.Score,
+	}
+
+	if path, ok := hit.Fields[indexFieldPath].(string); ok {
+		result.Path = path
+	}
+	if name, ok := hit.Fields[indexFieldName].(string); ok {
+		result.Name = name
+	}
+	if size, ok := hit.Fields[indexFieldSize].(float64); ok {
+		result.Size = int64(size)
+	}
+	if modTime, ok := hit.Fields[indexFieldModTime].(string); ok {
+		result.ModTime = modTime
+	}
+
+	return result
+}
+
+func buildFilterQueries(params SearchParams) []query.Query {
+	var filters []query.Query
+
+	if under, ok := underDirectory(params.Under); ok {
+		underQuery := bleve.NewTermQuery(under)
+		underQuery.SetField(indexFieldPathHierarchy)
+		filters = append(filters, underQuery)
+	}
+
+	if len(params.Lang) > 0 {
+		langQuery := bleve.NewTermQuery(params.Lang)
+		langQuery.SetField(indexFieldLang)
+		filters = append(filters, langQuery)
+	}
+
+	return filters
+}
+
+// underDirectory returns the directory that results are restricted to, and false if they are not restricted.
+// Every file is under the root of the file system, which the path hierarchy of a file does not include, so
+// results are not restricted by it.
+func underDirectory(under string) (string, bool) {
+	if len(under) == 0 {
+		return "", false
+	}
+
+	under = filepath.Clean(under)
+	if filepath.Dir(under) == under {
+		return "", false
+	}
+
+	return under, true
+}
+
+// withFilters restricts the results of a query to documents that match all the filters. Filters do not
+// affect the scores of the results.
+func withFilters(searchQuery query.Query, filters []query.Query) query.Query {
+	if len(filters) == 0 {
+		return searchQuery
+	}
+
+	conjunctionQuery := bleve.NewConjunctionQuery(searchQuery)
+	for _, filter := range filters {
+		if boostable, ok := filter.(query.BoostableQuery); ok {
+			boostable.SetBoost(0)
+		}
+		conjunctionQuery.AddQuery(filter)
+	}
+
+	return conjunctionQuery
+}
+
 func parseQuotedQuery(queryString string) (quotedPhrases []string, remainingTerms string) {
 	// Regular expression to find quoted phrases
 	quotedRegex := regexp.MustCompile(`"([^"]*)"`)
@@ -206,7 +790,7 @@ func parseQuotedQuery(queryString string) (quotedPhrases []string, remainingTerm
 	return quotedPhrases, remainingTerms
 }
 
-func (b *BleveDB) buildSearchQuery(queryString string) query.Query {
+func (b *BleveDB) buildSearchQuery(queryString string, options queryOptions) query.Query {
 
 	queryString = strings.ToLower(strings.TrimSpace(queryString))
 
@@ -218,7 +802,7 @@ func (b *BleveDB) buildSearchQuery(queryString string) query.Query {
 	disjunctQuery := bleve.NewDisjunctionQuery()
 
 	if len(quotedPhrases) == 0 && len(remainingTerms) == 0 {
-		b.buildSearchSubQueryForRegularPhrase(disjunctQuery, queryString)
+		b.buildSearchSubQueryForRegularPhrase(disjunctQuery, queryString, options)
 		return disjunctQuery
 	}
 
@@ -226,26 +810,27 @@ func (b *BleveDB) buildSearchQuery(queryString string) query.Query {
 		if len(phrase) == 0 {
 			continue
 		}
-		b.buildSearchSubQueryForQuotedPhrase(disjunctQuery, queryString)
+		b.buildSearchSubQueryForQuotedPhrase(disjunctQuery, queryString, options)
 	}
 
 	if len(remainingTerms) > 0 {
-		b.buildSearchSubQueryForRegularPhrase(disjunctQuery, remainingTerms)
+		b.buildSearchSubQueryForRegularPhrase(disjunctQuery, remainingTerms, options)
 	}
 
 	return disjunctQuery
 }
 
-func (b *BleveDB) buildSearchSubQueryForQuotedPhrase(query *query.DisjunctionQuery, queryString string) {
+func (b *BleveDB) buildSearchSubQueryForQuotedPhrase(query *query.DisjunctionQuery, queryString string, options queryOptions) {
 
 	contentPhraseQuery := bleve.NewMatchPhraseQuery(queryString)
+	contentPhraseQuery.Analyzer = queryAnalyzer
 	contentPhraseQuery.SetField(indexFieldContent)
-	contentPhraseQuery.SetBoost(boostForQuotedPhrase)
+	contentPhraseQuery.SetBoost(options.profile.QuotedPhrase)
 	query.AddQuery(contentPhraseQuery)
 
 }
 
-func (b *BleveDB) buildSearchSubQueryForRegularPhrase(query *query.DisjunctionQuery, queryString string) {
+func (b *BleveDB) buildSearchSubQueryForRegularPhrase(query *query.DisjunctionQuery, queryString string, options queryOptions) {
 
 	// Split query into individual terms
 	terms := strings.Fields(queryString)
@@ -255,77 +840,112 @@ func (b *BleveDB) buildSearchSubQueryForRegularPhrase(query *query.DisjunctionQu
 	}
 
 	if len(terms) == 1 {
-		b.buildSearchSubQueryForSingleTerm(query, queryString)
+		b.buildSearchSubQueryForSingleTerm(query, queryString, options)
 		return
 	}
 	// Multiple terms - require phrase to be present or ALL terms to be present
 	phraseQuery := bleve.NewMatchPhraseQuery(queryString)
+	phraseQuery.Analyzer = queryAnalyzer
 	phraseQuery.SetField(indexFieldContent)
-	phraseQuery.SetBoost(boostForRegularPhrase)
+	phraseQuery.SetBoost(options.profile.RegularPhrase)
 	query.AddQuery(phraseQuery)
 
 	conjunctionQuery := bleve.NewConjunctionQuery()
 
 	for _, term := range terms {
 		termQuery := bleve.NewDisjunctionQuery()
-		b.buildSearchSubQueryForSingleTerm(termQuery, term)
+		b.buildSearchSubQueryForSingleTerm(termQuery, term, options)
 		conjunctionQuery.AddQuery(termQuery)
 	}
 
 	query.AddQuery(conjunctionQuery)
 }
 
-func (b *BleveDB) buildSearchSubQueryForSingleTerm(query *query.DisjunctionQuery, term string) {
+func (b *BleveDB) buildSearchSubQueryForSingleTerm(query *query.DisjunctionQuery, term string, options queryOptions) {
 
 	contentQuery := bleve.NewMatchQuery(term)
+	contentQuery.Analyzer = queryAnalyzer
 	contentQuery.SetField(indexFieldContent)
-	contentQuery.SetBoost(boostForContent)
+	contentQuery.SetBoost(options.profile.Content)
 	query.AddQuery(contentQuery)
 
 	nameQuery := bleve.NewMatchQuery(term)
+	nameQuery.Analyzer = queryAnalyzer
 	nameQuery.SetField(indexFieldName)
-	nameQuery.SetBoost(boostForFileName)
+	nameQuery.SetBoost(options.profile.FileName)
 	query.AddQuery(nameQuery)
 
 	pathQuery := bleve.NewMatchQuery(term)
-	pathQuery.SetField(indexFieldPath)
-	pathQuery.SetBoost(boostForPath)
+	pathQuery.Analyzer = pathHierarchyAnalyzerName
+	pathQuery.SetField(indexFieldPathHierarchy)
+	pathQuery.SetBoost(options.profile.Path)
 	query.AddQuery(pathQuery)
 
 	// Prefix matching for partial matches
 	if len(term) > 2 {
 		prefixQuery := bleve.NewPrefixQuery(term)
 		prefixQuery.SetField(indexFieldName)
-		prefixQuery.SetBoost(boostForPartialMatch)
+		prefixQuery.SetBoost(options.profile.PartialMatch)
 		query.AddQuery(prefixQuery)
 
 		contentPrefixQuery := bleve.NewPrefixQuery(term)
 		contentPrefixQuery.SetField(indexFieldContent)
-		contentPrefixQuery.SetBoost(boostForPartialMatch)
+		contentPrefixQuery.SetBoost(options.profile.PartialMatch)
 		query.AddQuery(contentPrefixQuery)
 	}
+
+	// Fuzzy matching for typos
+	b.buildFuzzySubQueries(query, term, options)
+
+	// Stemmed matching for other forms of the term
+	b.buildStemmedSubQuery(query, term, options)
+
+	b.buildSynonymSubQueries(query, term, options)
 }
 
 func (b *BleveDB) DeleteDocuments(documentIDs []string) error {
-	batch := b.index.NewBatch()
+	b.mu.RLock()
+	defer b.mu.RUnlock()
 
-	for i, docID := range documentIDs {
-		batch.Delete(docID)
+	before, err := b.readDocumentStats(b.index, documentIDs)
+	if err != nil {
+		b.logger.Warn("could not read stats of deleted documents, they are computed again on flush", "err", err.Error())
+		b.liveStats.markStale()
+	}
 
-		// Execute batch when it reaches the batch size
-		if (i+1)%IndexingBatchSize == 0 {
-			err := b.index.Batch(batch)
-			if err != nil {
-				return err
-			}
-			batch = b.index.NewBatch()
+	for i := 0; i < len(documentIDs); i += IndexingBatchSize {
+		if err := b.deleteBatch(documentIDs[i:min(i+IndexingBatchSize, len(documentIDs))]); err != nil {
+			b.liveStats.markStale()
+			return err
 		}
 	}
 
-	if batch.Size() > 0 {
-		if err := b.index.Batch(batch); err != nil {
-			b.logger.Error("could not delete documents", "err", err.Error())
-			return err
+	if before != nil {
+		b.liveStats.trackChange(before, nil)
+	}
+
+	return nil
+}
+
+// deleteBatch deletes a batch of documents from the index, and only once they are out of it removes their
+// embeddings and stored content, so that neither is missing for documents the index still has. Content that
+// could not be removed is never read, since only documents in the index are.
+func (b *BleveDB) deleteBatch(documentIDs []string) error {
+	batch := b.index.NewBatch()
+	for _, docID := range documentIDs {
+		batch.Delete(docID)
+	}
+	if err := b.index.Batch(batch); err != nil {
+		b.logger.Error("could not delete documents", "err", err.Error())
+		return err
+	}
+
+	if b.vectors != nil {
+		b.vectors.Delete(documentIDs)
+	}
+	if b.contents != nil {
+		if err := b.contents.Delete(documentIDs); err != nil {
+			b.logger.Warn("could not remove stored content of deleted documents", "err", err.Error())
 		}
 	}
 
@@ -333,10 +953,49 @@ func (b *BleveDB) DeleteDocuments(documentIDs []string) error {
 }
 
 func (b *BleveDB) GetDocCount() (uint64, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
 	return b.index.DocCount()
 }
 
+// Flush saves data that is kept in memory while indexing, which is the vector database for semantic search,
+// reloads the completions and indexed languages from the updated index and publishes its updated stats
+func (b *BleveDB) Flush() error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	// Completions and stats that are out of date are still usable, so failing to reload them does not fail indexing
+	if err := b.loadCompletions(); err != nil {
+		b.logger.Warn("could not reload completions, the previous ones are kept", "err", err.Error())
+	}
+	if err := b.updateStats(); err != nil {
+		b.logger.Warn("could not update index stats, the previous ones are kept", "err", err.Error())
+	}
+	if err := b.loadLanguages(); err != nil {
+		b.logger.Warn("could not reload indexed languages, the previous ones are kept", "err", err.Error())
+	}
+
+	if b.vectors == nil {
+		return nil
+	}
+
+	return b.vectors.Save()
+}
+
 func (b *BleveDB) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.vectors != nil {
+		if err := b.vectors.Close(); err != nil {
+			b.logger.Error("could not close vector index", "err", err.Error())
+			return err
+		}
+	}
+	if err := b.closeContents(); err != nil {
+		return err
+	}
 
 	if b.index != nil {
 		if err := b.index.Close(); err != nil {
@@ -347,27 +1006,45 @@ func (b *BleveDB) Close() error {
 	return nil
 }
 
-func (b *BleveDB) extractSnippet(filePath string, locations search.FieldTermLocationMap) string {
-	// Check if there are content locations from the search
-	contentLocations, hasContentMatch := locations[indexFieldContent]
+// extractSnippet reads the text around a match in a file, along with where any of highlightTerms matched in it
+func (b *BleveDB) extractSnippet(filePath string, locations search.FieldTermLocationMap, highlightTerms map[string]struct{}) (string, []Highlight) {
+	// Check if there are content locations from the search, falling back to matches of stemmed terms
+	contentLocations := locations[indexFieldContent]
+	for _, lang := range Languages {
+		if len(contentLocations) > 0 {
+			break
+		}
+		contentLocations = locations[languageContentField(lang)]
+	}
+
+	if len(contentLocations) == 0 {
+		return "", nil
+	}
 
-	if !hasContentMatch || len(contentLocations) == 0 {
-		return ""
+	// Stored content is what was indexed, so it is used even if the file changed, moved or is not text
+	if b.contents != nil {
+		content, ok, err := b.contents.Get(filePath)
+		if err != nil {
+			b.logger.Warn("failed to read stored content for snippet", "path", filePath, "err", err.Error())
+		}
+		if ok {
+			return b.snippetFromContent(filePath, content, contentLocations, highlightTerms)
+		}
 	}
 
 	// Check if file is text-based
 	if !b.isTextFile(filePath) {
-		return ""
+		return "", nil
 	}
 
 	// Try to read the file and extract snippet from the first location
-	snippet, err := b.readSnippetFromLocation(filePath, contentLocations)
+	snippet, highlights, err := b.readSnippetFromLocation(filePath, contentLocations, highlightTerms)
 	if err != nil {
 		b.logger.Warn("failed to extract snippet from file", "path", filePath, "err", err.Error())
-		return ""
+		return "", nil
 	}
 
-	return snippet
+	return snippet, highlights
 }
 
 func (b *BleveDB) isTextFile(filePath string) bool {
@@ -398,11 +1075,11 @@ func (b *BleveDB) isTextFile(filePath string) bool {
 	return strings.HasPrefix(mimeType, "text/")
 }
 
-func (b *BleveDB) readSnippetFromLocation(filePath string, termLocations search.TermLocationMap) (string, error) {
+func (b *BleveDB) readSnippetFromLocation(filePath string, termLocations search.TermLocationMap, highlightTerms map[string]struct{}) (string, []Highlight, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		b.logger.Error("failed to open file for snippet", "path", filePath, "err", err.Error())
-		return "", err
+		return "", nil, err
 	}
 	defer file.Close()
 
@@ -410,10 +1087,46 @@ func (b *BleveDB) readSnippetFromLocation(filePath string, termLocations search.
 	fileInfo, err := file.Stat()
 	if err != nil {
 		b.logger.Error("failed to get file info for snippet", "path", filePath, "err", err.Error())
-		return "", err
+		return "", nil, err
 	}
 	fileSize := fileInfo.Size()
 
+	snippetStart, snippetEnd, ok := b.snippetBounds(filePath, termLocations, fileSize)
+	if !ok {
+		return "", nil, nil
+	}
+
+	// Read only the snippet portion using ReadAt
+	buffer := make([]byte, snippetEnd-snippetStart)
+	_, err = file.ReadAt(buffer, snippetStart)
+	if err != nil && err != io.EOF {
+		b.logger.Error("failed to read file for snippet", "path", filePath, "err", err.Error())
+		return "", nil, err
+	}
+
+	snippet := formatSnippet(string(buffer), snippetStart, snippetEnd, fileSize)
+
+	return snippet, snippetHighlights(snippet, string(buffer), snippetStart, termLocations, highlightTerms), nil
+}
+
+// snippetFromContent extracts a snippet from the stored content of a document, which the term locations of
+// its matches are always within
+func (b *BleveDB) snippetFromContent(filePath string, content string, termLocations search.TermLocationMap, highlightTerms map[string]struct{}) (string, []Highlight) {
+	contentSize := int64(len(content))
+	snippetStart, snippetEnd, ok := b.snippetBounds(filePath, termLocations, contentSize)
+	if !ok {
+		return "", nil
+	}
+
+	rawSnippet := content[snippetStart:snippetEnd]
+	snippet := formatSnippet(rawSnippet, snippetStart, snippetEnd, contentSize)
+
+	return snippet, snippetHighlights(snippet, rawSnippet, snippetStart, termLocations, highlightTerms)
+}
+
+// snippetBounds returns where the snippet around the first match in termLocations starts and ends, within
+// content of the given size. It returns false if there is no match within the content.
+func (b *BleveDB) snippetBounds(filePath string, termLocations search.TermLocationMap, size int64) (int64, int64, bool) {
 	var matchStart, matchEnd uint64
 	found := false
 
@@ -429,38 +1142,28 @@ func (b *BleveDB) readSnippetFromLocation(filePath string, termLocations search.
 
 	if !found {
 		b.logger.Error("no match found for snippet", "path", filePath)
-		return "", nil
+		return 0, 0, false
 	}
 
-	if matchStart >= uint64(fileSize) {
-		b.logger.Error("match start is beyond file size for snippet", "path", filePath, "matchStart", matchStart, "fileSize", fileSize)
-		return "", nil
+	if matchStart >= uint64(size) {
+		b.logger.Error("match start is beyond file size for snippet", "path", filePath, "matchStart", matchStart, "fileSize", size)
+		return 0, 0, false
 	}
-	if matchEnd > uint64(fileSize) {
-		b.logger.Error("match end is beyond file size for snippet", "path", filePath, "matchEnd", matchEnd, "fileSize", fileSize)
-		matchEnd = uint64(fileSize)
+	if matchEnd > uint64(size) {
+		b.logger.Error("match end is beyond file size for snippet", "path", filePath, "matchEnd", matchEnd, "fileSize", size)
+		matchEnd = uint64(size)
 	}
 
 	// Calculate snippet boundaries with context
 	snippetStart := max(0, int64(matchStart)-int64(snippetContext))
-	snippetEnd := min(fileSize, int64(matchEnd)+int64(snippetContext))
+	snippetEnd := min(size, int64(matchEnd)+int64(snippetContext))
 
-	// Calculate buffer size needed
-	bufferSize := snippetEnd - snippetStart
-	if bufferSize <= 0 {
+	if snippetEnd-snippetStart <= 0 {
 		b.logger.Error("invalid buffer size for snippet", "path", filePath, "snippetStart", snippetStart, "snippetEnd", snippetEnd)
-		return "", nil
-	}
-
-	// Read only the snippet portion using ReadAt
-	buffer := make([]byte, bufferSize)
-	_, err = file.ReadAt(buffer, snippetStart)
-	if err != nil && err != io.EOF {
-		b.logger.Error("failed to read file for snippet", "path", filePath, "err", err.Error())
-		return "", err
+		return 0, 0, false
 	}
 
-	return formatSnippet(string(buffer), snippetStart, snippetEnd, fileSize), nil
+	return snippetStart, snippetEnd, true
 }
 
 func formatSnippet(snippet string, snippetStart int64, snippetEnd int64, fileSize int64) string {
@@ -0,0 +1,162 @@
+package searchdb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/standard"
+)
+
+// DefaultCollection is the name of the collection kept at the configured index path, which is searched and
+// indexed when no collection is named
+const DefaultCollection = "default"
+
+// The analyzers the names and content of text documents in a collection can be analyzed with
+const (
+	CollectionAnalyzerStandard = "standard"
+	CollectionAnalyzerCode     = "code"
+)
+
+// Each collection other than the default one is kept in its own directory within the collections path
+const collectionIndexDir = "search.index"
+
+var ErrMultiCollectionSearchNotSupported = errors.New("several collections can only be searched together in keyword mode and without grouping")
+
+// CollectionSettings are how the documents of a collection are analyzed and ranked
+type CollectionSettings struct {
+	// Analyzer is CollectionAnalyzerStandard (the default) or CollectionAnalyzerCode. Code documents are
+	// always analyzed as code.
+	Analyzer string
+	// RankingProfile is the ranking profile used when a search does not name one, or empty for the default one
+	RankingProfile string
+}
+
+// OpenCollection opens the index of a collection other than the default one, creating it if it does not exist
+// yet. The collection shares its synonyms, ranking profiles and search settings with b.
+func (b *BleveDB) OpenCollection(name string, settings CollectionSettings) (*BleveDB, error) {
+	textAnalyzer := standard.Name
+	if settings.Analyzer == CollectionAnalyzerCode {
+		textAnalyzer = codeAnalyzerName
+	}
+
+	defaultRankingProfile := b.defaultRankingProfile
+	if len(settings.RankingProfile) > 0 {
+		if _, ok := b.rankingProfiles[settings.RankingProfile]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownRankingProfile, settings.RankingProfile)
+		}
+		defaultRankingProfile = settings.RankingProfile
+	}
+
+	collection := &BleveDB{
+		name:                          name,
+		indexPath:                     CollectionIndexPath(b.collectionsPath, name),
+		collectionsPath:               b.collectionsPath,
+		textAnalyzer:                  textAnalyzer,
+		logger:                        b.logger,
+		synonyms:                      b.synonyms,
+		fuzzyMinTermLengthForOneEdit:  b.fuzzyMinTermLengthForOneEdit,
+		fuzzyMinTermLengthForTwoEdits: b.fuzzyMinTermLengthForTwoEdits,
+		grepMaxCandidates:             b.grepMaxCandidates,
+		grepTimeout:                   b.grepTimeout,
+		rankingProfiles:               b.rankingProfiles,
+		defaultRankingProfile:         defaultRankingProfile,
+		contentOptions:                b.contentOptions,
+	}
+
+	if err := os.MkdirAll(filepath.Dir(collection.indexPath), 0755); err != nil {
+		b.logger.Error("could not create collection directory", "collection", name, "err", err.Error())
+		return nil, err
+	}
+
+	if err := collection.open(b.vectors != nil); err != nil {
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+// CollectionIndexPath returns where the index of a collection other than the default one is kept
+func CollectionIndexPath(collectionsPath string, name string) string {
+	return filepath.Join(collectionsPath, name, collectionIndexDir)
+}
+
+// Name returns the name of the collection the index holds
+func (b *BleveDB) Name() string {
+	return b.name
+}
+
+// DefaultRankingProfile returns the name of the ranking profile used when a search does not name one
+func (b *BleveDB) DefaultRankingProfile() string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.defaultRankingProfile
+}
+
+// SetDefaultRankingProfile changes the ranking profile used when a search does not name one
+func (b *BleveDB) SetDefaultRankingProfile(name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.rankingProfiles[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownRankingProfile, name)
+	}
+	b.defaultRankingProfile = name
+
+	return nil
+}
+
+// Destroy closes the index of a collection other than the default one and removes its directory
+func (b *BleveDB) Destroy() error {
+	if b.name == DefaultCollection {
+		return errors.New("the default collection can not be destroyed")
+	}
+
+	if err := b.Close(); err != nil {
+		return err
+	}
+
+	collectionPath := filepath.Dir(b.indexPath)
+	if err := os.RemoveAll(collectionPath); err != nil {
+		b.logger.Error("could not remove collection", "path", collectionPath, "err", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// SearchCollections searches one or more collections. Several collections are only searched together with
+// keyword searches, whose results are merged by score and name the collection they are from.
+func SearchCollections(collections []*BleveDB, params SearchParams) (*Response, error) {
+	if len(collections) == 1 {
+		return collections[0].Search(params)
+	}
+
+	start := time.Now()
+
+	if len(strings.TrimSpace(params.Query)) == 0 {
+		return &Response{}, nil
+	}
+
+	if (len(params.Mode) > 0 && params.Mode != SearchModeKeyword) || len(params.GroupBy) > 0 {
+		return nil, ErrMultiCollectionSearchNotSupported
+	}
+
+	for _, collection := range collections {
+		collection.mu.RLock()
+		defer collection.mu.RUnlock()
+	}
+
+	response, err := searchKeywords(collections, params)
+	if err != nil {
+		return nil, err
+	}
+
+	response.SearchTime = time.Since(start).String()
+
+	return response, nil
+}
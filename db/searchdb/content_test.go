@@ -0,0 +1,88 @@
+package searchdb
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/standard"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/meghashyamc/wheresthat/db/contentdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoredContentSnippets(t *testing.T) {
+	assert := require.New(t)
+
+	b := &BleveDB{
+		name:           DefaultCollection,
+		indexPath:      filepath.Join(t.TempDir(), "search.index"),
+		textAnalyzer:   standard.Name,
+		logger:         slog.New(slog.NewTextHandler(os.Stderr, nil)),
+		contentOptions: &contentdb.Options{Compression: contentdb.CompressionZstd},
+	}
+	assert.NoError(b.open(false))
+	defer b.Close()
+
+	// The file is not on disk, so its snippet can only come from the stored content
+	path := filepath.Join(t.TempDir(), "missing", "cache.md")
+	assert.NoError(b.BuildIndex([]*Document{{ID: path, Path: path, Name: "cache.md", Root: filepath.Dir(path), Type: DocumentTypeText, Content: "notes on cache eviction"}}))
+
+	highlightTerms := map[string]struct{}{"eviction": {}}
+	locations := search.FieldTermLocationMap{indexFieldContent: {"eviction": {{Start: 15, End: 23}}}}
+	snippet, highlights := b.extractSnippet(path, locations, highlightTerms)
+	assert.Equal("notes on cache eviction", snippet)
+	assert.Equal([]Highlight{{Start: 15, End: 23, Term: "eviction"}}, highlights)
+	assert.Equal("...cache eviction", b.readPassageSnippet(path, 9, 23), "semantic snippets should come from the stored content")
+
+	lines, err := b.grepDocument(context.Background(), regexp.MustCompile(`cache\s+evict`), path)
+	assert.NoError(err)
+	assert.Equal([]LineMatch{{Number: 1, Text: "notes on cache eviction"}}, lines, "pattern searches should scan the stored content")
+
+	rebuild, err := b.StartRebuild()
+	assert.NoError(err)
+	assert.NoError(rebuild.BuildIndex([]*Document{{ID: path, Path: path, Name: "cache.md", Root: filepath.Dir(path), Type: DocumentTypeText, Content: "eviction of old entries"}}))
+	assert.NoError(rebuild.Commit())
+
+	locations = search.FieldTermLocationMap{indexFieldContent: {"eviction": {{Start: 0, End: 8}}}}
+	snippet, _ = b.extractSnippet(path, locations, highlightTerms)
+	assert.Equal("eviction of old entries", snippet, "stored content should be rebuilt along with the index")
+
+	assert.NoError(b.DeleteDocuments([]string{path}))
+	_, ok, err := b.contents.Get(path)
+	assert.NoError(err)
+	assert.False(ok, "stored content should be deleted along with the document")
+}
+
+func TestIndexingWhenContentCanNotBeWritten(t *testing.T) {
+	assert := require.New(t)
+
+	b := &BleveDB{
+		name:           DefaultCollection,
+		indexPath:      filepath.Join(t.TempDir(), "search.index"),
+		textAnalyzer:   standard.Name,
+		logger:         slog.New(slog.NewTextHandler(os.Stderr, nil)),
+		contentOptions: &contentdb.Options{Compression: contentdb.CompressionZstd},
+	}
+	assert.NoError(b.open(false))
+	defer b.Close()
+
+	// Writes to a closed content store fail
+	assert.NoError(b.contents.Close())
+
+	path := filepath.Join(t.TempDir(), "cache.md")
+	err := b.BuildIndex([]*Document{{ID: path, Path: path, Name: "cache.md", Root: filepath.Dir(path), Type: DocumentTypeText, Content: "notes on cache eviction"}})
+	assert.NoError(err, "documents in the index should not be reported as failed to index")
+
+	docCount, err := b.GetDocCount()
+	assert.NoError(err)
+	assert.Equal(uint64(1), docCount)
+
+	assert.NoError(b.DeleteDocuments([]string{path}), "documents should be deleted from the index even if their content can not be")
+	docCount, err = b.GetDocCount()
+	assert.NoError(err)
+	assert.Equal(uint64(0), docCount)
+}
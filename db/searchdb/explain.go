@@ -0,0 +1,45 @@
+package searchdb
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+var ErrExplainNotSupported = errors.New("scores can only be explained in keyword mode")
+
+// Explanation is how a score was calculated, as a tree of the values it was calculated from
+type Explanation struct {
+	Value    float64       `json:"value"`
+	Message  string        `json:"message"`
+	Children []Explanation `json:"children,omitempty"`
+}
+
+func newExplanation(explanation *search.Explanation) *Explanation {
+	if explanation == nil {
+		return nil
+	}
+
+	converted := &Explanation{Value: explanation.Value, Message: explanation.Message}
+	for _, child := range explanation.Children {
+		if child := newExplanation(child); child != nil {
+			converted.Children = append(converted.Children, *child)
+		}
+	}
+
+	return converted
+}
+
+// explainQuery returns the query a search was rewritten into, as JSON
+func (b *BleveDB) explainQuery(searchQuery query.Query) json.RawMessage {
+	queryJSON, err := json.Marshal(searchQuery)
+	if err != nil {
+		// The explanation is only a debugging aid, so the search itself still succeeds
+		b.logger.Warn("could not explain query", "err", err.Error())
+		return nil
+	}
+
+	return queryJSON
+}
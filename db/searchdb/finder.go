@@ -0,0 +1,237 @@
+package searchdb
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// Number of file names fetched from the index using n-grams, before they are scored by how well they match
+const maxFileFinderCandidates = 200
+
+// Names that only have a word starting with the pattern's first character are fetched after those sharing
+// its n-grams
+const finderInitialBoost = 0.1
+
+const (
+	finderScoreContiguousMatch = 100.0
+	finderScoreNameStartMatch  = 50.0
+	finderScoreSegmentMatch    = 25.0
+	finderScoreConsecutiveRune = 5.0
+	finderScoreSegmentRune     = 8.0
+	// Longer names rank lower, but never by more than finderMaxLengthPenalty
+	finderPenaltyPerExtraRune = 0.5
+	finderMaxLengthPenalty    = 20.0
+)
+
+// FindFiles matches a pattern against file names the way "go to file" pickers in editors do. Candidates
+// are names sharing n-grams with the pattern or having a word that starts with its first character, and
+// names that contain the pattern are ranked above names that only contain its characters in order. Matches
+// at the start of a name or of a word within it rank higher still. Whitespace in the pattern is ignored.
+// Only maxFileFinderCandidates names are scored, so Total never exceeds it.
+func (b *BleveDB) FindFiles(pattern string, limit int) (*Response, error) {
+	start := time.Now()
+
+	pattern = strings.ToLower(strings.Join(strings.Fields(pattern), ""))
+	if len(pattern) == 0 {
+		return &Response{}, nil
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	// No highlighting or snippets are needed, which keeps lookups fast enough for every keystroke
+	searchRequest := bleve.NewSearchRequestOptions(buildFileFinderQuery(pattern), maxFileFinderCandidates, 0, false)
+	searchRequest.Fields = []string{indexFieldPath, indexFieldName, indexFieldSize, indexFieldModTime}
+
+	searchResult, err := b.index.Search(searchRequest)
+	if err != nil {
+		b.logger.Error("file name search failed", "err", err.Error())
+		return nil, fmt.Errorf("file name search failed: %w", err)
+	}
+
+	results := make([]Result, 0, len(searchResult.Hits))
+	for _, hit := range searchResult.Hits {
+		result := newResult(hit)
+		score, ok := scoreFileNameMatch(pattern, result.Name)
+		if !ok {
+			continue
+		}
+		result.Score = score
+		results = append(results, result)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return len(results[i].Path) < len(results[j].Path)
+	})
+
+	response := &Response{
+		Results: results[:min(limit, len(results))],
+		Total:   uint64(len(results)),
+	}
+	if len(results) > 0 {
+		response.MaxScore = results[0].Score
+	}
+	response.SearchTime = time.Since(start).String()
+
+	return response, nil
+}
+
+func buildFileFinderQuery(pattern string) query.Query {
+	ngrams := nameNgrams(pattern)
+
+	// A pattern shorter than the shortest n-gram can only be matched as the start of one
+	if len(ngrams) == 0 {
+		prefixQuery := bleve.NewPrefixQuery(pattern)
+		prefixQuery.SetField(indexFieldNameNgram)
+		return prefixQuery
+	}
+
+	// Names containing more of the pattern's n-grams score higher and are picked as candidates first
+	disjunctionQuery := bleve.NewDisjunctionQuery()
+	for _, ngram := range ngrams {
+		termQuery := bleve.NewTermQuery(ngram)
+		termQuery.SetField(indexFieldNameNgram)
+		disjunctionQuery.AddQuery(termQuery)
+	}
+
+	// Names containing the pattern's characters in order may share none of its n-grams
+	initialQuery := bleve.NewPrefixQuery(string([]rune(pattern)[:1]))
+	initialQuery.SetField(indexFieldName)
+	initialQuery.SetBoost(finderInitialBoost)
+	disjunctionQuery.AddQuery(initialQuery)
+
+	return disjunctionQuery
+}
+
+// scoreFileNameMatch scores how well a lower case pattern matches a file name. It returns false
+// if the characters of the pattern do not appear in the name in the same order.
+func scoreFileNameMatch(pattern string, name string) (float64, bool) {
+	nameRunes := []rune(name)
+	lowerNameRunes := make([]rune, len(nameRunes))
+	for i, r := range nameRunes {
+		lowerNameRunes[i] = unicode.ToLower(r)
+	}
+	patternRunes := []rune(pattern)
+
+	lengthPenalty := min(float64(len(nameRunes)-len(patternRunes))*finderPenaltyPerExtraRune, finderMaxLengthPenalty)
+
+	if i := indexOfRunes(lowerNameRunes, patternRunes); i >= 0 {
+		score := finderScoreContiguousMatch
+		switch {
+		case i == 0:
+			score += finderScoreNameStartMatch
+		case isSegmentStart(nameRunes, i):
+			score += finderScoreSegmentMatch
+		}
+		return score - lengthPenalty, true
+	}
+
+	score, ok := scoreSubsequenceMatch(patternRunes, nameRunes, lowerNameRunes)
+	if !ok {
+		return 0, false
+	}
+
+	return score - lengthPenalty, true
+}
+
+// scoreSubsequenceMatch finds the best way of matching the runes of a pattern in order within a name.
+// Runes that follow the previously matched rune or that start a word within the name score higher.
+func scoreSubsequenceMatch(pattern []rune, name []rune, lowerName []rune) (float64, bool) {
+	if len(pattern) == 0 || len(name) == 0 {
+		return 0, false
+	}
+
+	runeScore := func(i int) float64 {
+		if isSegmentStart(name, i) {
+			return finderScoreSegmentRune
+		}
+		return 0
+	}
+
+	// best[i] is the best score for the pattern matched so far, with its last rune matched at position i
+	noMatch := math.Inf(-1)
+	best := make([]float64, len(name))
+	for i := range name {
+		best[i] = noMatch
+		if lowerName[i] == pattern[0] {
+			best[i] = runeScore(i)
+		}
+	}
+
+	for j := 1; j < len(pattern); j++ {
+		next := make([]float64, len(name))
+		// Best score for matches that end before position i-1
+		bestBefore := noMatch
+		for i := range name {
+			next[i] = noMatch
+			if i >= 2 {
+				bestBefore = max(bestBefore, best[i-2])
+			}
+			if lowerName[i] != pattern[j] {
+				continue
+			}
+
+			score := bestBefore
+			if i >= 1 {
+				score = max(score, best[i-1]+finderScoreConsecutiveRune)
+			}
+			if score != noMatch {
+				next[i] = score + runeScore(i)
+			}
+		}
+		best = next
+	}
+
+	score := noMatch
+	for _, s := range best {
+		score = max(score, s)
+	}
+
+	return score, score != noMatch
+}
+
+// isSegmentStart returns true if the rune at i starts a word within a name, e.g. the 'r' in
+// my_report.txt or in quarterlyReport.txt
+func isSegmentStart(name []rune, i int) bool {
+	if i == 0 {
+		return true
+	}
+
+	prev, current := name[i-1], name[i]
+	switch {
+	case strings.ContainsRune("_-. /", prev):
+		return true
+	case unicode.IsLower(prev) && unicode.IsUpper(current):
+		return true
+	case unicode.IsDigit(prev) != unicode.IsDigit(current):
+		return true
+	}
+
+	return false
+}
+
+func indexOfRunes(s []rune, sub []rune) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		found := true
+		for j := range sub {
+			if s[i+j] != sub[j] {
+				found = false
+				break
+			}
+		}
+		if found {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,62 @@
+package searchdb
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var scoreFileNameMatchTestCases = []struct {
+	name          string
+	pattern       string
+	names         []string
+	expectedOrder []string
+}{
+	{
+		name:          "WholeNameBeforeWordBeforeMiddleOfWord",
+		pattern:       "report",
+		names:         []string{"quarterlyreport2024.xlsx", "my_report.md", "report.txt"},
+		expectedOrder: []string{"report.txt", "my_report.md", "quarterlyreport2024.xlsx"},
+	},
+	{
+		name:          "ContiguousBeforeScattered",
+		pattern:       "report",
+		names:         []string{"r_e_p_o_r_t.txt", "quarterlyreport2024.xlsx"},
+		expectedOrder: []string{"quarterlyreport2024.xlsx", "r_e_p_o_r_t.txt"},
+	},
+	{
+		name:          "WordStartsBeforeScattered",
+		pattern:       "qrep",
+		names:         []string{"quarrelsomeperson.txt", "quarterlyReport.xlsx"},
+		expectedOrder: []string{"quarterlyReport.xlsx", "quarrelsomeperson.txt"},
+	},
+	{
+		name:          "CharactersOutOfOrderDoNotMatch",
+		pattern:       "troper",
+		names:         []string{"report.txt", "tropers.txt"},
+		expectedOrder: []string{"tropers.txt"},
+	},
+}
+
+func TestScoreFileNameMatch(t *testing.T) {
+	for _, testCase := range scoreFileNameMatchTestCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert := require.New(t)
+
+			scores := map[string]float64{}
+			var matchedNames []string
+			for _, name := range testCase.names {
+				if score, ok := scoreFileNameMatch(testCase.pattern, name); ok {
+					scores[name] = score
+					matchedNames = append(matchedNames, name)
+				}
+			}
+			sort.SliceStable(matchedNames, func(i, j int) bool {
+				return scores[matchedNames[i]] > scores[matchedNames[j]]
+			})
+
+			assert.Equal(testCase.expectedOrder, matchedNames, "matched names should be in the expected order")
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package searchdb
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// FuzzinessAuto picks the fuzziness of each query term based on its length
+const FuzzinessAuto = -1
+
+// Bleve does not support fuzzy matching with an edit distance of more than 2
+const maxFuzziness = 2
+
+// fuzzinessForTerm returns the maximum edit distance allowed when matching a term with typos. Short terms
+// are never matched fuzzily, since almost every other short word is only an edit or two away from them.
+// Neither are terms with digits, like file1 or v2, where a single changed character is a different term.
+func (b *BleveDB) fuzzinessForTerm(term string, fuzziness int) int {
+	if strings.ContainsFunc(term, unicode.IsDigit) {
+		return 0
+	}
+
+	length := utf8.RuneCountInString(term)
+
+	if fuzziness == FuzzinessAuto {
+		switch {
+		case length >= b.fuzzyMinTermLengthForTwoEdits:
+			return 2
+		case length >= b.fuzzyMinTermLengthForOneEdit:
+			return 1
+		default:
+			return 0
+		}
+	}
+
+	// A term must keep at least one of its characters
+	if length <= fuzziness {
+		return 0
+	}
+
+	return min(fuzziness, maxFuzziness)
+}
+
+// buildFuzzySubQueries adds queries matching the content and file name against the term with typos
+func (b *BleveDB) buildFuzzySubQueries(query *query.DisjunctionQuery, term string, options queryOptions) {
+	if options.fuzziness == 0 {
+		return
+	}
+
+	for _, token := range b.analyzeQueryText(term) {
+		fuzziness := b.fuzzinessForTerm(token, options.fuzziness)
+		if fuzziness == 0 {
+			continue
+		}
+
+		contentFuzzyQuery := bleve.NewFuzzyQuery(token)
+		contentFuzzyQuery.SetField(indexFieldContent)
+		contentFuzzyQuery.SetFuzziness(fuzziness)
+		contentFuzzyQuery.SetBoost(options.profile.FuzzyContent)
+		query.AddQuery(contentFuzzyQuery)
+
+		nameFuzzyQuery := bleve.NewFuzzyQuery(token)
+		nameFuzzyQuery.SetField(indexFieldName)
+		nameFuzzyQuery.SetFuzziness(fuzziness)
+		nameFuzzyQuery.SetBoost(options.profile.FuzzyFileName)
+		query.AddQuery(nameFuzzyQuery)
+	}
+}
+
+// analyzeQueryText returns the terms that query text is split into when searching
+func (b *BleveDB) analyzeQueryText(text string) []string {
+	analyzer := b.index.Mapping().AnalyzerNamed(queryAnalyzer)
+	if analyzer == nil {
+		return strings.Fields(strings.ToLower(text))
+	}
+
+	var terms []string
+	for _, token := range analyzer.Analyze([]byte(text)) {
+		terms = append(terms, string(token.Term))
+	}
+
+	return terms
+}
+
+// findFuzzyTerms returns the query terms that were matched with typos in any of the hits, along with
+// the terms they matched. Terms in quoted phrases are never matched fuzzily.
+func (b *BleveDB) findFuzzyTerms(queryString string, options queryOptions, hits search.DocumentMatchCollection) map[string][]string {
+	if options.fuzziness == 0 || len(hits) == 0 {
+		return nil
+	}
+
+	_, remainingTerms := parseQuotedQuery(strings.ToLower(queryString))
+
+	fuzziness := map[string]int{}
+	for _, term := range b.analyzeQueryText(remainingTerms) {
+		if f := b.fuzzinessForTerm(term, options.fuzziness); f > 0 {
+			fuzziness[term] = f
+		}
+	}
+	if len(fuzziness) == 0 {
+		return nil
+	}
+
+	matchedTerms := map[string]struct{}{}
+	for _, hit := range hits {
+		for _, field := range []string{indexFieldContent, indexFieldName} {
+			for matchedTerm := range hit.Locations[field] {
+				matchedTerms[matchedTerm] = struct{}{}
+			}
+		}
+	}
+
+	fuzzyTerms := map[string][]string{}
+	for term, maxDistance := range fuzziness {
+		// A term that matched exactly was not misspelt
+		if _, ok := matchedTerms[term]; ok {
+			continue
+		}
+
+		for matchedTerm := range matchedTerms {
+			// Terms starting with the query term were matched by prefix queries
+			if strings.HasPrefix(matchedTerm, term) {
+				continue
+			}
+			if editDistance(term, matchedTerm) <= maxDistance {
+				fuzzyTerms[term] = append(fuzzyTerms[term], matchedTerm)
+			}
+		}
+		sort.Strings(fuzzyTerms[term])
+	}
+
+	for term, matches := range fuzzyTerms {
+		if len(matches) == 0 {
+			delete(fuzzyTerms, term)
+		}
+	}
+	if len(fuzzyTerms) == 0 {
+		return nil
+	}
+
+	return fuzzyTerms
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a string, b string) int {
+	aRunes, bRunes := []rune(a), []rune(b)
+
+	previous := make([]int, len(bRunes)+1)
+	current := make([]int, len(bRunes)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(aRunes); i++ {
+		current[0] = i
+		for j := 1; j <= len(bRunes); j++ {
+			substitutionCost := 1
+			if aRunes[i-1] == bRunes[j-1] {
+				substitutionCost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+substitutionCost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(bRunes)]
+}
@@ -0,0 +1,77 @@
+package searchdb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var fuzzinessForTermTestCases = []struct {
+	name              string
+	term              string
+	fuzziness         int
+	expectedFuzziness int
+}{
+	{
+		name:              "AutoShortTerm",
+		term:              "code",
+		fuzziness:         FuzzinessAuto,
+		expectedFuzziness: 0,
+	},
+	{
+		name:              "AutoMediumTerm",
+		term:              "query",
+		fuzziness:         FuzzinessAuto,
+		expectedFuzziness: 1,
+	},
+	{
+		name:              "AutoLongTerm",
+		term:              "markdown",
+		fuzziness:         FuzzinessAuto,
+		expectedFuzziness: 2,
+	},
+	{
+		name:              "AutoTermWithDigits",
+		term:              "version2024",
+		fuzziness:         FuzzinessAuto,
+		expectedFuzziness: 0,
+	},
+	{
+		name:              "ExplicitFuzziness",
+		term:              "code",
+		fuzziness:         2,
+		expectedFuzziness: 2,
+	},
+	{
+		name:              "ExplicitFuzzinessTooLargeForTerm",
+		term:              "go",
+		fuzziness:         2,
+		expectedFuzziness: 0,
+	},
+	{
+		name:              "Off",
+		term:              "markdown",
+		fuzziness:         0,
+		expectedFuzziness: 0,
+	},
+}
+
+func TestFuzzinessForTerm(t *testing.T) {
+	b := &BleveDB{fuzzyMinTermLengthForOneEdit: 5, fuzzyMinTermLengthForTwoEdits: 8}
+
+	for _, testCase := range fuzzinessForTermTestCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			require.Equal(t, testCase.expectedFuzziness, b.fuzzinessForTerm(testCase.term, testCase.fuzziness))
+		})
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal(0, editDistance("markdown", "markdown"))
+	assert.Equal(1, editDistance("markdowm", "markdown"))
+	assert.Equal(2, editDistance("markdwon", "markdown"))
+	assert.Equal(3, editDistance("", "abc"))
+	assert.Equal(1, editDistance("café", "cafe"))
+}
@@ -0,0 +1,335 @@
+package searchdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// Search modes that match a pattern against the lines of a file
+const (
+	// SearchModeRegex matches a regular expression, in the syntax of Go's regexp package
+	SearchModeRegex = "regex"
+	// SearchModeLiteral matches an exact string
+	SearchModeLiteral = "literal"
+)
+
+var ErrInvalidPattern = errors.New("invalid pattern")
+
+const (
+	// Only this many matching lines of a file are returned
+	maxGrepLinesPerFile = 100
+	// Matching lines are cut short after this many bytes
+	maxGrepLineLength = 500
+	// Only this many bytes of a file on disk are scanned, as no more of it is indexed
+	maxGrepFileSize = 5 * 1024 * 1024
+	// Shorter words cut off by the end of a literal match too many terms to narrow down the candidates
+	minPartialWordLength = 3
+)
+
+// Characters that always separate words, whichever analyzer was used to index a document. Characters like
+// '.' and '-' are left out, since they separate words in some analyzers and not in others.
+const wordSeparators = `()[]{}<>!?"=+*/\&|^%$#@~`
+
+// LineMatch is a line of a file matching a pattern
+type LineMatch struct {
+	Number int    `json:"number"`
+	Text   string `json:"text"`
+}
+
+// searchPattern finds the lines of files matching a pattern, the way grep does. The index narrows down the
+// files to those containing the words the pattern requires, and then their stored content, or otherwise the
+// files on disk, is scanned. Files are returned in order of their paths. Scanning stops once there are too many candidate files or too
+// much time has passed, in which case the response is marked as partial.
+func (b *BleveDB) searchPattern(params SearchParams) (*Response, error) {
+	pattern := params.Query
+	if params.Mode == SearchModeLiteral {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+
+	// Matches may span lines, while ^ and $ still match at the start and end of each line
+	re, err := regexp.Compile("(?m)" + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPattern, err.Error())
+	}
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPattern, err.Error())
+	}
+
+	candidateQuery := b.buildPatternCandidateQuery(requiredLiterals(parsed.Simplify()))
+
+	// One more candidate than allowed is fetched, to tell whether there were too many
+	searchRequest := bleve.NewSearchRequestOptions(withFilters(candidateQuery, buildFilterQueries(params)), b.grepMaxCandidates+1, 0, false)
+	searchRequest.Fields = []string{indexFieldPath, indexFieldName, indexFieldSize, indexFieldModTime}
+	searchRequest.SortBy([]string{indexFieldPath})
+
+	searchResult, err := b.index.Search(searchRequest)
+	if err != nil {
+		b.logger.Error("pattern search failed", "err", err.Error())
+		return nil, fmt.Errorf("pattern search failed: %w", err)
+	}
+
+	hits := searchResult.Hits
+	partial := false
+	if len(hits) > b.grepMaxCandidates {
+		b.logger.Warn("pattern matches too many candidate files, only some are scanned", "pattern", params.Query, "max_candidates", b.grepMaxCandidates)
+		hits = hits[:b.grepMaxCandidates]
+		partial = true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.grepTimeout)
+	defer cancel()
+
+	var matches []Result
+	for _, hit := range hits {
+		if ctx.Err() != nil {
+			b.logger.Warn("pattern search took too long, only some candidate files were scanned", "pattern", params.Query, "timeout", b.grepTimeout.String())
+			partial = true
+			break
+		}
+
+		result := newResult(hit)
+		lines, err := b.grepDocument(ctx, re, result.Path)
+		if err != nil {
+			b.logger.Warn("could not scan file for pattern", "path", result.Path, "err", err.Error())
+		}
+		if len(lines) == 0 {
+			continue
+		}
+
+		result.Lines = lines
+		result.Snippet = lines[0].Text
+		matches = append(matches, result)
+	}
+
+	return &Response{
+		Results: matches[min(params.Offset, len(matches)):min(params.Offset+params.Limit, len(matches))],
+		Total:   uint64(len(matches)),
+		Partial: partial,
+	}, nil
+}
+
+// grepDocument returns the lines of a file matching a regular expression. Stored content is what was indexed,
+// so it is scanned instead of the file if there is any, even if the file changed, moved or is not text.
+func (b *BleveDB) grepDocument(ctx context.Context, re *regexp.Regexp, filePath string) ([]LineMatch, error) {
+	if b.contents != nil {
+		content, ok, err := b.contents.Get(filePath)
+		if err != nil {
+			b.logger.Warn("failed to read stored content for pattern search", "path", filePath, "err", err.Error())
+		}
+		if ok {
+			return grepContent(ctx, re, content)
+		}
+	}
+
+	if !b.isTextFile(filePath) {
+		return nil, nil
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, maxGrepFileSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return grepContent(ctx, re, string(content))
+}
+
+// grepContent returns the lines of content matching a regular expression, including every line a match spans.
+// The search resumes on the line after the end of each match.
+func grepContent(ctx context.Context, re *regexp.Regexp, content string) ([]LineMatch, error) {
+	// Windows line endings are read as newlines, so that $ matches at the end of their lines
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	var lines []LineMatch
+	lineNumber := 1
+	// Searches start at the start of a line, so that word boundaries are the same as in the whole content
+	for pos := 0; pos < len(content) && len(lines) < maxGrepLinesPerFile; {
+		if err := ctx.Err(); err != nil {
+			return lines, err
+		}
+
+		loc := re.FindStringIndex(content[pos:])
+		if loc == nil {
+			break
+		}
+		start, end := pos+loc[0], pos+loc[1]
+
+		lineStart := strings.LastIndexByte(content[:start], '\n') + 1
+		// An empty match after a final newline is not on any line
+		if lineStart == len(content) {
+			break
+		}
+		lineNumber += strings.Count(content[pos:lineStart], "\n")
+
+		lineEnd := len(content)
+		if i := strings.IndexByte(content[max(start, end-1):], '\n'); i >= 0 {
+			lineEnd = max(start, end-1) + i
+		}
+
+		for _, line := range strings.Split(content[lineStart:lineEnd], "\n") {
+			if len(lines) == maxGrepLinesPerFile {
+				break
+			}
+			lines = append(lines, LineMatch{Number: lineNumber, Text: truncateLine(line)})
+			lineNumber++
+		}
+		pos = lineEnd + 1
+	}
+
+	return lines, nil
+}
+
+func truncateLine(line string) string {
+	if len(line) <= maxGrepLineLength {
+		return line
+	}
+
+	end := maxGrepLineLength
+	for end > 0 && !utf8.RuneStart(line[end]) {
+		end--
+	}
+	return line[:end] + "..."
+}
+
+// requiredLiterals returns strings that every match of a simplified regular expression contains
+func requiredLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min > 0 {
+			return requiredLiterals(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		var literals []string
+		var current strings.Builder
+		for _, sub := range re.Sub {
+			// Literals next to each other, including those in groups, make up a longer literal
+			if literal, ok := literalOf(sub); ok {
+				current.WriteString(literal)
+				continue
+			}
+			if current.Len() > 0 {
+				literals = append(literals, current.String())
+				current.Reset()
+			}
+			literals = append(literals, requiredLiterals(sub)...)
+		}
+		if current.Len() > 0 {
+			literals = append(literals, current.String())
+		}
+		return literals
+	}
+
+	return nil
+}
+
+func literalOf(re *syntax.Regexp) (string, bool) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return string(re.Rune), true
+	case syntax.OpCapture:
+		return literalOf(re.Sub[0])
+	}
+	return "", false
+}
+
+// buildPatternCandidateQuery builds a query for documents containing the words of the required literals of
+// a pattern. Whole words are looked up as terms, while words cut off by the end of a literal may be the start
+// of longer terms and are looked up using prefixes. Words cut off by the start of a literal are left out, since
+// looking up terms ending with them would walk every term in the index. Without any usable words, every
+// document is a candidate.
+func (b *BleveDB) buildPatternCandidateQuery(literals []string) query.Query {
+	conjunctionQuery := bleve.NewConjunctionQuery()
+
+	for _, literal := range literals {
+		for _, word := range splitLiteralWords(literal) {
+			if !word.boundedBefore {
+				continue
+			}
+
+			term := strings.ToLower(word.text)
+
+			// Stop words are not indexed for text documents
+			if terms := b.analyzeQueryText(term); len(terms) != 1 || terms[0] != term {
+				continue
+			}
+
+			if !word.boundedAfter && utf8.RuneCountInString(term) < minPartialWordLength {
+				continue
+			}
+
+			var wordQuery query.FieldableQuery = bleve.NewPrefixQuery(term)
+			if word.boundedAfter {
+				wordQuery = bleve.NewTermQuery(term)
+			}
+			wordQuery.SetField(indexFieldContent)
+			conjunctionQuery.AddQuery(wordQuery)
+		}
+	}
+
+	if len(conjunctionQuery.Conjuncts) == 0 {
+		return bleve.NewMatchAllQuery()
+	}
+
+	return conjunctionQuery
+}
+
+// literalWord is a run of letters, digits and underscores within a literal. A word is bounded on a side if
+// a word separator is next to it on that side, rather than the start or end of the literal.
+type literalWord struct {
+	text          string
+	boundedBefore bool
+	boundedAfter  bool
+}
+
+func splitLiteralWords(literal string) []literalWord {
+	isWordRune := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+	}
+	isSeparator := func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(wordSeparators, r)
+	}
+
+	runes := []rune(literal)
+	var words []literalWord
+	for start := 0; start < len(runes); {
+		if !isWordRune(runes[start]) {
+			start++
+			continue
+		}
+
+		end := start
+		for end < len(runes) && isWordRune(runes[end]) {
+			end++
+		}
+
+		words = append(words, literalWord{
+			text:          string(runes[start:end]),
+			boundedBefore: start > 0 && isSeparator(runes[start-1]),
+			boundedAfter:  end < len(runes) && isSeparator(runes[end]),
+		})
+		start = end
+	}
+
+	return words
+}
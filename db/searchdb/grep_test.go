@@ -0,0 +1,141 @@
+package searchdb
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"regexp/syntax"
+	"testing"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/standard"
+	"github.com/stretchr/testify/require"
+)
+
+var requiredLiteralsTestCases = []struct {
+	name             string
+	pattern          string
+	expectedLiterals []string
+}{
+	{
+		name:             "Literal",
+		pattern:          `TODO\(alice\)`,
+		expectedLiterals: []string{"TODO(alice)"},
+	},
+	{
+		name:             "LiteralsAroundClass",
+		pattern:          `err != nil \{\s*return`,
+		expectedLiterals: []string{"err != nil {", "return"},
+	},
+	{
+		name:             "LiteralInGroup",
+		pattern:          `func (parse)Query`,
+		expectedLiterals: []string{"func parseQuery"},
+	},
+	{
+		name:             "RequiredRepetition",
+		pattern:          `(retry)+ed`,
+		expectedLiterals: []string{"retry", "ed"},
+	},
+	{
+		name:             "OptionalParts",
+		pattern:          `colou?r|gr[ae]y`,
+		expectedLiterals: nil,
+	},
+}
+
+func TestRequiredLiterals(t *testing.T) {
+	for _, testCase := range requiredLiteralsTestCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert := require.New(t)
+			parsed, err := syntax.Parse(testCase.pattern, syntax.Perl)
+			assert.NoError(err, "pattern should be valid")
+
+			assert.Equal(testCase.expectedLiterals, requiredLiterals(parsed.Simplify()))
+		})
+	}
+}
+
+func TestSplitLiteralWords(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal([]literalWord{
+		{text: "err", boundedBefore: false, boundedAfter: true},
+		{text: "nil", boundedBefore: true, boundedAfter: true},
+		{text: "file2", boundedBefore: true, boundedAfter: false},
+		{text: "go", boundedBefore: false, boundedAfter: false},
+	}, splitLiteralWords("err != nil {file2.go"), "words cut off by the literal or next to dots should not be bounded")
+}
+
+const grepTestContent = "package main\r\n\nfunc run() error {\n\tif err != nil {\n\t\treturn err\n\t}\n\treturn nil\n}\n"
+
+var grepContentTestCases = []struct {
+	name          string
+	pattern       string
+	expectedLines []LineMatch
+}{
+	{
+		name:    "AcrossLines",
+		pattern: `err != nil \{\s*return`,
+		expectedLines: []LineMatch{
+			{Number: 4, Text: "\tif err != nil {"},
+			{Number: 5, Text: "\t\treturn err"},
+		},
+	},
+	{
+		name:    "SeveralMatches",
+		pattern: `return`,
+		expectedLines: []LineMatch{
+			{Number: 5, Text: "\t\treturn err"},
+			{Number: 7, Text: "\treturn nil"},
+		},
+	},
+	{
+		name:          "Anchors",
+		pattern:       `^func|main$`,
+		expectedLines: []LineMatch{{Number: 1, Text: "package main"}, {Number: 3, Text: "func run() error {"}},
+	},
+	{
+		name:          "NoMatch",
+		pattern:       `panic\(`,
+		expectedLines: nil,
+	},
+}
+
+func TestGrepContent(t *testing.T) {
+	for _, testCase := range grepContentTestCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert := require.New(t)
+
+			lines, err := grepContent(context.Background(), regexp.MustCompile("(?m)"+testCase.pattern), grepTestContent)
+			assert.NoError(err, "content should be scanned")
+			assert.Equal(testCase.expectedLines, lines)
+		})
+	}
+}
+
+func TestBuildPatternCandidateQuery(t *testing.T) {
+	assert := require.New(t)
+
+	b := &BleveDB{
+		name:         DefaultCollection,
+		indexPath:    filepath.Join(t.TempDir(), "search.index"),
+		textAnalyzer: standard.Name,
+		logger:       slog.New(slog.NewTextHandler(os.Stderr, nil)),
+	}
+	assert.NoError(b.open(false))
+	defer b.Close()
+
+	nilQuery := bleve.NewTermQuery("nil")
+	nilQuery.SetField(indexFieldContent)
+	assert.Equal(bleve.NewConjunctionQuery(nilQuery), b.buildPatternCandidateQuery([]string{"err != nil {", "return"}),
+		"words cut off by the start of a literal should be left out")
+
+	prefixQuery := bleve.NewPrefixQuery("pars")
+	prefixQuery.SetField(indexFieldContent)
+	assert.Equal(bleve.NewConjunctionQuery(prefixQuery), b.buildPatternCandidateQuery([]string{"func pars"}))
+
+	assert.Equal(bleve.NewMatchAllQuery(), b.buildPatternCandidateQuery([]string{"TODO"}))
+}
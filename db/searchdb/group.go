@@ -0,0 +1,122 @@
+package searchdb
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// Fields results can be grouped by
+const (
+	// GroupByDir groups results by the directory containing them
+	GroupByDir = "dir"
+	// GroupByRoot groups results by the indexed root directory they were found under
+	GroupByRoot = "root"
+	// GroupByExt groups results by their extension
+	GroupByExt = "ext"
+)
+
+var ErrGroupingNotSupported = errors.New("results can only be grouped in keyword mode")
+
+// Only this many groups are counted, however many there are
+const maxGroups = 1000
+
+const groupFacetName = "groups"
+
+var groupFields = map[string]string{
+	GroupByDir:  indexFieldDir,
+	GroupByRoot: indexFieldRoot,
+	GroupByExt:  indexFieldExt,
+}
+
+// searchGroups finds documents matching the terms of a query and groups them by directory, root or extension.
+// Groups are ordered by their number of results, and Limit and Offset page through them. Each group holds its
+// top GroupSize results, ranked the same way as ungrouped results. Documents without a value for the field,
+// like files without an extension, are not in any group.
+func (b *BleveDB) searchGroups(params SearchParams) (*Response, error) {
+	if len(params.Mode) > 0 && params.Mode != SearchModeKeyword {
+		return nil, ErrGroupingNotSupported
+	}
+
+	groupField, ok := groupFields[params.GroupBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown field to group by: %s", params.GroupBy)
+	}
+
+	profile, err := b.rankingProfile(params.RankingProfile)
+	if err != nil {
+		return nil, err
+	}
+	options := queryOptions{fuzziness: params.Fuzziness, profile: profile, languages: b.queryLanguages(params)}
+	filters := buildFilterQueries(params)
+	searchQuery := b.buildSearchQuery(params.Query, options)
+	expansions := b.expandSynonyms(params.Query)
+	synonymTerms := b.synonymTerms(expansions)
+
+	// Only the counts of the groups are needed to pick the groups on the page
+	facetRequest := bleve.NewSearchRequestOptions(withFilters(searchQuery, filters), 0, 0, false)
+	facetRequest.AddFacet(groupFacetName, bleve.NewFacetRequest(groupField, maxGroups))
+
+	facetResult, err := b.index.Search(facetRequest)
+	if err != nil {
+		b.logger.Error("search for groups failed", "err", err.Error())
+		return nil, fmt.Errorf("search for groups failed: %w", err)
+	}
+
+	response := &Response{
+		Total:    facetResult.Total,
+		MaxScore: facetResult.MaxScore,
+		Synonyms: expansions,
+	}
+	if params.Explain {
+		response.Query = b.explainQuery(withFilters(searchQuery, filters))
+	}
+
+	facet, ok := facetResult.Facets[groupFacetName]
+	if !ok || facet.Terms == nil {
+		return response, nil
+	}
+
+	terms := facet.Terms.Terms()
+	response.TotalGroups = len(terms)
+	pageTerms := terms[min(params.Offset, len(terms)):min(params.Offset+params.Limit, len(terms))]
+
+	now := time.Now()
+	response.Groups = make([]Group, 0, len(pageTerms))
+	for _, term := range pageTerms {
+		groupQuery := bleve.NewTermQuery(term.Term)
+		groupQuery.SetField(groupField)
+
+		searchRequest := bleve.NewSearchRequestOptions(withFilters(searchQuery, append(filters, groupQuery)), rankingHits(params.GroupSize, profile), 0, params.Explain)
+		searchRequest.Fields = []string{indexFieldPath, indexFieldName, indexFieldSize, indexFieldModTime, indexFieldRoot}
+		searchRequest.IncludeLocations = true
+
+		searchResult, err := b.index.Search(searchRequest)
+		if err != nil {
+			b.logger.Error("search within group failed", "group", term.Term, "err", err.Error())
+			return nil, fmt.Errorf("search within group failed: %w", err)
+		}
+
+		hits := searchResult.Hits
+		rankHits(hits, profile, params.Query, now)
+		hits = hits[:min(params.GroupSize, len(hits))]
+
+		results := make([]Result, len(hits))
+		for i, hit := range hits {
+			result := newResult(hit)
+			result.Snippet, result.Highlights = b.extractSnippet(result.Path, hit.Locations, synonymTerms)
+			result.Explanation = newExplanation(hit.Expl)
+			results[i] = result
+		}
+
+		response.Groups = append(response.Groups, Group{
+			Key:     term.Term,
+			Total:   searchResult.Total,
+			Results: results,
+		})
+	}
+
+	return response, nil
+}
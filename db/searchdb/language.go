@@ -0,0 +1,202 @@
+package searchdb
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis"
+	"github.com/blevesearch/bleve/v2/analysis/lang/de"
+	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/analysis/lang/es"
+	"github.com/blevesearch/bleve/v2/analysis/lang/fr"
+	"github.com/blevesearch/bleve/v2/analysis/lang/it"
+	"github.com/blevesearch/bleve/v2/analysis/lang/nl"
+	"github.com/blevesearch/bleve/v2/analysis/lang/pt"
+	"github.com/blevesearch/bleve/v2/registry"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// Languages that documents are detected to be in. Text documents in one of them have their content indexed
+// a second time with the language's analyzer, which stems words and removes the language's stop words.
+var Languages = []string{en.AnalyzerName, de.AnalyzerName, fr.AnalyzerName, es.AnalyzerName, it.AnalyzerName, nl.AnalyzerName, pt.AnalyzerName}
+
+var languageStopWordsNames = map[string]string{
+	en.AnalyzerName: en.StopName,
+	de.AnalyzerName: de.StopName,
+	fr.AnalyzerName: fr.StopName,
+	es.AnalyzerName: es.StopName,
+	it.AnalyzerName: it.StopName,
+	nl.AnalyzerName: nl.StopName,
+	pt.AnalyzerName: pt.StopName,
+}
+
+var ErrLanguageFilterNotSupported = errors.New("results can only be filtered by language in keyword, regex and literal modes")
+
+const (
+	// Only the start of a document is looked at to detect its language
+	maxLanguageDetectionLength = 64 * 1024
+	// A document needs at least this many stop words of a language to be detected as being in it
+	minLanguageStopWords = 3
+)
+
+const languageFacetName = "languages"
+
+var (
+	languageStopWordsOnce sync.Once
+	languageStopWords     map[string]analysis.TokenMap
+)
+
+func loadLanguageStopWords() map[string]analysis.TokenMap {
+	languageStopWordsOnce.Do(func() {
+		cache := registry.NewCache()
+		languageStopWords = map[string]analysis.TokenMap{}
+		for lang, stopWordsName := range languageStopWordsNames {
+			stopWords, err := cache.TokenMapNamed(stopWordsName)
+			if err != nil {
+				continue
+			}
+			languageStopWords[lang] = stopWords
+		}
+	})
+
+	return languageStopWords
+}
+
+// DetectLanguage returns the language text is most likely in, going by which language's stop words it has
+// the most of, or an empty string if it is not clearly in any of the supported languages
+func DetectLanguage(text string) string {
+	if len(text) > maxLanguageDetectionLength {
+		text = text[:maxLanguageDetectionLength]
+	}
+
+	words := map[string]int{}
+	start := -1
+	for i, r := range text + " " {
+		if unicode.IsLetter(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			words[strings.ToLower(text[start:i])]++
+			start = -1
+		}
+	}
+
+	bestLanguage := ""
+	bestCount, secondBestCount := 0, 0
+	for _, lang := range Languages {
+		stopWords := loadLanguageStopWords()[lang]
+		count := 0
+		for word, occurrences := range words {
+			if stopWords[word] {
+				count += occurrences
+			}
+		}
+
+		switch {
+		case count > bestCount:
+			bestLanguage, bestCount, secondBestCount = lang, count, bestCount
+		case count > secondBestCount:
+			secondBestCount = count
+		}
+	}
+
+	// Languages share some stop words, so a tie says nothing about which one the text is in
+	if bestCount < minLanguageStopWords || bestCount == secondBestCount {
+		return ""
+	}
+
+	return bestLanguage
+}
+
+// languageContentField returns the sub-field holding the content of documents in a language, analyzed with
+// the language's analyzer
+func languageContentField(lang string) string {
+	return indexFieldContent + "_" + lang
+}
+
+// indexedLanguages returns the languages that indexed documents are in, as of when the index last changed
+func (b *BleveDB) indexedLanguages() []string {
+	if languages := b.languages.Load(); languages != nil {
+		return *languages
+	}
+	return nil
+}
+
+// loadLanguages finds the languages that indexed documents are in, for searches to look in the
+// language-specific content of
+func (b *BleveDB) loadLanguages() error {
+	var languages []string
+	err := b.visitFieldTerms(indexFieldLang, func(lang string, _ int) {
+		// Documents whose language was not detected have an empty language
+		if len(lang) > 0 {
+			languages = append(languages, lang)
+		}
+	})
+	if err != nil {
+		b.logger.Error("could not find indexed languages", "err", err.Error())
+		return err
+	}
+
+	b.languages.Store(&languages)
+
+	return nil
+}
+
+// buildStemmedSubQuery adds a query matching a term in the language-specific content of documents, where
+// other forms of the term, like running for run, match as well. Documents in a language only match the
+// term as analyzed for that language.
+func (b *BleveDB) buildStemmedSubQuery(query *query.DisjunctionQuery, term string, options queryOptions) {
+	languages := options.languages
+	if len(languages) == 0 {
+		return
+	}
+
+	// The languages are grouped into a single query, so that a term adds one alternative however many
+	// languages there are
+	stemmedQuery := bleve.NewDisjunctionQuery()
+	for _, lang := range languages {
+		languageQuery := bleve.NewMatchQuery(term)
+		languageQuery.Analyzer = lang
+		languageQuery.SetField(languageContentField(lang))
+		languageQuery.SetBoost(options.profile.Content * options.profile.Stemmed)
+		stemmedQuery.AddQuery(languageQuery)
+	}
+	query.AddQuery(stemmedQuery)
+}
+
+// queryLanguages returns the languages whose language-specific content a search looks in
+func (b *BleveDB) queryLanguages(params SearchParams) []string {
+	if len(params.Lang) > 0 {
+		return []string{params.Lang}
+	}
+	return b.indexedLanguages()
+}
+
+// textDocumentTypeForLanguage returns the type of text documents in a language
+func textDocumentTypeForLanguage(lang string) string {
+	return DocumentTypeText + "_" + lang
+}
+
+// newFacetTerms returns the non-empty terms of a facet along with their counts, with the most common first
+func newFacetTerms(facet *search.FacetResult) []FacetTerm {
+	if facet == nil || facet.Terms == nil {
+		return nil
+	}
+
+	var terms []FacetTerm
+	for _, term := range facet.Terms.Terms() {
+		if len(term.Term) == 0 {
+			continue
+		}
+		terms = append(terms, FacetTerm{Term: term.Term, Count: term.Count})
+	}
+
+	return terms
+}
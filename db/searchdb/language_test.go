@@ -0,0 +1,55 @@
+package searchdb
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/standard"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{name: "English", text: "The cache is warmed up at startup and the entries that are not used are evicted.", expected: "en"},
+		{name: "German", text: "Der Cache wird beim Start aufgewärmt und die Einträge, die nicht benutzt werden, werden entfernt.", expected: "de"},
+		{name: "French", text: "Le cache est préchauffé au démarrage et les entrées qui ne sont pas utilisées sont supprimées.", expected: "fr"},
+		{name: "TooFewStopWords", text: "Notes on cache eviction.", expected: ""},
+		{name: "NoWords", text: "42 == 42", expected: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, DetectLanguage(test.text))
+		})
+	}
+}
+
+func TestIndexedLanguages(t *testing.T) {
+	assert := require.New(t)
+
+	b := &BleveDB{
+		name:         DefaultCollection,
+		indexPath:    filepath.Join(t.TempDir(), "search.index"),
+		textAnalyzer: standard.Name,
+		logger:       slog.New(slog.NewTextHandler(os.Stderr, nil)),
+	}
+	assert.NoError(b.open(false))
+	defer b.Close()
+
+	assert.Empty(b.indexedLanguages())
+
+	assert.NoError(b.BuildIndex([]*Document{
+		{ID: "/notes/cache.md", Path: "/notes/cache.md", Type: textDocumentTypeForLanguage("en"), Lang: "en", Content: "the cache is evicted"},
+		{ID: "/notes/unknown.md", Path: "/notes/unknown.md", Type: DocumentTypeText, Content: "zzz"},
+	}))
+	assert.Empty(b.indexedLanguages(), "languages should only be reloaded once the index is done changing")
+
+	assert.NoError(b.Flush())
+	assert.Equal([]string{"en"}, b.indexedLanguages())
+}
@@ -1,6 +1,15 @@
 package searchdb
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
+
+// Document types decide which analyzer is used for a document's name and content
+const (
+	DocumentTypeText = "text"
+	DocumentTypeCode = "code"
+)
 
 type Document struct {
 	ID      string    `json:"id"`
@@ -9,6 +18,56 @@ type Document struct {
 	Content string    `json:"content"`
 	Size    int64     `json:"size"`
 	ModTime time.Time `json:"mod_time"`
+	Type    string    `json:"type"`
+	// Root is the directory that was indexed to find the document
+	Root string `json:"root"`
+	// Ext is the lower case extension of the document's name, including the dot
+	Ext string `json:"ext"`
+	// Dir is the directory containing the document
+	Dir string `json:"dir"`
+	// Lang is the language the content of a text document is in, if it was detected
+	Lang string `json:"lang"`
+}
+
+// BleveType tells bleve which document mapping to use for the document
+func (d Document) BleveType() string {
+	if d.Type == "" || d.Type == DocumentTypeText {
+		if len(d.Lang) > 0 {
+			return textDocumentTypeForLanguage(d.Lang)
+		}
+		return DocumentTypeText
+	}
+	return d.Type
+}
+
+// SearchParams holds a search query along with the paging and filters to apply to its results
+type SearchParams struct {
+	Query  string
+	Limit  int
+	Offset int
+	// Under restricts results to files within this directory
+	Under string
+	// Fuzziness is the maximum edit distance for matching terms with typos. FuzzinessAuto picks
+	// it based on the length of each term and 0 turns fuzzy matching off.
+	Fuzziness int
+	// AutoCorrect searches for the best suggestion instead if the query has no results
+	AutoCorrect bool
+	// Mode is SearchModeKeyword (the default), SearchModeSemantic, SearchModeHybrid, SearchModeRegex
+	// or SearchModeLiteral
+	Mode string
+	// Lang restricts results to documents in this language
+	Lang string
+	// RankingProfile is the name of the ranking profile used to rank keyword matches, or empty for the default one
+	RankingProfile string
+	// Explain adds the rewritten query and an explanation of the score of each result to the response
+	Explain bool
+	// GroupBy is GroupByDir, GroupByRoot or GroupByExt to group results, in which case Limit and Offset
+	// page through groups rather than results
+	GroupBy string
+	// GroupSize is the number of top results returned for each group
+	GroupSize int
+	// Collections are the names of the collections searched, or empty to search the default one
+	Collections []string
 }
 
 type Result struct {
@@ -19,6 +78,14 @@ type Result struct {
 	Size    int64   `json:"size"`
 	ModTime string  `json:"mod_time"`
 	Snippet string  `json:"snippet"`
+	// Lines are the lines matching the pattern, for regex and literal searches
+	Lines []LineMatch `json:"lines,omitempty"`
+	// Highlights are where synonyms of query terms matched in the snippet
+	Highlights []Highlight `json:"highlights,omitempty"`
+	// Explanation is how the score was calculated, if it was asked for
+	Explanation *Explanation `json:"explanation,omitempty"`
+	// Collection is the collection the result is from, when several collections are searched
+	Collection string `json:"collection,omitempty"`
 }
 
 type Response struct {
@@ -26,4 +93,35 @@ type Response struct {
 	Total      uint64   `json:"total"`
 	MaxScore   float64  `json:"max_score"`
 	SearchTime string   `json:"search_time"`
+	// FuzzyTerms maps query terms to the terms they matched with typos
+	FuzzyTerms map[string][]string `json:"fuzzy_terms,omitempty"`
+	// Synonyms maps query terms to the synonyms they were expanded with
+	Synonyms map[string][]string `json:"synonyms,omitempty"`
+	// Languages counts the results in each language
+	Languages []FacetTerm `json:"languages,omitempty"`
+	// Suggestions are corrected versions of a query that had no results
+	Suggestions []string `json:"suggestions,omitempty"`
+	// CorrectedQuery is the suggestion that was searched for instead, when a query had no results
+	CorrectedQuery string `json:"corrected_query,omitempty"`
+	// Partial is true if a regex or literal search stopped before scanning all the candidate files
+	Partial bool `json:"partial,omitempty"`
+	// Query is the query a search was rewritten into, if an explanation was asked for
+	Query json.RawMessage `json:"query,omitempty"`
+	// Groups holds the results grouped by directory, root or extension when grouping is requested
+	Groups []Group `json:"groups,omitempty"`
+	// TotalGroups is the number of groups with results
+	TotalGroups int `json:"total_groups,omitempty"`
+}
+
+// FacetTerm is a value of a field along with the number of results with it
+type FacetTerm struct {
+	Term  string `json:"term"`
+	Count int    `json:"count"`
+}
+
+// Group is a directory, root or extension along with the number of results in it and the top few of them
+type Group struct {
+	Key     string   `json:"key"`
+	Total   uint64   `json:"total"`
+	Results []Result `json:"results"`
 }
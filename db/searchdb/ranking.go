@@ -0,0 +1,155 @@
+package searchdb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/meghashyamc/wheresthat/config"
+)
+
+var ErrUnknownRankingProfile = errors.New("unknown ranking profile")
+
+// The function-score style boosts of a ranking profile re-rank this many of the top keyword matches, so a
+// result further down can not be moved onto the first pages. Profiles without such boosts do not re-rank, so
+// only the matches on the page are fetched for them.
+const rankingWindow = 100
+
+// rankingProfile returns the ranking profile with a name, or the default one if the name is empty
+func (b *BleveDB) rankingProfile(name string) (config.RankingProfile, error) {
+	if len(name) == 0 {
+		name = b.defaultRankingProfile
+	}
+
+	profile, ok := b.rankingProfiles[name]
+	if !ok {
+		return config.RankingProfile{}, fmt.Errorf("%w: %s", ErrUnknownRankingProfile, name)
+	}
+
+	return profile, nil
+}
+
+// hasRankingBoosts returns true if a ranking profile has any function-score style boosts, which hits are
+// re-ranked by
+func hasRankingBoosts(profile config.RankingProfile) bool {
+	return profile.ExactFileName > 0 ||
+		(profile.Recency > 0 && profile.RecencyHalfLife > 0) ||
+		(profile.DepthPenalty > 0 && profile.MaxDepthPenalty > 0) ||
+		(profile.VendorPenalty > 0 && len(profile.VendorDirs) > 0)
+}
+
+// rankingHits returns how many of the top matches to fetch for a page ending at end, which is more than end if
+// any of the profiles re-ranks them
+func rankingHits(end int, profiles ...config.RankingProfile) int {
+	if slices.ContainsFunc(profiles, hasRankingBoosts) {
+		return max(end, rankingWindow)
+	}
+
+	return end
+}
+
+// rankHits scales the scores of the top rankingWindow hits by the function-score style boosts of a ranking
+// profile and sorts them by their new scores. Hits must include the stored path, name, root and modification time. Explanations of
+// the scores of hits are extended with the boosts.
+func rankHits(hits search.DocumentMatchCollection, profile config.RankingProfile, queryString string, now time.Time) {
+	rankHitsByProfile(hits, func(*search.DocumentMatch) config.RankingProfile { return profile }, queryString, now)
+}
+
+// rankHitsByProfile is rankHits with the ranking profile of each hit given by profileOf, for hits from
+// collections ranked with different profiles
+func rankHitsByProfile(hits search.DocumentMatchCollection, profileOf func(*search.DocumentMatch) config.RankingProfile, queryString string, now time.Time) {
+	// Hits past the window keep their keyword scores and order, so that every page of results is ordered the
+	// same way however many hits were fetched for it
+	hits = hits[:min(rankingWindow, len(hits))]
+	queryString = strings.ToLower(strings.Trim(strings.TrimSpace(queryString), `"`))
+
+	for _, hit := range hits {
+		factor, boosts := rankingFactor(hit, profileOf(hit), queryString, now)
+		if len(boosts) == 0 {
+			continue
+		}
+		score := hit.Score * factor
+		if hit.Expl != nil {
+			hit.Expl = &search.Explanation{
+				Value:    score,
+				Message:  "product of:",
+				Children: []*search.Explanation{hit.Expl, {Value: factor, Message: "ranking profile boosts, product of:", Children: boosts}},
+			}
+		}
+		hit.Score = score
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+}
+
+// rankingFactor returns what the score of a hit is multiplied by, along with the boosts making it up
+func rankingFactor(hit *search.DocumentMatch, profile config.RankingProfile, queryString string, now time.Time) (float64, []*search.Explanation) {
+	path, _ := hit.Fields[indexFieldPath].(string)
+	name, _ := hit.Fields[indexFieldName].(string)
+	root, _ := hit.Fields[indexFieldRoot].(string)
+	modTime, _ := hit.Fields[indexFieldModTime].(string)
+
+	var boosts []*search.Explanation
+
+	if profile.ExactFileName > 0 && isExactFileName(name, queryString) {
+		boosts = append(boosts, &search.Explanation{Value: profile.ExactFileName, Message: "exact file name"})
+	}
+
+	if profile.Recency > 0 && profile.RecencyHalfLife > 0 {
+		if modified, err := time.Parse(time.RFC3339Nano, modTime); err == nil {
+			age := max(now.Sub(modified), 0)
+			boost := 1 + profile.Recency*math.Exp2(-float64(age)/float64(profile.RecencyHalfLife))
+			boosts = append(boosts, &search.Explanation{Value: boost, Message: fmt.Sprintf("recency, modified %s ago", age.Round(time.Second))})
+		}
+	}
+
+	dirs := directoriesBelowRoot(path, root)
+
+	if profile.DepthPenalty > 0 && len(dirs) > 0 {
+		penalty := min(float64(len(dirs))*profile.DepthPenalty, profile.MaxDepthPenalty, 1)
+		boosts = append(boosts, &search.Explanation{Value: 1 - penalty, Message: fmt.Sprintf("depth penalty, %d directories below root", len(dirs))})
+	}
+
+	if profile.VendorPenalty > 0 && slices.ContainsFunc(dirs, func(dir string) bool { return slices.Contains(profile.VendorDirs, dir) }) {
+		boosts = append(boosts, &search.Explanation{Value: 1 - min(profile.VendorPenalty, 1), Message: "vendor path penalty"})
+	}
+
+	factor := 1.0
+	for _, boost := range boosts {
+		factor *= boost.Value
+	}
+
+	return factor, boosts
+}
+
+// isExactFileName returns true if a query is a file name, with or without its extension
+func isExactFileName(name string, queryString string) bool {
+	name = strings.ToLower(name)
+	return len(queryString) > 0 && (name == queryString || strings.TrimSuffix(name, filepath.Ext(name)) == queryString)
+}
+
+// directoriesBelowRoot returns the names of the directories between a file and the root it was indexed
+// under, or all of its directories if the root is not known
+func directoriesBelowRoot(path string, root string) []string {
+	dir := filepath.Dir(path)
+	if len(root) > 0 {
+		if relativeDir, err := filepath.Rel(root, dir); err == nil && !strings.HasPrefix(relativeDir, "..") {
+			dir = relativeDir
+		}
+	}
+
+	var dirs []string
+	for _, segment := range strings.Split(filepath.ToSlash(dir), "/") {
+		if len(segment) > 0 && segment != "." {
+			dirs = append(dirs, segment)
+		}
+	}
+
+	return dirs
+}
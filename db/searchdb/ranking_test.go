@@ -0,0 +1,111 @@
+package searchdb
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/meghashyamc/wheresthat/config"
+	"github.com/stretchr/testify/require"
+)
+
+func newRankingTestHit(id string, score float64, root string, modTime time.Time) *search.DocumentMatch {
+	return &search.DocumentMatch{
+		ID:    id,
+		Score: score,
+		Fields: map[string]any{
+			indexFieldPath:    id,
+			indexFieldName:    filepath.Base(id),
+			indexFieldRoot:    root,
+			indexFieldModTime: modTime.Format(time.RFC3339Nano),
+		},
+	}
+}
+
+func TestRankHits(t *testing.T) {
+	assert := require.New(t)
+	now := time.Now()
+
+	profile := config.RankingProfile{
+		ExactFileName:   2,
+		Recency:         1,
+		RecencyHalfLife: 24 * time.Hour,
+		DepthPenalty:    0.1,
+		MaxDepthPenalty: 0.3,
+		VendorPenalty:   0.5,
+		VendorDirs:      []string{"vendor"},
+	}
+
+	hits := search.DocumentMatchCollection{
+		newRankingTestHit("/repo/a/b/c/d/e.go", 1, "/repo", now.Add(-24*time.Hour)),
+		newRankingTestHit("/repo/vendor/v.go", 1, "/repo", now.Add(-24*time.Hour)),
+		newRankingTestHit("/repo/x.go", 1, "/repo", now.Add(-24*time.Hour)),
+		newRankingTestHit("/repo/new.go", 1, "/repo", now),
+	}
+
+	rankHits(hits, profile, "x", now)
+
+	ids := make([]string, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	assert.Equal([]string{"/repo/x.go", "/repo/new.go", "/repo/a/b/c/d/e.go", "/repo/vendor/v.go"}, ids)
+
+	assert.InDelta(3.0, hits[0].Score, 0.001, "exact file name should double the score of a file a half-life old")
+	assert.InDelta(2.0, hits[1].Score, 0.001, "a file modified just now should get the full recency boost")
+	assert.InDelta(1.05, hits[2].Score, 0.001, "depth penalty should be capped")
+	assert.InDelta(0.675, hits[3].Score, 0.001, "vendored files should be penalized")
+}
+
+func TestRankingPagesAreConsistent(t *testing.T) {
+	assert := require.New(t)
+	now := time.Now()
+	profile := config.RankingProfile{Recency: 1, RecencyHalfLife: 10 * time.Minute}
+
+	// Hits further down the keyword matches were modified more recently, so re-ranking moves them up
+	rankedIDs := func(end int) []string {
+		hits := make(search.DocumentMatchCollection, min(rankingHits(end, profile), 150))
+		for i := range hits {
+			hits[i] = newRankingTestHit(fmt.Sprintf("/repo/%03d.go", i), 1-float64(i)/1000, "/repo", now.Add(-time.Duration(150-i)*time.Minute))
+		}
+		rankHits(hits, profile, "x", now)
+
+		ids := make([]string, len(hits))
+		for i, hit := range hits {
+			ids[i] = hit.ID
+		}
+		return ids
+	}
+
+	var pagedIDs []string
+	for offset := 0; offset < 150; offset += 20 {
+		ids := rankedIDs(offset + 20)
+		pagedIDs = append(pagedIDs, ids[offset:min(offset+20, len(ids))]...)
+	}
+	assert.Equal(rankedIDs(150), pagedIDs, "pages should neither repeat nor skip results")
+}
+
+func TestDirectoriesBelowRoot(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Empty(directoriesBelowRoot("/repo/file.go", "/repo"))
+	assert.Equal([]string{"a", "b"}, directoriesBelowRoot("/repo/a/b/file.go", "/repo"))
+	assert.Equal([]string{"repo", "a"}, directoriesBelowRoot("/repo/a/file.go", ""), "all directories should count without a root")
+}
+
+func TestRankingHits(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal(10, rankingHits(10, config.RankingProfile{}), "profiles without boosts should not widen the window")
+	assert.Equal(10, rankingHits(10, config.RankingProfile{Recency: 1}), "recency without a half-life has no effect")
+	assert.Equal(rankingWindow, rankingHits(10, config.RankingProfile{}, config.RankingProfile{ExactFileName: 2}))
+	assert.Equal(rankingWindow+10, rankingHits(rankingWindow+10, config.RankingProfile{DepthPenalty: 0.1, MaxDepthPenalty: 0.5}))
+
+	now := time.Now()
+	hits := search.DocumentMatchCollection{newRankingTestHit("/repo/x.go", 1, "/repo", now)}
+	hits[0].Expl = &search.Explanation{Value: 1, Message: "weight"}
+	rankHits(hits, config.RankingProfile{}, "x", now)
+	assert.Equal(&search.Explanation{Value: 1, Message: "weight"}, hits[0].Expl, "hits should be left as they are by a profile without boosts")
+}
@@ -0,0 +1,309 @@
+package searchdb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/meghashyamc/wheresthat/db/contentdb"
+	"github.com/meghashyamc/wheresthat/db/vectordb"
+)
+
+// An index is rebuilt at the index path with this suffix, and the index it replaces is moved to the index path
+// with the other suffix until the rebuilt one is in place
+const (
+	rebuildPathSuffix  = ".rebuild"
+	replacedPathSuffix = ".replaced"
+)
+
+// indexedFilesPageSize is the number of indexed files read from the index at a time
+const indexedFilesPageSize = 1000
+
+// IndexedFile is a file that is in the index, along with the root it was indexed under
+type IndexedFile struct {
+	Path string
+	Root string
+}
+
+// Rebuild is an empty index with the current schema that documents are indexed into while searches are still
+// served from the index it is going to replace
+type Rebuild struct {
+	b         *BleveDB
+	indexPath string
+	index     bleve.Index
+	vectors   *vectordb.VectorDB
+	contents  *contentdb.ContentDB
+}
+
+// SchemaVersion returns the version of the schema that indexes are created with
+func (b *BleveDB) SchemaVersion() int {
+	return CurrentSchemaVersion()
+}
+
+// IndexedFiles returns all files in the index
+func (b *BleveDB) IndexedFiles() ([]IndexedFile, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var files []IndexedFile
+	var after []string
+	for {
+		searchRequest := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), indexedFilesPageSize, 0, false)
+		searchRequest.Fields = []string{indexFieldPath, indexFieldRoot}
+		searchRequest.SortBy([]string{"_id"})
+		if after != nil {
+			searchRequest.SetSearchAfter(after)
+		}
+
+		searchResult, err := b.index.Search(searchRequest)
+		if err != nil {
+			b.logger.Error("could not list indexed files", "err", err.Error())
+			return nil, err
+		}
+
+		for _, hit := range searchResult.Hits {
+			path, _ := hit.Fields[indexFieldPath].(string)
+			root, _ := hit.Fields[indexFieldRoot].(string)
+			files = append(files, IndexedFile{Path: path, Root: root})
+		}
+		if len(searchResult.Hits) < indexedFilesPageSize {
+			return files, nil
+		}
+		after = []string{searchResult.Hits[len(searchResult.Hits)-1].ID}
+	}
+}
+
+// StartRebuild creates an empty index with the current schema next to the index, starting over any rebuild
+// that was interrupted
+func (b *BleveDB) StartRebuild() (*Rebuild, error) {
+	indexPath := b.indexPath + rebuildPathSuffix
+	if err := removeIndexFiles(indexPath); err != nil {
+		b.logger.Error("could not remove interrupted index rebuild", "path", indexPath, "err", err.Error())
+		return nil, err
+	}
+
+	index, err := b.createIndexAt(indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	rebuild := &Rebuild{b: b, indexPath: indexPath, index: index}
+
+	b.mu.RLock()
+	semantic := b.vectors != nil
+	b.mu.RUnlock()
+	if semantic {
+		if rebuild.vectors, err = vectordb.New(b.logger, indexPath+vectorsPathSuffix); err != nil {
+			index.Close()
+			return nil, err
+		}
+	}
+	if b.contentOptions != nil {
+		if rebuild.contents, err = b.openContents(indexPath); err != nil {
+			index.Close()
+			return nil, err
+		}
+	}
+
+	b.logger.Info("started rebuilding index", "collection", b.name, "schema_version", schemaVersion)
+
+	return rebuild, nil
+}
+
+// BuildIndex indexes documents into the rebuilt index
+func (r *Rebuild) BuildIndex(documents []*Document) error {
+	return r.b.indexDocuments(r.index, r.vectors, r.contents, nil, documents)
+}
+
+// Commit replaces the index with the rebuilt one. Searches wait while the indexes are switched, so they are
+// served from either the old index or the rebuilt one.
+func (r *Rebuild) Commit() error {
+	b := r.b
+
+	if r.vectors != nil {
+		if err := r.vectors.Save(); err != nil {
+			r.Abort()
+			return err
+		}
+	}
+	if r.contents != nil {
+		if err := r.contents.Close(); err != nil {
+			b.logger.Error("could not close rebuilt stored content", "err", err.Error())
+			r.contents = nil
+			r.Abort()
+			return err
+		}
+		r.contents = nil
+	}
+	if err := r.index.Close(); err != nil {
+		b.logger.Error("could not close rebuilt index", "err", err.Error())
+		r.Abort()
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.index.Close(); err != nil {
+		b.logger.Error("could not close search index", "err", err.Error())
+		return err
+	}
+	if b.vectors != nil {
+		if err := b.vectors.Close(); err != nil {
+			return err
+		}
+	}
+	if err := b.closeContents(); err != nil {
+		return err
+	}
+
+	if err := b.switchTo(r); err != nil {
+		// Searches go on being served from the old index if the rebuilt one could not be put in its place
+		if restoreErr := b.restoreReplacedIndex(); restoreErr != nil {
+			b.logger.Error("could not restore replaced index", "err", restoreErr.Error())
+		} else if reopenErr := b.reopen(); reopenErr != nil {
+			b.logger.Error("could not reopen replaced index", "err", reopenErr.Error())
+		}
+		return err
+	}
+
+	replacedPath := b.indexPath + replacedPathSuffix
+	if err := removeIndexFiles(replacedPath); err != nil {
+		b.logger.Warn("could not remove replaced index", "path", replacedPath, "err", err.Error())
+	}
+
+	// Completions and stats that are out of date are still usable, so failing to reload them does not fail the
+	// rebuild
+	if err := b.loadCompletions(); err != nil {
+		b.logger.Warn("could not reload completions, the previous ones are kept", "err", err.Error())
+	}
+	if err := b.loadStats(); err != nil {
+		b.logger.Warn("could not recompute index stats, the previous ones are kept", "err", err.Error())
+	}
+	if err := b.loadLanguages(); err != nil {
+		b.logger.Warn("could not reload indexed languages, the previous ones are kept", "err", err.Error())
+	}
+
+	b.logger.Info("switched to rebuilt index", "collection", b.name, "schema_version", schemaVersion)
+
+	return nil
+}
+
+// switchTo moves a rebuilt index into the place of the closed index and opens it. The old index is kept until
+// the rebuilt one is in place, so that an index is found at startup even if the switch is interrupted.
+func (b *BleveDB) switchTo(r *Rebuild) error {
+	replacedPath := b.indexPath + replacedPathSuffix
+	if err := removeIndexFiles(replacedPath); err != nil {
+		b.logger.Error("could not remove replaced index", "path", replacedPath, "err", err.Error())
+		return err
+	}
+	if err := os.Rename(b.indexPath, replacedPath); err != nil {
+		b.logger.Error("could not move index aside", "path", b.indexPath, "err", err.Error())
+		return err
+	}
+	if err := os.Rename(r.indexPath, b.indexPath); err != nil {
+		b.logger.Error("could not move rebuilt index into place", "path", r.indexPath, "err", err.Error())
+		return err
+	}
+	if r.vectors != nil {
+		// The vector database of a rebuilt index without documents is never saved, so the old one is removed
+		err := os.Rename(r.indexPath+vectorsPathSuffix, b.indexPath+vectorsPathSuffix)
+		if errors.Is(err, os.ErrNotExist) {
+			err = os.Remove(b.indexPath + vectorsPathSuffix)
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			b.logger.Error("could not move rebuilt vector index into place", "err", err.Error())
+			return err
+		}
+	}
+
+	return b.reopen()
+}
+
+// Abort discards the rebuilt index, leaving the index as it was
+func (r *Rebuild) Abort() error {
+	if r.contents != nil {
+		r.contents.Close()
+	}
+	r.index.Close()
+
+	if err := removeIndexFiles(r.indexPath); err != nil {
+		r.b.logger.Error("could not remove aborted index rebuild", "path", r.indexPath, "err", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// reopen opens the index, vector database and stored content at the index path after they were replaced
+func (b *BleveDB) reopen() error {
+	index, err := bleve.Open(b.indexPath)
+	if err != nil {
+		b.logger.Error("could not open rebuilt index", "path", b.indexPath, "err", err.Error())
+		return err
+	}
+	index.SetName(b.name)
+
+	version, err := index.GetInternal(internalKeySchemaVersion)
+	if err != nil {
+		index.Close()
+		return err
+	}
+
+	if b.vectors != nil {
+		vectors, err := vectordb.New(b.logger, b.indexPath+vectorsPathSuffix)
+		if err != nil {
+			index.Close()
+			return err
+		}
+		b.vectors = vectors
+	}
+	if b.contentOptions != nil {
+		if b.contents, err = b.openContents(b.indexPath); err != nil {
+			index.Close()
+			return err
+		}
+	}
+
+	b.index = index
+	b.outdated = string(version) != strconv.Itoa(schemaVersion)
+
+	return nil
+}
+
+// restoreReplacedIndex moves an index that was being replaced back into place, if switching to a rebuilt
+// index was interrupted before the rebuilt one was in place
+func (b *BleveDB) restoreReplacedIndex() error {
+	replacedPath := b.indexPath + replacedPathSuffix
+	if _, err := os.Stat(replacedPath); err != nil {
+		return nil
+	}
+
+	if _, err := os.Stat(b.indexPath); err == nil {
+		// The rebuilt index is in place, so only the replaced one is left to remove
+		return removeIndexFiles(replacedPath)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	b.logger.Warn("restoring index whose replacement was interrupted", "path", b.indexPath)
+	if err := os.Rename(replacedPath, b.indexPath); err != nil {
+		return fmt.Errorf("could not restore replaced index: %w", err)
+	}
+
+	return nil
+}
+
+// removeIndexFiles removes an index along with its vector database
+func removeIndexFiles(indexPath string) error {
+	if err := os.RemoveAll(indexPath); err != nil {
+		return err
+	}
+	if err := os.Remove(indexPath + vectorsPathSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package searchdb
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/standard"
+	"github.com/stretchr/testify/require"
+)
+
+func newRebuildTestDocument(path string, content string) *Document {
+	return &Document{ID: path, Path: path, Name: filepath.Base(path), Content: content, Root: filepath.Dir(path), Type: DocumentTypeText}
+}
+
+func TestRebuild(t *testing.T) {
+	assert := require.New(t)
+
+	b := &BleveDB{
+		name:         DefaultCollection,
+		indexPath:    filepath.Join(t.TempDir(), "search.index"),
+		textAnalyzer: standard.Name,
+		logger:       slog.New(slog.NewTextHandler(os.Stderr, nil)),
+	}
+	assert.NoError(b.open(false))
+	defer b.Close()
+
+	assert.NoError(b.BuildIndex([]*Document{
+		newRebuildTestDocument("/notes/cache.md", "cache eviction"),
+		newRebuildTestDocument("/notes/billing.md", "billing retries"),
+	}))
+
+	files, err := b.IndexedFiles()
+	assert.NoError(err)
+	assert.ElementsMatch([]IndexedFile{{Path: "/notes/cache.md", Root: "/notes"}, {Path: "/notes/billing.md", Root: "/notes"}}, files)
+
+	rebuild, err := b.StartRebuild()
+	assert.NoError(err)
+	assert.NoError(rebuild.BuildIndex([]*Document{newRebuildTestDocument("/notes/cache.md", "cache warmup")}))
+
+	docCount, err := b.GetDocCount()
+	assert.NoError(err)
+	assert.Equal(uint64(2), docCount, "searches should be served from the old index while it is rebuilt")
+
+	assert.NoError(rebuild.Commit())
+
+	docCount, err = b.GetDocCount()
+	assert.NoError(err)
+	assert.Equal(uint64(1), docCount, "the rebuilt index should replace the old one")
+	assert.False(b.IsOutdated())
+
+	for _, suffix := range []string{rebuildPathSuffix, replacedPathSuffix} {
+		_, err := os.Stat(b.indexPath + suffix)
+		assert.ErrorIs(err, os.ErrNotExist, "no other index should be left behind")
+	}
+
+	rebuild, err = b.StartRebuild()
+	assert.NoError(err)
+	assert.NoError(rebuild.Abort())
+	docCount, err = b.GetDocCount()
+	assert.NoError(err)
+	assert.Equal(uint64(1), docCount, "an aborted rebuild should leave the index as it was")
+}
+
+func TestRestoreReplacedIndex(t *testing.T) {
+	assert := require.New(t)
+
+	b := &BleveDB{
+		name:         DefaultCollection,
+		indexPath:    filepath.Join(t.TempDir(), "search.index"),
+		textAnalyzer: standard.Name,
+		logger:       slog.New(slog.NewTextHandler(os.Stderr, nil)),
+	}
+	assert.NoError(b.open(false))
+	assert.NoError(b.BuildIndex([]*Document{newRebuildTestDocument("/notes/cache.md", "cache eviction")}))
+	assert.NoError(b.Close())
+
+	// A switch to a rebuilt index that was interrupted after the old index was moved aside
+	assert.NoError(os.Rename(b.indexPath, b.indexPath+replacedPathSuffix))
+
+	assert.NoError(b.open(false))
+	defer b.Close()
+
+	docCount, err := b.GetDocCount()
+	assert.NoError(err)
+	assert.Equal(uint64(1), docCount, "the old index should be restored")
+}
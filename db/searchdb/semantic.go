@@ -0,0 +1,251 @@
+package searchdb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/meghashyamc/wheresthat/db/vectordb"
+)
+
+// Search modes
+const (
+	// SearchModeKeyword ranks documents matching the terms of a query by BM25
+	SearchModeKeyword = "keyword"
+	// SearchModeSemantic ranks documents by how similar their passages are to a query
+	SearchModeSemantic = "semantic"
+	// SearchModeHybrid fuses the rankings of keyword and semantic search
+	SearchModeHybrid = "hybrid"
+)
+
+var ErrSemanticSearchDisabled = errors.New("semantic search is not enabled")
+
+const (
+	// Number of documents ranked by each kind of search, before the results are paged
+	semanticSearchCandidates = 100
+	// Passages less similar than this to a query are not considered to match it
+	minSemanticScore = 0.05
+	// Filtering by directory happens after passages are looked up, so more of them are looked up
+	semanticCandidatesPerFilteredResult = 4
+	// Constant k of reciprocal rank fusion, which keeps the top few ranks of either search from dominating
+	rrfRankConstant = 60
+)
+
+// searchSemantic finds documents with passages similar to a query. Each result's snippet is its most similar passage.
+func (b *BleveDB) searchSemantic(params SearchParams) (*Response, error) {
+	matches, err := b.findSemanticMatches(params, max(params.Offset+params.Limit, semanticSearchCandidates))
+	if err != nil {
+		return nil, err
+	}
+
+	pageMatches := matches[min(params.Offset, len(matches)):min(params.Offset+params.Limit, len(matches))]
+	docIDs := make([]string, len(pageMatches))
+	for i, match := range pageMatches {
+		docIDs[i] = match.DocID
+	}
+
+	resultsByID, err := b.getResultsByID(docIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(pageMatches))
+	for _, match := range pageMatches {
+		result, ok := resultsByID[match.DocID]
+		if !ok {
+			continue
+		}
+		result.Score = match.Score
+		result.Snippet = b.readPassageSnippet(result.Path, match.Start, match.End)
+		results = append(results, result)
+	}
+
+	response := &Response{
+		Results: results,
+		Total:   uint64(len(matches)),
+	}
+	if len(matches) > 0 {
+		response.MaxScore = matches[0].Score
+	}
+
+	return response, nil
+}
+
+// searchHybrid runs both keyword and semantic search and fuses their rankings with reciprocal rank fusion,
+// so that documents ranked highly by either search, and especially by both, come first
+func (b *BleveDB) searchHybrid(params SearchParams) (*Response, error) {
+	candidates := max(params.Offset+params.Limit, semanticSearchCandidates)
+
+	keywordParams := params
+	keywordParams.Limit = candidates
+	keywordParams.Offset = 0
+	keywordResponse, err := b.searchKeywords(keywordParams)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := b.findSemanticMatches(params, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	scores := map[string]float64{}
+	resultsByID := map[string]Result{}
+	for rank, result := range keywordResponse.Results {
+		scores[result.ID] += 1 / float64(rrfRankConstant+rank+1)
+		resultsByID[result.ID] = result
+	}
+
+	var semanticOnlyIDs []string
+	for rank, match := range matches {
+		scores[match.DocID] += 1 / float64(rrfRankConstant+rank+1)
+		if _, ok := resultsByID[match.DocID]; !ok {
+			semanticOnlyIDs = append(semanticOnlyIDs, match.DocID)
+		}
+	}
+
+	semanticOnlyResults, err := b.getResultsByID(semanticOnlyIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, match := range matches {
+		if result, ok := semanticOnlyResults[match.DocID]; ok {
+			result.Snippet = b.readPassageSnippet(result.Path, match.Start, match.End)
+			resultsByID[match.DocID] = result
+		}
+	}
+
+	fused := make([]Result, 0, len(resultsByID))
+	for id, result := range resultsByID {
+		result.Score = scores[id]
+		fused = append(fused, result)
+	}
+	sort.Slice(fused, func(i, j int) bool {
+		if fused[i].Score != fused[j].Score {
+			return fused[i].Score > fused[j].Score
+		}
+		return fused[i].Path < fused[j].Path
+	})
+
+	response := &Response{
+		Results:    fused[min(params.Offset, len(fused)):min(params.Offset+params.Limit, len(fused))],
+		Total:      uint64(len(fused)),
+		FuzzyTerms: keywordResponse.FuzzyTerms,
+	}
+	if len(fused) > 0 {
+		response.MaxScore = fused[0].Score
+	}
+
+	return response, nil
+}
+
+// findSemanticMatches looks up to limit documents with passages similar to a query, within the directory
+// the search is restricted to
+func (b *BleveDB) findSemanticMatches(params SearchParams, limit int) ([]vectordb.Match, error) {
+	if b.vectors == nil {
+		return nil, ErrSemanticSearchDisabled
+	}
+
+	under := ""
+	lookups := limit
+	if dir, ok := underDirectory(params.Under); ok {
+		under = dir + string(filepath.Separator)
+		lookups = limit * semanticCandidatesPerFilteredResult
+	}
+
+	var matches []vectordb.Match
+	for _, match := range b.vectors.Search(params.Query, lookups) {
+		if match.Score < minSemanticScore {
+			break
+		}
+		if len(under) > 0 && !strings.HasPrefix(match.DocID, under) {
+			continue
+		}
+		matches = append(matches, match)
+		if len(matches) == limit {
+			break
+		}
+	}
+
+	return matches, nil
+}
+
+// getResultsByID creates results from the stored fields of documents
+func (b *BleveDB) getResultsByID(docIDs []string) (map[string]Result, error) {
+	results := make(map[string]Result, len(docIDs))
+	if len(docIDs) == 0 {
+		return results, nil
+	}
+
+	searchRequest := bleve.NewSearchRequestOptions(bleve.NewDocIDQuery(docIDs), len(docIDs), 0, false)
+	searchRequest.Fields = []string{indexFieldPath, indexFieldName, indexFieldSize, indexFieldModTime}
+
+	searchResult, err := b.index.Search(searchRequest)
+	if err != nil {
+		b.logger.Error("could not look up documents", "err", err.Error())
+		return nil, fmt.Errorf("could not look up documents: %w", err)
+	}
+
+	for _, hit := range searchResult.Hits {
+		results[hit.ID] = newResult(hit)
+	}
+
+	return results, nil
+}
+
+// readPassageSnippet reads the start of a passage of a file, to show as a snippet. The passage is read from the
+// stored content of the file if there is any, since that is what it was found in.
+func (b *BleveDB) readPassageSnippet(filePath string, start int, end int) string {
+	if b.contents != nil {
+		content, ok, err := b.contents.Get(filePath)
+		if err != nil {
+			b.logger.Warn("failed to read stored content for snippet", "path", filePath, "err", err.Error())
+		}
+		if ok {
+			contentSize := int64(len(content))
+			snippetStart := min(int64(start), contentSize)
+			snippetEnd := min(int64(end), snippetStart+2*snippetContext, contentSize)
+			if snippetEnd <= snippetStart {
+				return ""
+			}
+			return formatSnippet(content[snippetStart:snippetEnd], snippetStart, snippetEnd, contentSize)
+		}
+	}
+
+	if !b.isTextFile(filePath) {
+		return ""
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		b.logger.Warn("failed to open file for snippet", "path", filePath, "err", err.Error())
+		return ""
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		b.logger.Warn("failed to get file info for snippet", "path", filePath, "err", err.Error())
+		return ""
+	}
+	fileSize := fileInfo.Size()
+
+	snippetStart := min(int64(start), fileSize)
+	snippetEnd := min(int64(end), snippetStart+2*snippetContext, fileSize)
+	if snippetEnd <= snippetStart {
+		return ""
+	}
+
+	buffer := make([]byte, snippetEnd-snippetStart)
+	if _, err := file.ReadAt(buffer, snippetStart); err != nil && err != io.EOF {
+		b.logger.Warn("failed to read file for snippet", "path", filePath, "err", err.Error())
+		return ""
+	}
+
+	return formatSnippet(string(buffer), snippetStart, snippetEnd, fileSize)
+}
@@ -0,0 +1,241 @@
+package searchdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+	index "github.com/blevesearch/bleve_index_api"
+)
+
+var ErrDocumentNotFound = errors.New("document not found")
+
+const (
+	// Number of the most distinctive terms of a document that similar documents are searched for
+	maxSimilarQueryTerms = 25
+	// Shorter terms are rarely distinctive
+	minSimilarTermLength = 3
+	// Terms found in more than this fraction of documents are too common to tell documents apart
+	maxSimilarTermDocFrequencyRatio = 0.5
+)
+
+// SimilarParams identifies a document to find similar documents for, along with paging and filters
+type SimilarParams struct {
+	Path   string
+	Limit  int
+	Offset int
+	// SameRoot restricts results to documents found by indexing the same directory as the document
+	SameRoot bool
+	// SameExt restricts results to documents with the same extension as the document
+	SameExt bool
+}
+
+// weightedTerm is a term of a document along with how distinctive it is of the document
+type weightedTerm struct {
+	term   string
+	weight float64
+}
+
+// FindSimilar finds documents whose content is similar to that of an indexed document. The most distinctive
+// terms of the document by TF-IDF are searched for, each boosted by its weight. The document itself is never
+// part of the results.
+func (b *BleveDB) FindSimilar(params SimilarParams) (*Response, error) {
+	start := time.Now()
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	source, err := b.getStoredFields(params.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	terms, err := b.findDistinctiveTerms(params.Path)
+	if err != nil {
+		return nil, err
+	}
+	if len(terms) == 0 {
+		return &Response{Results: []Result{}, SearchTime: time.Since(start).String()}, nil
+	}
+
+	var filters []query.Query
+	if params.SameRoot && len(source[indexFieldRoot]) > 0 {
+		rootQuery := bleve.NewTermQuery(source[indexFieldRoot])
+		rootQuery.SetField(indexFieldRoot)
+		filters = append(filters, rootQuery)
+	}
+	if params.SameExt && len(source[indexFieldExt]) > 0 {
+		extQuery := bleve.NewTermQuery(source[indexFieldExt])
+		extQuery.SetField(indexFieldExt)
+		filters = append(filters, extQuery)
+	}
+
+	booleanQuery := bleve.NewBooleanQuery()
+	booleanQuery.AddMust(withFilters(buildSimilarQuery(terms), filters))
+	booleanQuery.AddMustNot(bleve.NewDocIDQuery([]string{params.Path}))
+
+	searchRequest := bleve.NewSearchRequestOptions(booleanQuery, params.Limit, params.Offset, false)
+	searchRequest.Fields = []string{indexFieldPath, indexFieldName, indexFieldSize, indexFieldModTime}
+	searchRequest.IncludeLocations = true
+
+	searchResult, err := b.index.Search(searchRequest)
+	if err != nil {
+		b.logger.Error("similar documents search failed", "path", params.Path, "err", err.Error())
+		return nil, fmt.Errorf("similar documents search failed: %w", err)
+	}
+
+	results := make([]Result, len(searchResult.Hits))
+	for i, hit := range searchResult.Hits {
+		result := newResult(hit)
+		result.Snippet, _ = b.extractSnippet(result.Path, hit.Locations, nil)
+		results[i] = result
+	}
+
+	return &Response{
+		Results:    results,
+		Total:      searchResult.Total,
+		MaxScore:   searchResult.MaxScore,
+		SearchTime: time.Since(start).String(),
+	}, nil
+}
+
+// getStoredFields returns the stored root and extension of a document
+func (b *BleveDB) getStoredFields(documentID string) (map[string]string, error) {
+	searchRequest := bleve.NewSearchRequestOptions(bleve.NewDocIDQuery([]string{documentID}), 1, 0, false)
+	searchRequest.Fields = []string{indexFieldRoot, indexFieldExt}
+
+	searchResult, err := b.index.Search(searchRequest)
+	if err != nil {
+		b.logger.Error("could not look up document", "id", documentID, "err", err.Error())
+		return nil, fmt.Errorf("could not look up document: %w", err)
+	}
+	if len(searchResult.Hits) == 0 {
+		return nil, ErrDocumentNotFound
+	}
+
+	fields := map[string]string{}
+	for _, field := range searchRequest.Fields {
+		if value, ok := searchResult.Hits[0].Fields[field].(string); ok {
+			fields[field] = value
+		}
+	}
+
+	return fields, nil
+}
+
+// findDistinctiveTerms weighs the terms a document was indexed with by TF-IDF, reading them and their
+// frequencies from the index, so that they are those of the document as it was indexed even if its file has
+// changed or is gone since. Terms that appear in no other document are left out, since they cannot match
+// anything.
+func (b *BleveDB) findDistinctiveTerms(documentID string) ([]weightedTerm, error) {
+	advancedIndex, err := b.index.Advanced()
+	if err != nil {
+		return nil, fmt.Errorf("could not access index: %w", err)
+	}
+	indexReader, err := advancedIndex.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("could not read index: %w", err)
+	}
+	defer indexReader.Close()
+
+	docCount, err := indexReader.DocCount()
+	if err != nil {
+		return nil, fmt.Errorf("could not count documents: %w", err)
+	}
+	internalID, err := indexReader.InternalID(documentID)
+	if err != nil {
+		return nil, fmt.Errorf("could not look up document: %w", err)
+	}
+	if internalID == nil {
+		return nil, ErrDocumentNotFound
+	}
+
+	// The doc values of the content field are the distinct terms the content of the document was indexed with
+	docValues, err := indexReader.DocValueReader([]string{indexFieldContent})
+	if err != nil {
+		return nil, fmt.Errorf("could not read document terms: %w", err)
+	}
+	var candidates []string
+	err = docValues.VisitDocValues(internalID, func(field string, term []byte) {
+		if isDistinctiveTermCandidate(string(term)) {
+			candidates = append(candidates, string(term))
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not read document terms: %w", err)
+	}
+
+	terms := make([]weightedTerm, 0, len(candidates))
+	for _, term := range candidates {
+		termFrequency, docFrequency, err := getTermFrequencies(indexReader, internalID, term)
+		if err != nil {
+			return nil, err
+		}
+		if termFrequency == 0 || docFrequency < 2 || float64(docFrequency) > maxSimilarTermDocFrequencyRatio*float64(docCount) {
+			continue
+		}
+		idf := math.Log(float64(docCount) / float64(docFrequency))
+		terms = append(terms, weightedTerm{term: term, weight: float64(termFrequency) * idf})
+	}
+
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].weight != terms[j].weight {
+			return terms[i].weight > terms[j].weight
+		}
+		return terms[i].term < terms[j].term
+	})
+
+	return terms[:min(maxSimilarQueryTerms, len(terms))], nil
+}
+
+// getTermFrequencies returns how many times a term appears in the content of a document, along with the number
+// of documents it appears in
+func getTermFrequencies(indexReader index.IndexReader, internalID index.IndexInternalID, term string) (uint64, uint64, error) {
+	termFieldReader, err := indexReader.TermFieldReader(context.Background(), []byte(term), indexFieldContent, true, false, false)
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not look up term %q: %w", term, err)
+	}
+	defer termFieldReader.Close()
+
+	termFieldDoc, err := termFieldReader.Advance(internalID, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not look up term %q: %w", term, err)
+	}
+	if termFieldDoc == nil || !termFieldDoc.ID.Equals(internalID) {
+		return 0, termFieldReader.Count(), nil
+	}
+
+	return termFieldDoc.Freq, termFieldReader.Count(), nil
+}
+
+func buildSimilarQuery(terms []weightedTerm) query.Query {
+	// Weights are scaled so that the most distinctive term has a boost of 1
+	maxWeight := terms[0].weight
+
+	disjunctionQuery := bleve.NewDisjunctionQuery()
+	for _, term := range terms {
+		termQuery := bleve.NewTermQuery(term.term)
+		termQuery.SetField(indexFieldContent)
+		termQuery.SetBoost(term.weight / maxWeight)
+		disjunctionQuery.AddQuery(termQuery)
+	}
+
+	return disjunctionQuery
+}
+
+// isDistinctiveTermCandidate returns false for terms that are too short or that are only numbers
+func isDistinctiveTermCandidate(term string) bool {
+	if utf8.RuneCountInString(term) < minSimilarTermLength {
+		return false
+	}
+
+	return strings.ContainsFunc(term, func(r rune) bool { return !unicode.IsDigit(r) })
+}
@@ -0,0 +1,72 @@
+package searchdb
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+var ErrSchemaVersionMismatch = errors.New("index was built with a different schema")
+
+// CurrentSchemaVersion returns the version of the schema that indexes are created with
+func CurrentSchemaVersion() int {
+	return schemaVersion
+}
+
+// VectorsPath returns where the vector database of the index at indexPath is kept
+func VectorsPath(indexPath string) string {
+	return indexPath + vectorsPathSuffix
+}
+
+// Snapshot writes a copy of the index to path, along with its vector database at VectorsPath(path) if semantic
+// search is enabled and its stored content inside path if that is enabled. The copy is of the index as it is
+// when Snapshot is called, so searches are not held up by it.
+func (b *BleveDB) Snapshot(path string) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	index, ok := b.index.(bleve.IndexCopyable)
+	if !ok {
+		return errors.New("index can not be copied")
+	}
+	if err := index.CopyTo(bleve.FileSystemDirectory(path)); err != nil {
+		b.logger.Error("could not copy index", "collection", b.name, "path", path, "err", err.Error())
+		return fmt.Errorf("could not copy index: %w", err)
+	}
+
+	if b.vectors != nil {
+		if err := b.vectors.Snapshot(VectorsPath(path)); err != nil {
+			return err
+		}
+	}
+	if b.contents != nil {
+		if err := b.contents.Snapshot(filepath.Join(path, contentFileName)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CheckSnapshot opens a copy of an index written by Snapshot, returning ErrSchemaVersionMismatch if it was not
+// built with the current schema
+func CheckSnapshot(path string) error {
+	index, err := bleve.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not open index: %w", err)
+	}
+	defer index.Close()
+
+	version, err := index.GetInternal(internalKeySchemaVersion)
+	if err != nil {
+		return fmt.Errorf("could not read index schema version: %w", err)
+	}
+	if string(version) != strconv.Itoa(schemaVersion) {
+		return fmt.Errorf("%w: %s, expected %d", ErrSchemaVersionMismatch, string(version), schemaVersion)
+	}
+
+	return nil
+}
@@ -0,0 +1,348 @@
+package searchdb
+
+import (
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/numeric"
+)
+
+// Only this many of the largest indexed files are kept in the stats
+const largestFilesCount = 10
+
+// IndexStats describes what is in an index. It is kept up to date as the index changes and published whenever
+// the index is done changing, so reading it costs nothing.
+type IndexStats struct {
+	Documents            uint64         `json:"documents"`
+	DocumentsByRoot      map[string]int `json:"documents_by_root"`
+	DocumentsByExtension map[string]int `json:"documents_by_extension"`
+	// IndexedBytes is the total size of all indexed files
+	IndexedBytes int64 `json:"indexed_bytes"`
+	// VocabularySize is the number of distinct terms in the content of all indexed files
+	VocabularySize int `json:"vocabulary_size"`
+	// LargestFiles are the largest indexed files, with the largest first
+	LargestFiles []FileSize `json:"largest_files"`
+	ComputedAt   time.Time  `json:"computed_at"`
+}
+
+type FileSize struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// Stats returns the stats of the index as of the last time it changed
+func (b *BleveDB) Stats() IndexStats {
+	return *b.stats.Load()
+}
+
+// DiskUsage returns the size of the files of the index and its vector database
+func (b *BleveDB) DiskUsage() (int64, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var size int64
+	err := filepath.WalkDir(b.indexPath, func(path string, entry fs.DirEntry, err error) error {
+		// Files of the index may be merged away while it is walked
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+
+		return nil
+	})
+	if err != nil {
+		b.logger.Error("could not get size of index", "path", b.indexPath, "err", err.Error())
+		return 0, err
+	}
+
+	if info, err := os.Stat(VectorsPath(b.indexPath)); err == nil {
+		size += info.Size()
+	}
+
+	return size, nil
+}
+
+// liveStats are the stats of an index, kept up to date as documents are indexed and deleted so that they do not
+// have to be computed again from every document whenever the index is done changing
+type liveStats struct {
+	mu    sync.Mutex
+	stats IndexStats
+	// stale is true if the stats could not be kept up to date, either because a change to the index could not be
+	// followed or because one of the largest files was removed and the next largest one is not known
+	stale bool
+}
+
+// documentStats is what the stats of an index count of one of its documents
+type documentStats struct {
+	path string
+	root string
+	ext  string
+	size int64
+}
+
+// visit reads the doc value of a field of a document into d
+func (d *documentStats) visit(field string, term []byte) {
+	switch field {
+	case indexFieldRoot:
+		d.root = string(term)
+	case indexFieldExt:
+		d.ext = string(term)
+	case indexFieldPath:
+		d.path = string(term)
+	case indexFieldSize:
+		// Sizes are indexed at full precision along with coarser terms for range queries
+		if valid, shift := numeric.ValidPrefixCodedTermBytes(term); valid && shift == 0 {
+			if value, err := numeric.PrefixCoded(term).Int64(); err == nil {
+				d.size = int64(numeric.Int64ToFloat64(value))
+			}
+		}
+	}
+}
+
+// statsFields are the fields whose doc values make up documentStats
+var statsFields = []string{indexFieldRoot, indexFieldExt, indexFieldSize, indexFieldPath}
+
+// loadStats computes the stats of the index, visiting the root, extension, size and path of each of its
+// documents once. It must be called with b.mu held.
+func (b *BleveDB) loadStats() error {
+	start := time.Now()
+	stats := IndexStats{
+		DocumentsByRoot:      map[string]int{},
+		DocumentsByExtension: map[string]int{},
+	}
+
+	advanced, err := b.index.Advanced()
+	if err != nil {
+		return err
+	}
+	reader, err := advanced.Reader()
+	if err != nil {
+		b.logger.Error("could not read index", "err", err.Error())
+		return err
+	}
+	defer reader.Close()
+
+	docIDs, err := reader.DocIDReaderAll()
+	if err != nil {
+		return err
+	}
+	defer docIDs.Close()
+	docValues, err := reader.DocValueReader(statsFields)
+	if err != nil {
+		return err
+	}
+
+	for {
+		id, err := docIDs.Next()
+		if err != nil {
+			b.logger.Error("could not read indexed documents", "err", err.Error())
+			return err
+		}
+		if id == nil {
+			break
+		}
+
+		var doc documentStats
+		if err := docValues.VisitDocValues(id, doc.visit); err != nil {
+			b.logger.Error("could not read indexed documents", "err", err.Error())
+			return err
+		}
+		stats.add(doc)
+	}
+
+	b.liveStats.mu.Lock()
+	defer b.liveStats.mu.Unlock()
+
+	b.liveStats.stats = stats
+	b.liveStats.stale = false
+	if err := b.publishStats(); err != nil {
+		return err
+	}
+	b.logger.Info("computed index stats", "collection", b.name, "documents", stats.Documents, "duration", time.Since(start).String())
+
+	return nil
+}
+
+// updateStats brings the stats of the index up to date after a change to it, computing them again from every
+// document only if they could not be kept up to date since the last time. It must be called with b.mu held.
+func (b *BleveDB) updateStats() error {
+	b.liveStats.mu.Lock()
+	stale := b.liveStats.stale
+	if !stale {
+		defer b.liveStats.mu.Unlock()
+		return b.publishStats()
+	}
+	b.liveStats.mu.Unlock()
+
+	return b.loadStats()
+}
+
+// publishStats replaces the stats returned by Stats with a copy of the live stats, counting the vocabulary of
+// the index along the way. It must be called with b.mu and b.liveStats.mu held.
+func (b *BleveDB) publishStats() error {
+	stats := b.liveStats.stats
+	stats.DocumentsByRoot = maps.Clone(stats.DocumentsByRoot)
+	stats.DocumentsByExtension = maps.Clone(stats.DocumentsByExtension)
+	stats.LargestFiles = slices.Clone(stats.LargestFiles)
+	stats.ComputedAt = time.Now().UTC()
+
+	// Which terms are no longer used by any document is not known from the documents that changed, so the
+	// vocabulary is counted from the term dictionary. Terms of deleted documents stay in it until their
+	// segments are merged, so this may count a few terms too many.
+	err := b.visitFieldTerms(indexFieldContent, func(string, int) { stats.VocabularySize++ })
+	if err != nil {
+		return err
+	}
+
+	b.stats.Store(&stats)
+
+	return nil
+}
+
+// readDocumentStats reads what the stats of an index count of those of its documents that have the given IDs.
+// Documents that are not in the index are left out, and documents whose IDs are repeated are read once.
+func (b *BleveDB) readDocumentStats(index bleve.Index, documentIDs []string) ([]documentStats, error) {
+	advanced, err := index.Advanced()
+	if err != nil {
+		return nil, err
+	}
+	reader, err := advanced.Reader()
+	if err != nil {
+		b.logger.Error("could not read index", "err", err.Error())
+		return nil, err
+	}
+	defer reader.Close()
+
+	docValues, err := reader.DocValueReader(statsFields)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []documentStats
+	seen := make(map[string]struct{}, len(documentIDs))
+	for _, documentID := range documentIDs {
+		if _, ok := seen[documentID]; ok {
+			continue
+		}
+		seen[documentID] = struct{}{}
+
+		id, err := reader.InternalID(documentID)
+		if err != nil {
+			return nil, err
+		}
+		if len(id) == 0 {
+			continue
+		}
+
+		var doc documentStats
+		if err := docValues.VisitDocValues(id, doc.visit); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+
+	return docs, nil
+}
+
+// trackChange changes the live stats of the index by a change that replaced the documents described by before
+// with those described by after
+func (s *liveStats) trackChange(before []documentStats, after []documentStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, doc := range before {
+		if !s.stats.remove(doc) {
+			s.stale = true
+		}
+	}
+	for _, doc := range after {
+		s.stats.add(doc)
+	}
+}
+
+// markStale records that a change to the index could not be followed, so that the stats are computed again from
+// every document on the next update
+func (s *liveStats) markStale() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stale = true
+}
+
+// reset empties the live stats, for an index that has just been emptied
+func (s *liveStats) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats = IndexStats{DocumentsByRoot: map[string]int{}, DocumentsByExtension: map[string]int{}}
+	s.stale = false
+}
+
+// add counts a document in the stats
+func (s *IndexStats) add(doc documentStats) {
+	s.Documents++
+	s.DocumentsByRoot[doc.root]++
+	s.DocumentsByExtension[doc.ext]++
+	s.IndexedBytes += doc.size
+	s.LargestFiles = addLargestFile(s.LargestFiles, doc.path, doc.size)
+}
+
+// remove stops counting a document in the stats. It returns false if it was one of the largest files and
+// there may be another file that is now among them, in which case the largest files are incomplete.
+func (s *IndexStats) remove(doc documentStats) bool {
+	s.Documents--
+	removeCount(s.DocumentsByRoot, doc.root)
+	removeCount(s.DocumentsByExtension, doc.ext)
+	s.IndexedBytes -= doc.size
+
+	at := slices.Index(s.LargestFiles, FileSize{Path: doc.path, Size: doc.size})
+	if at < 0 {
+		return true
+	}
+	s.LargestFiles = slices.Delete(s.LargestFiles, at, at+1)
+
+	return s.Documents == uint64(len(s.LargestFiles))
+}
+
+func removeCount(counts map[string]int, key string) {
+	if counts[key] <= 1 {
+		delete(counts, key)
+		return
+	}
+	counts[key]--
+}
+
+// addLargestFile adds a file to the largest files if it is among them, keeping them ordered by size and then path
+func addLargestFile(files []FileSize, path string, size int64) []FileSize {
+	isLarger := func(file FileSize) bool {
+		return size > file.Size || (size == file.Size && path < file.Path)
+	}
+	if len(files) == largestFilesCount && !isLarger(files[len(files)-1]) {
+		return files
+	}
+
+	at := slices.IndexFunc(files, isLarger)
+	if at < 0 {
+		at = len(files)
+	}
+	files = slices.Insert(files, at, FileSize{Path: path, Size: size})
+
+	return files[:min(len(files), largestFilesCount)]
+}
@@ -0,0 +1,101 @@
+package searchdb
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/standard"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	assert := require.New(t)
+
+	b := &BleveDB{
+		name:         DefaultCollection,
+		indexPath:    filepath.Join(t.TempDir(), "search.index"),
+		textAnalyzer: standard.Name,
+		logger:       slog.New(slog.NewTextHandler(os.Stderr, nil)),
+	}
+	assert.NoError(b.open(false))
+	defer b.Close()
+
+	assert.Equal(uint64(0), b.Stats().Documents)
+
+	documents := []*Document{
+		{ID: "/notes/cache.md", Path: "/notes/cache.md", Root: "/notes", Ext: ".md", Size: 100, Content: "cache eviction"},
+		{ID: "/notes/billing.md", Path: "/notes/billing.md", Root: "/notes", Ext: ".md", Size: 2000, Content: "billing retries"},
+		{ID: "/code/main.go", Path: "/code/main.go", Root: "/code", Ext: ".go", Size: 100, Content: "cache main"},
+	}
+	assert.NoError(b.BuildIndex(documents))
+	assert.NoError(b.Flush())
+
+	stats := b.Stats()
+	assert.Equal(uint64(3), stats.Documents)
+	assert.Equal(map[string]int{"/notes": 2, "/code": 1}, stats.DocumentsByRoot)
+	assert.Equal(map[string]int{".md": 2, ".go": 1}, stats.DocumentsByExtension)
+	assert.Equal(int64(2200), stats.IndexedBytes, "files of the same size should all be counted")
+	assert.Equal(5, stats.VocabularySize)
+	assert.Equal([]FileSize{{Path: "/notes/billing.md", Size: 2000}, {Path: "/code/main.go", Size: 100}, {Path: "/notes/cache.md", Size: 100}}, stats.LargestFiles)
+
+	assert.NoError(b.DeleteDocuments([]string{"/notes/billing.md"}))
+	assert.NoError(b.Flush())
+
+	stats = b.Stats()
+	assert.Equal(uint64(2), stats.Documents)
+	assert.Equal(map[string]int{"/notes": 1, "/code": 1}, stats.DocumentsByRoot, "deleted documents should not be counted")
+	assert.Equal(int64(200), stats.IndexedBytes)
+
+	// Indexing a document again replaces what was counted of it
+	assert.NoError(b.BuildIndex([]*Document{{ID: "/code/main.go", Path: "/code/main.go", Root: "/code", Ext: ".go", Size: 300, Content: "cache main"}}))
+	assert.NoError(b.Flush())
+
+	stats = b.Stats()
+	assert.Equal(uint64(2), stats.Documents)
+	assert.Equal(map[string]int{"/notes": 1, "/code": 1}, stats.DocumentsByRoot)
+	assert.Equal(int64(400), stats.IndexedBytes)
+	assert.Equal([]FileSize{{Path: "/code/main.go", Size: 300}, {Path: "/notes/cache.md", Size: 100}}, stats.LargestFiles)
+
+	diskUsage, err := b.DiskUsage()
+	assert.NoError(err)
+	assert.Positive(diskUsage)
+}
+
+func TestStatsOfLargestFiles(t *testing.T) {
+	assert := require.New(t)
+
+	b := &BleveDB{
+		name:         DefaultCollection,
+		indexPath:    filepath.Join(t.TempDir(), "search.index"),
+		textAnalyzer: standard.Name,
+		logger:       slog.New(slog.NewTextHandler(os.Stderr, nil)),
+	}
+	assert.NoError(b.open(false))
+	defer b.Close()
+
+	var documents []*Document
+	for i := range largestFilesCount + 2 {
+		path := fmt.Sprintf("/notes/%02d.md", i)
+		documents = append(documents, &Document{ID: path, Path: path, Root: "/notes", Ext: ".md", Size: int64(100 * (i + 1)), Content: "notes"})
+	}
+	assert.NoError(b.BuildIndex(documents))
+	assert.NoError(b.Flush())
+
+	stats := b.Stats()
+	assert.Len(stats.LargestFiles, largestFilesCount)
+	assert.Equal(FileSize{Path: "/notes/11.md", Size: 1200}, stats.LargestFiles[0])
+	assert.Equal(FileSize{Path: "/notes/02.md", Size: 300}, stats.LargestFiles[largestFilesCount-1])
+
+	// The files that take the place of removed largest files were not among them before
+	assert.NoError(b.DeleteDocuments([]string{"/notes/11.md", "/notes/10.md"}))
+	assert.NoError(b.Flush())
+
+	stats = b.Stats()
+	assert.Equal(uint64(largestFilesCount), stats.Documents)
+	assert.Len(stats.LargestFiles, largestFilesCount)
+	assert.Equal(FileSize{Path: "/notes/09.md", Size: 1000}, stats.LargestFiles[0])
+	assert.Equal(FileSize{Path: "/notes/00.md", Size: 100}, stats.LargestFiles[largestFilesCount-1])
+}
@@ -0,0 +1,189 @@
+package searchdb
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	index "github.com/blevesearch/bleve_index_api"
+)
+
+// Maximum number of corrected queries suggested for a query
+const maxQuerySuggestions = 3
+
+// Maximum number of corrections considered for each misspelt word of a query
+const maxCorrectionsPerWord = 3
+
+var suggestionWordRegexp = regexp.MustCompile(`[\p{L}\p{N}_]+`)
+
+// correction is a term from the index vocabulary that could replace a misspelt word
+type correction struct {
+	term         string
+	editDistance int
+	docFrequency uint64
+}
+
+// misspeltWord is a word of a query that is not in the index vocabulary, with its position in the query
+type misspeltWord struct {
+	start, end  int
+	corrections []correction
+}
+
+// SuggestQueries suggests corrected versions of a query, using the vocabulary of the content and name
+// fields. Each word that does not appear in the index is replaced with a similar term that does. Corrected
+// queries whose replacements appear in more documents are suggested first.
+func (b *BleveDB) SuggestQueries(queryString string) ([]string, error) {
+	queryString = strings.ToLower(strings.TrimSpace(queryString))
+	if len(queryString) == 0 {
+		return nil, nil
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	advancedIndex, err := b.index.Advanced()
+	if err != nil {
+		return nil, fmt.Errorf("could not access index: %w", err)
+	}
+	indexReader, err := advancedIndex.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("could not read index: %w", err)
+	}
+	defer indexReader.Close()
+
+	fuzzyReader, ok := indexReader.(index.IndexReaderFuzzy)
+	if !ok {
+		return nil, fmt.Errorf("index does not support fuzzy term lookups")
+	}
+
+	var misspeltWords []misspeltWord
+	for _, location := range suggestionWordRegexp.FindAllStringIndex(queryString, -1) {
+		word := queryString[location[0]:location[1]]
+
+		// Stop words and words that are split up when searching are left as they are
+		terms := b.analyzeQueryText(word)
+		if len(terms) != 1 || terms[0] != word {
+			continue
+		}
+
+		fuzziness := b.fuzzinessForTerm(word, FuzzinessAuto)
+		if fuzziness == 0 {
+			fuzziness = b.fuzzinessForTerm(word, 1)
+		}
+		if fuzziness == 0 {
+			continue
+		}
+
+		corrections, found, err := b.findCorrections(fuzzyReader, word, fuzziness)
+		if err != nil {
+			return nil, err
+		}
+		if found || len(corrections) == 0 {
+			continue
+		}
+
+		misspeltWords = append(misspeltWords, misspeltWord{start: location[0], end: location[1], corrections: corrections})
+	}
+
+	return buildQuerySuggestions(queryString, misspeltWords), nil
+}
+
+// findCorrections looks up terms within the given edit distance of a word. It returns true if the word
+// itself is in the vocabulary, in which case there is nothing to correct.
+func (b *BleveDB) findCorrections(fuzzyReader index.IndexReaderFuzzy, word string, fuzziness int) ([]correction, bool, error) {
+	docFrequencies := map[string]uint64{}
+	for _, field := range []string{indexFieldContent, indexFieldName} {
+		fieldDict, err := fuzzyReader.FieldDictFuzzy(field, word, fuzziness, "")
+		if err != nil {
+			return nil, false, fmt.Errorf("could not look up terms similar to %q: %w", word, err)
+		}
+
+		// A file with a term in both its name and its content is counted once, by keeping the larger count
+		entry, err := fieldDict.Next()
+		for err == nil && entry != nil {
+			docFrequencies[entry.Term] = max(docFrequencies[entry.Term], entry.Count)
+			entry, err = fieldDict.Next()
+		}
+		fieldDict.Close()
+		if err != nil {
+			return nil, false, fmt.Errorf("could not look up terms similar to %q: %w", word, err)
+		}
+	}
+
+	if docFrequencies[word] > 0 {
+		return nil, true, nil
+	}
+
+	corrections := make([]correction, 0, len(docFrequencies))
+	for term, docFrequency := range docFrequencies {
+		corrections = append(corrections, correction{term: term, editDistance: editDistance(word, term), docFrequency: docFrequency})
+	}
+	sortCorrections(corrections)
+
+	return corrections[:min(maxCorrectionsPerWord, len(corrections))], false, nil
+}
+
+// sortCorrections puts the corrections appearing in the most documents first
+func sortCorrections(corrections []correction) {
+	sort.Slice(corrections, func(i, j int) bool {
+		if corrections[i].docFrequency != corrections[j].docFrequency {
+			return corrections[i].docFrequency > corrections[j].docFrequency
+		}
+		if corrections[i].editDistance != corrections[j].editDistance {
+			return corrections[i].editDistance < corrections[j].editDistance
+		}
+		return corrections[i].term < corrections[j].term
+	})
+}
+
+// buildQuerySuggestions replaces misspelt words in a query with their corrections. The first suggestion
+// uses the best correction of every word and the rest swap in the other corrections of one word at a time.
+// Suggestions are ranked by the document frequency of their rarest correction.
+func buildQuerySuggestions(queryString string, misspeltWords []misspeltWord) []string {
+	if len(misspeltWords) == 0 {
+		return nil
+	}
+
+	type suggestion struct {
+		query        string
+		docFrequency uint64
+	}
+
+	build := func(choices []int) suggestion {
+		var builder strings.Builder
+		previousEnd := 0
+		docFrequency := misspeltWords[0].corrections[choices[0]].docFrequency
+		for i, word := range misspeltWords {
+			chosen := word.corrections[choices[i]]
+			builder.WriteString(queryString[previousEnd:word.start])
+			builder.WriteString(chosen.term)
+			previousEnd = word.end
+			docFrequency = min(docFrequency, chosen.docFrequency)
+		}
+		builder.WriteString(queryString[previousEnd:])
+		return suggestion{query: builder.String(), docFrequency: docFrequency}
+	}
+
+	bestChoices := make([]int, len(misspeltWords))
+	suggestions := []suggestion{build(bestChoices)}
+	for i, word := range misspeltWords {
+		for j := 1; j < len(word.corrections); j++ {
+			choices := make([]int, len(misspeltWords))
+			choices[i] = j
+			suggestions = append(suggestions, build(choices))
+		}
+	}
+
+	// The first suggestion stays first, since each of its corrections is the best one for its word
+	sort.SliceStable(suggestions[1:], func(i, j int) bool {
+		return suggestions[i+1].docFrequency > suggestions[j+1].docFrequency
+	})
+
+	queries := make([]string, 0, maxQuerySuggestions)
+	for _, s := range suggestions[:min(maxQuerySuggestions, len(suggestions))] {
+		queries = append(queries, s.query)
+	}
+
+	return queries
+}
@@ -0,0 +1,88 @@
+package searchdb
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/standard"
+	index "github.com/blevesearch/bleve_index_api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildQuerySuggestions(t *testing.T) {
+	assert := require.New(t)
+
+	queryString := `"helo" wrld again`
+	misspeltWords := []misspeltWord{
+		{
+			start: 1,
+			end:   5,
+			corrections: []correction{
+				{term: "hello", editDistance: 1, docFrequency: 10},
+				{term: "help", editDistance: 1, docFrequency: 2},
+			},
+		},
+		{
+			start: 7,
+			end:   11,
+			corrections: []correction{
+				{term: "world", editDistance: 1, docFrequency: 4},
+				{term: "word", editDistance: 1, docFrequency: 3},
+			},
+		},
+	}
+
+	suggestions := buildQuerySuggestions(queryString, misspeltWords)
+	assert.Equal([]string{`"hello" world again`, `"hello" word again`, `"help" world again`}, suggestions, "should replace misspelt words in place and rank by document frequency")
+}
+
+func TestSortCorrections(t *testing.T) {
+	corrections := []correction{
+		{term: "word", editDistance: 2, docFrequency: 3},
+		{term: "world", editDistance: 1, docFrequency: 3},
+		{term: "would", editDistance: 2, docFrequency: 9},
+	}
+
+	sortCorrections(corrections)
+
+	require.Equal(t, []correction{
+		{term: "would", editDistance: 2, docFrequency: 9},
+		{term: "world", editDistance: 1, docFrequency: 3},
+		{term: "word", editDistance: 2, docFrequency: 3},
+	}, corrections, "corrections in more documents should come first")
+}
+
+func TestFindCorrectionsCountsFilesOnce(t *testing.T) {
+	assert := require.New(t)
+
+	b := &BleveDB{
+		name:         DefaultCollection,
+		indexPath:    filepath.Join(t.TempDir(), "search.index"),
+		textAnalyzer: standard.Name,
+		logger:       slog.New(slog.NewTextHandler(os.Stderr, nil)),
+	}
+	assert.NoError(b.open(false))
+	defer b.Close()
+
+	root := t.TempDir()
+	assert.NoError(b.BuildIndex([]*Document{
+		{ID: filepath.Join(root, "budget"), Path: filepath.Join(root, "budget"), Name: "budget", Root: root, Type: DocumentTypeText, Content: "budget for the year"},
+		{ID: filepath.Join(root, "a.txt"), Path: filepath.Join(root, "a.txt"), Name: "a.txt", Root: root, Type: DocumentTypeText, Content: "budgets"},
+	}))
+
+	advancedIndex, err := b.index.Advanced()
+	assert.NoError(err)
+	indexReader, err := advancedIndex.Reader()
+	assert.NoError(err)
+	defer indexReader.Close()
+
+	corrections, found, err := b.findCorrections(indexReader.(index.IndexReaderFuzzy), "budgt", 2)
+	assert.NoError(err)
+	assert.False(found)
+	assert.Equal([]correction{
+		{term: "budget", editDistance: 1, docFrequency: 1},
+		{term: "budgets", editDistance: 2, docFrequency: 1},
+	}, corrections, "a file with a term in its name and content should be counted once")
+}
@@ -0,0 +1,283 @@
+package searchdb
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+var ErrInvalidSynonyms = errors.New("invalid synonyms")
+
+// Highlight is a part of a snippet worth pointing out, like a match of a synonym of a query term
+type Highlight struct {
+	// Start and End are the character offsets of the highlighted text within the snippet
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Term  string `json:"term"`
+}
+
+// synonyms holds sets of words and phrases that mean the same thing, like k8s and kubernetes. They are
+// read from a file with one comma separated set per line, where blank lines and lines starting with # are
+// ignored, and written back to it whenever they are updated.
+type synonyms struct {
+	path string
+
+	// saveMu is held while the synonyms file is written and the sets are replaced with what was written, so
+	// that concurrent updates leave the file and the sets the same
+	saveMu sync.Mutex
+
+	// mu guards the fields below
+	mu   sync.RWMutex
+	sets [][]string
+	// byTerm holds the other words and phrases in the set of each word or phrase
+	byTerm map[string][]string
+}
+
+func loadSynonyms(path string) (*synonyms, error) {
+	s := &synonyms{path: path}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.set(nil)
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not open synonyms file: %w", err)
+	}
+	defer file.Close()
+
+	var sets [][]string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sets = append(sets, strings.Split(line, ","))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read synonyms file: %w", err)
+	}
+
+	sets, err = normalizeSynonymSets(sets)
+	if err != nil {
+		return nil, err
+	}
+	s.set(sets)
+
+	return s, nil
+}
+
+// normalizeSynonymSets lower cases the words and phrases of synonym sets and checks that every set has at
+// least two of them. Words and phrases can not have commas, which separate them in the synonyms file, or
+// start with #, which would make a line of the file a comment.
+func normalizeSynonymSets(sets [][]string) ([][]string, error) {
+	normalized := make([][]string, 0, len(sets))
+	for _, set := range sets {
+		var terms []string
+		for _, term := range set {
+			term = strings.Join(strings.Fields(strings.ToLower(term)), " ")
+			if strings.Contains(term, ",") || strings.HasPrefix(term, "#") {
+				return nil, fmt.Errorf("%w: words and phrases can not have commas or start with #: %s", ErrInvalidSynonyms, term)
+			}
+			if len(term) > 0 && !slices.Contains(terms, term) {
+				terms = append(terms, term)
+			}
+		}
+		if len(terms) < 2 {
+			return nil, fmt.Errorf("%w: a set needs at least two different words or phrases: %s", ErrInvalidSynonyms, strings.Join(set, ","))
+		}
+		normalized = append(normalized, terms)
+	}
+
+	return normalized, nil
+}
+
+func (s *synonyms) set(sets [][]string) {
+	byTerm := map[string][]string{}
+	for _, set := range sets {
+		for _, term := range set {
+			for _, other := range set {
+				if other != term && !slices.Contains(byTerm[term], other) {
+					byTerm[term] = append(byTerm[term], other)
+				}
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sets = sets
+	s.byTerm = byTerm
+}
+
+func (s *synonyms) list() [][]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sets := make([][]string, len(s.sets))
+	for i, set := range s.sets {
+		sets[i] = slices.Clone(set)
+	}
+
+	return sets
+}
+
+func (s *synonyms) of(term string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.byTerm[term]
+}
+
+// save replaces the synonym sets and writes them to the synonyms file
+func (s *synonyms) save(sets [][]string) error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("could not create synonyms directory: %w", err)
+	}
+
+	var content strings.Builder
+	content.WriteString("# Each line is a set of words or phrases that mean the same thing, separated by commas\n")
+	for _, set := range sets {
+		content.WriteString(strings.Join(set, ", "))
+		content.WriteString("\n")
+	}
+
+	// The file is written to a temporary file first, so that a failed write never leaves a partial file behind
+	tempPath := s.path + ".tmp"
+	if err := os.WriteFile(tempPath, []byte(content.String()), 0644); err != nil {
+		return fmt.Errorf("could not write synonyms file: %w", err)
+	}
+	if err := os.Rename(tempPath, s.path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("could not replace synonyms file: %w", err)
+	}
+
+	s.set(sets)
+
+	return nil
+}
+
+// GetSynonyms returns the synonym sets applied to queries
+func (b *BleveDB) GetSynonyms() [][]string {
+	return b.synonyms.list()
+}
+
+// SetSynonyms replaces the synonym sets applied to queries. Searches use the new sets right away.
+func (b *BleveDB) SetSynonyms(sets [][]string) error {
+	sets, err := normalizeSynonymSets(sets)
+	if err != nil {
+		return err
+	}
+
+	if err := b.synonyms.save(sets); err != nil {
+		b.logger.Error("could not save synonyms", "path", b.synonyms.path, "err", err.Error())
+		return err
+	}
+
+	b.logger.Info("updated synonyms", "sets", len(sets))
+
+	return nil
+}
+
+// buildSynonymSubQueries adds alternatives for the synonyms of a query term, matching them in the content
+// and name of documents. Matches of synonyms are weighted lower than matches of the term itself. Only single
+// words of a query are looked up, but they may be expanded into phrases.
+func (b *BleveDB) buildSynonymSubQueries(query *query.DisjunctionQuery, term string, options queryOptions) {
+	for _, synonym := range b.synonyms.of(term) {
+		contentQuery := bleve.NewMatchPhraseQuery(synonym)
+		contentQuery.Analyzer = queryAnalyzer
+		contentQuery.SetField(indexFieldContent)
+		contentQuery.SetBoost(options.profile.Content * options.profile.Synonym)
+		query.AddQuery(contentQuery)
+
+		nameQuery := bleve.NewMatchPhraseQuery(synonym)
+		nameQuery.Analyzer = queryAnalyzer
+		nameQuery.SetField(indexFieldName)
+		nameQuery.SetBoost(options.profile.FileName * options.profile.Synonym)
+		query.AddQuery(nameQuery)
+	}
+}
+
+// expandSynonyms returns the synonyms that the terms of a query were expanded with
+func (b *BleveDB) expandSynonyms(queryString string) map[string][]string {
+	expansions := map[string][]string{}
+	for _, term := range strings.Fields(strings.ToLower(strings.ReplaceAll(queryString, `"`, " "))) {
+		if synonyms := b.synonyms.of(term); len(synonyms) > 0 {
+			expansions[term] = synonyms
+		}
+	}
+
+	if len(expansions) == 0 {
+		return nil
+	}
+	return expansions
+}
+
+// synonymTerms returns the indexed terms of all the synonyms a query was expanded with
+func (b *BleveDB) synonymTerms(expansions map[string][]string) map[string]struct{} {
+	terms := map[string]struct{}{}
+	for _, synonyms := range expansions {
+		for _, synonym := range synonyms {
+			for _, term := range b.analyzeQueryText(synonym) {
+				terms[term] = struct{}{}
+			}
+		}
+	}
+
+	return terms
+}
+
+// snippetHighlights returns the parts of a snippet where the given terms matched. The snippet is the text
+// of a file from snippetStart, formatted by formatSnippet.
+func snippetHighlights(snippet string, rawSnippet string, snippetStart int64, termLocations search.TermLocationMap, terms map[string]struct{}) []Highlight {
+	if len(terms) == 0 {
+		return nil
+	}
+
+	// formatSnippet trims the text and may add an ellipsis before it
+	trimmed := int64(len(rawSnippet) - len(strings.TrimLeftFunc(rawSnippet, unicode.IsSpace)))
+	contentLength := int64(len(strings.TrimSpace(rawSnippet)))
+	offset := int64(0)
+	if snippetStart > 0 {
+		offset = int64(len("..."))
+	}
+
+	var highlights []Highlight
+	for term, locations := range termLocations {
+		if _, ok := terms[term]; !ok {
+			continue
+		}
+		for _, location := range locations {
+			start := int64(location.Start) - snippetStart - trimmed + offset
+			end := int64(location.End) - snippetStart - trimmed + offset
+			if start < offset || end > offset+contentLength {
+				continue
+			}
+			highlights = append(highlights, Highlight{
+				Start: utf8.RuneCountInString(snippet[:start]),
+				End:   utf8.RuneCountInString(snippet[:end]),
+				Term:  term,
+			})
+		}
+	}
+
+	slices.SortFunc(highlights, func(a, b Highlight) int { return a.Start - b.Start })
+
+	return highlights
+}
@@ -0,0 +1,77 @@
+package searchdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadAndSaveSynonyms(t *testing.T) {
+	assert := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "synonyms.txt")
+	err := os.WriteFile(path, []byte("# comment\n\nk8s, Kubernetes\ndb,database , DB\n"), 0644)
+	assert.NoError(err)
+
+	s, err := loadSynonyms(path)
+	assert.NoError(err)
+	assert.Equal([][]string{{"k8s", "kubernetes"}, {"db", "database"}}, s.list())
+	assert.Equal([]string{"kubernetes"}, s.of("k8s"))
+
+	assert.NoError(s.save([][]string{{"auth", "authentication", "single sign on"}}))
+	assert.Empty(s.of("k8s"), "saved sets should replace the old ones")
+
+	reloaded, err := loadSynonyms(path)
+	assert.NoError(err)
+	assert.Equal([]string{"auth", "single sign on"}, reloaded.of("authentication"), "saved sets should be read back")
+
+	_, err = normalizeSynonymSets([][]string{{"db", "DB"}})
+	assert.ErrorIs(err, ErrInvalidSynonyms, "a set of one term is not valid")
+	_, err = normalizeSynonymSets([][]string{{"a,b", "c"}})
+	assert.ErrorIs(err, ErrInvalidSynonyms, "terms with commas would be split when the file is read back")
+	_, err = normalizeSynonymSets([][]string{{"#tag", "tag"}})
+	assert.ErrorIs(err, ErrInvalidSynonyms, "a line starting with # would be read back as a comment")
+}
+
+func TestSaveSynonymsConcurrently(t *testing.T) {
+	assert := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "synonyms.txt")
+	s, err := loadSynonyms(path)
+	assert.NoError(err)
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(s.save([][]string{{"term", fmt.Sprintf("synonym%d", i)}}))
+		}()
+	}
+	wg.Wait()
+
+	reloaded, err := loadSynonyms(path)
+	assert.NoError(err)
+	assert.Equal(s.list(), reloaded.list(), "the file should hold the sets that were saved last")
+}
+
+func TestSnippetHighlights(t *testing.T) {
+	assert := require.New(t)
+
+	// The raw snippet starts at byte 10 of the file, with a space that formatSnippet trims
+	raw := " café uses postgres"
+	snippet := formatSnippet(raw, 10, 10+int64(len(raw)), 100)
+	locations := search.TermLocationMap{
+		"postgres": {{Start: 10 + 12, End: 10 + 20}},
+		"café":     {{Start: 10 + 1, End: 10 + 6}},
+	}
+
+	highlights := snippetHighlights(snippet, raw, 10, locations, map[string]struct{}{"postgres": {}})
+	assert.Equal([]Highlight{{Start: 13, End: 21, Term: "postgres"}}, highlights)
+	assert.Equal("postgres", string([]rune(snippet)[13:21]), "offsets should count characters")
+}
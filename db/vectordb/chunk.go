@@ -0,0 +1,40 @@
+package vectordb
+
+import "regexp"
+
+const (
+	// Documents are embedded in chunks of this many words, so that a passage about one topic in a long
+	// document is not drowned out by the rest of it
+	chunkWords = 200
+	// Consecutive chunks share this many words, so that passages spanning chunks are not lost
+	chunkOverlapWords = 40
+	// Only the start of very long documents is embedded
+	maxChunksPerDocument = 64
+)
+
+var nonSpaceRegexp = regexp.MustCompile(`\S+`)
+
+// chunk is a passage of a document, identified by its byte offsets within the document
+type chunk struct {
+	start int
+	end   int
+}
+
+// splitIntoChunks splits content into overlapping passages of words
+func splitIntoChunks(content string) []chunk {
+	words := nonSpaceRegexp.FindAllStringIndex(content, -1)
+	if len(words) == 0 {
+		return nil
+	}
+
+	var chunks []chunk
+	for start := 0; start < len(words) && len(chunks) < maxChunksPerDocument; start += chunkWords - chunkOverlapWords {
+		end := min(start+chunkWords, len(words))
+		chunks = append(chunks, chunk{start: words[start][0], end: words[end-1][1]})
+		if end == len(words) {
+			break
+		}
+	}
+
+	return chunks
+}
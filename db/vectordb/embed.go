@@ -0,0 +1,151 @@
+package vectordb
+
+import (
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+
+	porterstemmer "github.com/blevesearch/go-porterstemmer"
+)
+
+// Dimensions is the length of every embedding
+const Dimensions = 512
+
+const (
+	// Character trigrams let different forms of a word, and words sharing a root, end up close together
+	trigramWeight = 0.5
+	minWordLength = 2
+)
+
+var stopWords = map[string]struct{}{
+	"a": {}, "an": {}, "and": {}, "are": {}, "as": {}, "at": {}, "be": {}, "but": {}, "by": {}, "do": {},
+	"does": {}, "for": {}, "from": {}, "has": {}, "have": {}, "how": {}, "i": {}, "if": {}, "in": {},
+	"into": {}, "is": {}, "it": {}, "its": {}, "no": {}, "not": {}, "of": {}, "on": {}, "or": {}, "our": {},
+	"so": {}, "such": {}, "that": {}, "the": {}, "their": {}, "then": {}, "there": {}, "these": {},
+	"they": {}, "this": {}, "to": {}, "was": {}, "we": {}, "were": {}, "what": {}, "when": {}, "where": {},
+	"which": {}, "who": {}, "why": {}, "will": {}, "with": {}, "you": {},
+}
+
+// Embed turns text into a normalized vector, without needing a model. Words are stemmed and hashed into
+// the vector along with their character trigrams, so texts sharing words or word roots have similar vectors.
+// A text without any words is embedded as a zero vector.
+func Embed(text string) []float32 {
+	wordCounts := map[string]int{}
+	for _, word := range splitWords(text) {
+		wordCounts[word]++
+	}
+
+	vector := make([]float32, Dimensions)
+	for word, count := range wordCounts {
+		// Repeated words count for less than their number of repetitions
+		weight := 1 + math.Log(float64(count))
+		addFeature(vector, "w:"+word, weight)
+
+		trigrams := wordTrigrams(word)
+		for _, trigram := range trigrams {
+			addFeature(vector, "t:"+trigram, weight*trigramWeight/math.Sqrt(float64(len(trigrams))))
+		}
+	}
+
+	normalize(vector)
+
+	return vector
+}
+
+// splitWords returns the stemmed, lower case words of a text, leaving out stop words. Identifiers like
+// retryPayment are split into their words.
+func splitWords(text string) []string {
+	var words []string
+	addWord := func(word []rune) {
+		if len(word) < minWordLength {
+			return
+		}
+		lowerWord := strings.ToLower(string(word))
+		if _, ok := stopWords[lowerWord]; ok {
+			return
+		}
+		words = append(words, porterstemmer.StemString(lowerWord))
+	}
+
+	var word []rune
+	var previous rune
+	for _, r := range text {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			addWord(word)
+			word = word[:0]
+			previous = r
+			continue
+		}
+		if len(word) > 0 && unicode.IsLower(previous) && unicode.IsUpper(r) {
+			addWord(word)
+			word = word[:0]
+		}
+		word = append(word, r)
+		previous = r
+	}
+	addWord(word)
+
+	return words
+}
+
+func wordTrigrams(word string) []string {
+	runes := []rune("^" + word + "$")
+	if len(runes) < 3 {
+		return nil
+	}
+
+	trigrams := make([]string, 0, len(runes)-2)
+	for i := 0; i+3 <= len(runes); i++ {
+		trigrams = append(trigrams, string(runes[i:i+3]))
+	}
+
+	return trigrams
+}
+
+// addFeature hashes a feature to a dimension of the vector. The sign also comes from the hash, so that
+// features colliding on a dimension tend to cancel out instead of adding up.
+func addFeature(vector []float32, feature string, weight float64) {
+	hash := fnv.New64a()
+	hash.Write([]byte(feature))
+	sum := hash.Sum64()
+
+	dimension := sum % Dimensions
+	if (sum>>32)&1 == 1 {
+		weight = -weight
+	}
+	vector[dimension] += float32(weight)
+}
+
+func normalize(vector []float32) {
+	var sumOfSquares float64
+	for _, value := range vector {
+		sumOfSquares += float64(value) * float64(value)
+	}
+	if sumOfSquares == 0 {
+		return
+	}
+
+	norm := float32(math.Sqrt(sumOfSquares))
+	for i := range vector {
+		vector[i] /= norm
+	}
+}
+
+func isZero(vector []float32) bool {
+	for _, value := range vector {
+		if value != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// similarity returns the cosine similarity of two normalized vectors
+func similarity(a []float32, b []float32) float32 {
+	var dot float32
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+	return dot
+}
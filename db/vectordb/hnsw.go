@@ -0,0 +1,281 @@
+package vectordb
+
+import (
+	"container/heap"
+	"math"
+	"math/rand/v2"
+	"sort"
+)
+
+// HNSW parameters, see "Efficient and robust approximate nearest neighbor search using Hierarchical
+// Navigable Small World graphs" by Malkov and Yashunin
+const (
+	// Maximum number of neighbors of a node on every layer but the bottom one
+	maxNeighbors = 16
+	// Maximum number of neighbors of a node on the bottom layer
+	maxNeighborsOnBottomLayer = 2 * maxNeighbors
+	// Number of candidates considered when picking the neighbors of a new node
+	efConstruction = 100
+	// Minimum number of candidates considered when searching
+	efSearch = 64
+)
+
+// graphNode is a vector in the graph. Nodes that are deleted stay in the graph, so that searches can still
+// pass through them, until the graph is compacted.
+type graphNode struct {
+	Vector []float32
+	// Neighbors holds the neighbors of the node on each layer, from the bottom one up
+	Neighbors [][]uint32
+	DocID     string
+	// Start and End are the byte offsets of the embedded passage within the document
+	Start   int
+	End     int
+	Deleted bool
+}
+
+// graph is a hierarchical navigable small world graph for approximate nearest neighbor search
+type graph struct {
+	Nodes []*graphNode
+	// EntryPoint is the node searches start from, or -1 if the graph is empty
+	EntryPoint int
+	MaxLayer   int
+	// DeletedCount is the number of deleted nodes still in the graph
+	DeletedCount int
+
+	rng *rand.Rand
+}
+
+func newGraph() *graph {
+	return &graph{EntryPoint: -1, rng: newRand(0)}
+}
+
+// newRand returns a random number generator with a fixed seed, so that graphs are built the same way each time
+func newRand(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, 0x9e3779b97f4a7c15))
+}
+
+// candidate is a node along with its distance from the vector being searched for
+type candidate struct {
+	id       uint32
+	distance float32
+}
+
+// candidateHeap is a heap of candidates, closest first unless farthestFirst is set
+type candidateHeap struct {
+	items         []candidate
+	farthestFirst bool
+}
+
+func (h *candidateHeap) Len() int { return len(h.items) }
+func (h *candidateHeap) Less(i, j int) bool {
+	if h.farthestFirst {
+		return h.items[i].distance > h.items[j].distance
+	}
+	return h.items[i].distance < h.items[j].distance
+}
+func (h *candidateHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *candidateHeap) Push(x any)    { h.items = append(h.items, x.(candidate)) }
+func (h *candidateHeap) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+func distance(a []float32, b []float32) float32 {
+	return 1 - similarity(a, b)
+}
+
+// randomLayer picks the top layer of a new node. Each layer has about 1/maxNeighbors as many nodes as the one below it.
+func (g *graph) randomLayer() int {
+	return int(math.Floor(-math.Log(1-g.rng.Float64()) / math.Log(maxNeighbors)))
+}
+
+// insert adds a node to the graph and connects it to its nearest neighbors on each of its layers
+func (g *graph) insert(node *graphNode) {
+	id := uint32(len(g.Nodes))
+	layer := g.randomLayer()
+	node.Neighbors = make([][]uint32, layer+1)
+	g.Nodes = append(g.Nodes, node)
+
+	if g.EntryPoint < 0 {
+		g.EntryPoint = int(id)
+		g.MaxLayer = layer
+		return
+	}
+
+	entryPoint := uint32(g.EntryPoint)
+	for l := g.MaxLayer; l > layer; l-- {
+		entryPoint = g.searchLayer(node.Vector, entryPoint, 1, l)[0].id
+	}
+
+	for l := min(layer, g.MaxLayer); l >= 0; l-- {
+		candidates := g.searchLayer(node.Vector, entryPoint, efConstruction, l)
+		neighbors := g.selectNeighbors(candidates, maxNeighbors)
+		node.Neighbors[l] = neighbors
+
+		for _, neighborID := range neighbors {
+			neighbor := g.Nodes[neighborID]
+			neighbor.Neighbors[l] = append(neighbor.Neighbors[l], id)
+			if len(neighbor.Neighbors[l]) > maxNeighborsForLayer(l) {
+				g.pruneNeighbors(neighbor, l)
+			}
+		}
+
+		entryPoint = candidates[0].id
+	}
+
+	if layer > g.MaxLayer {
+		g.EntryPoint = int(id)
+		g.MaxLayer = layer
+	}
+}
+
+func maxNeighborsForLayer(layer int) int {
+	if layer == 0 {
+		return maxNeighborsOnBottomLayer
+	}
+	return maxNeighbors
+}
+
+// pruneNeighbors keeps the best neighbors of a node on a layer once it has too many
+func (g *graph) pruneNeighbors(node *graphNode, layer int) {
+	candidates := make([]candidate, 0, len(node.Neighbors[layer]))
+	for _, neighborID := range node.Neighbors[layer] {
+		candidates = append(candidates, candidate{id: neighborID, distance: distance(node.Vector, g.Nodes[neighborID].Vector)})
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+
+	node.Neighbors[layer] = g.selectNeighbors(candidates, maxNeighborsForLayer(layer))
+}
+
+// selectNeighbors picks neighbors from candidates sorted by distance. A candidate is skipped if it is closer
+// to an already picked neighbor than to the node, so that neighbors point in different directions and the
+// graph stays connected. Skipped candidates fill up any remaining places.
+func (g *graph) selectNeighbors(candidates []candidate, count int) []uint32 {
+	selected := make([]uint32, 0, count)
+	var skipped []uint32
+
+	for _, c := range candidates {
+		if len(selected) == count {
+			break
+		}
+
+		diverse := true
+		for _, selectedID := range selected {
+			if distance(g.Nodes[c.id].Vector, g.Nodes[selectedID].Vector) < c.distance {
+				diverse = false
+				break
+			}
+		}
+
+		if diverse {
+			selected = append(selected, c.id)
+		} else {
+			skipped = append(skipped, c.id)
+		}
+	}
+
+	for _, id := range skipped {
+		if len(selected) == count {
+			break
+		}
+		selected = append(selected, id)
+	}
+
+	return selected
+}
+
+// searchLayer finds the ef nodes on a layer closest to a vector, starting from an entry point. The nodes
+// are returned closest first.
+func (g *graph) searchLayer(vector []float32, entryPoint uint32, ef int, layer int) []candidate {
+	visited := map[uint32]struct{}{entryPoint: {}}
+	entry := candidate{id: entryPoint, distance: distance(vector, g.Nodes[entryPoint].Vector)}
+
+	// Candidates still to be expanded, closest first
+	candidates := &candidateHeap{items: []candidate{entry}}
+	// The closest nodes found so far, farthest first so that it is quick to replace
+	results := &candidateHeap{items: []candidate{entry}, farthestFirst: true}
+
+	for candidates.Len() > 0 {
+		current := heap.Pop(candidates).(candidate)
+		if current.distance > results.items[0].distance && results.Len() >= ef {
+			break
+		}
+
+		node := g.Nodes[current.id]
+		if layer >= len(node.Neighbors) {
+			continue
+		}
+
+		for _, neighborID := range node.Neighbors[layer] {
+			if _, ok := visited[neighborID]; ok {
+				continue
+			}
+			visited[neighborID] = struct{}{}
+
+			neighbor := candidate{id: neighborID, distance: distance(vector, g.Nodes[neighborID].Vector)}
+			if results.Len() < ef || neighbor.distance < results.items[0].distance {
+				heap.Push(candidates, neighbor)
+				heap.Push(results, neighbor)
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	sorted := results.items
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].distance < sorted[j].distance })
+
+	return sorted
+}
+
+// search returns up to k live nodes closest to a vector, closest first
+func (g *graph) search(vector []float32, k int, ef int) []candidate {
+	if g.EntryPoint < 0 || k <= 0 {
+		return nil
+	}
+
+	entryPoint := uint32(g.EntryPoint)
+	for l := g.MaxLayer; l > 0; l-- {
+		entryPoint = g.searchLayer(vector, entryPoint, 1, l)[0].id
+	}
+
+	// Deleted nodes are among the candidates, so more are looked at to still find k live ones
+	ef = max(ef, k) + g.DeletedCount*max(ef, k)/max(1, len(g.Nodes)-g.DeletedCount)
+
+	var nearest []candidate
+	for _, c := range g.searchLayer(vector, entryPoint, ef, 0) {
+		if g.Nodes[c.id].Deleted {
+			continue
+		}
+		nearest = append(nearest, c)
+		if len(nearest) == k {
+			break
+		}
+	}
+
+	return nearest
+}
+
+// delete marks a node as deleted, leaving it in the graph
+func (g *graph) delete(id uint32) {
+	if g.Nodes[id].Deleted {
+		return
+	}
+	g.Nodes[id].Deleted = true
+	g.DeletedCount++
+}
+
+// compacted returns a new graph with only the live nodes
+func (g *graph) compacted() *graph {
+	compacted := newGraph()
+	for _, node := range g.Nodes {
+		if node.Deleted {
+			continue
+		}
+		compacted.insert(&graphNode{Vector: node.Vector, DocID: node.DocID, Start: node.Start, End: node.End})
+	}
+
+	return compacted
+}
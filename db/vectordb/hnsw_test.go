@@ -0,0 +1,84 @@
+package vectordb
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func randomVectors(count int, dimensions int) [][]float32 {
+	rng := newRand(42)
+	vectors := make([][]float32, count)
+	for i := range vectors {
+		vector := make([]float32, dimensions)
+		for j := range vector {
+			vector[j] = float32(rng.NormFloat64())
+		}
+		normalize(vector)
+		vectors[i] = vector
+	}
+	return vectors
+}
+
+func bruteForceNearest(vectors [][]float32, query []float32, k int) []uint32 {
+	ids := make([]uint32, len(vectors))
+	for i := range ids {
+		ids[i] = uint32(i)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return distance(query, vectors[ids[i]]) < distance(query, vectors[ids[j]])
+	})
+	return ids[:k]
+}
+
+func TestGraphSearchRecall(t *testing.T) {
+	assert := require.New(t)
+
+	const k = 10
+	vectors := randomVectors(2000, 32)
+	queries := randomVectors(50, 32)
+
+	g := newGraph()
+	for _, vector := range vectors {
+		g.insert(&graphNode{Vector: vector})
+	}
+
+	found := 0
+	for _, query := range queries {
+		expected := map[uint32]struct{}{}
+		for _, id := range bruteForceNearest(vectors, query, k) {
+			expected[id] = struct{}{}
+		}
+		for _, c := range g.search(query, k, efSearch) {
+			if _, ok := expected[c.id]; ok {
+				found++
+			}
+		}
+	}
+
+	recall := float64(found) / float64(k*len(queries))
+	assert.GreaterOrEqual(recall, 0.9, "approximate search should find most of the exact nearest neighbors")
+}
+
+func TestGraphSearchSkipsDeletedNodes(t *testing.T) {
+	assert := require.New(t)
+
+	vectors := randomVectors(200, 16)
+	g := newGraph()
+	for _, vector := range vectors {
+		g.insert(&graphNode{Vector: vector})
+	}
+
+	nearest := g.search(vectors[7], 1, efSearch)
+	assert.Equal(uint32(7), nearest[0].id, "a vector should be its own nearest neighbor")
+
+	g.delete(7)
+	for _, c := range g.search(vectors[7], 10, efSearch) {
+		assert.NotEqual(uint32(7), c.id, "deleted nodes should not be returned")
+	}
+
+	compacted := g.compacted()
+	assert.Len(compacted.Nodes, 199, "compacting should drop deleted nodes")
+	assert.Zero(compacted.DeletedCount)
+}
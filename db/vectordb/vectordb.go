@@ -0,0 +1,284 @@
+package vectordb
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/meghashyamc/wheresthat/logger"
+)
+
+// formatVersion must be incremented whenever the way documents are embedded or stored changes
+const formatVersion = 1
+
+// Passages are looked up several times over the number of documents wanted, since a document may have
+// several passages among the closest ones
+const passagesPerDocument = 4
+
+// Match is a document whose content is similar to a query, along with its most similar passage
+type Match struct {
+	DocID string
+	// Score is the cosine similarity of the query and the passage
+	Score float64
+	// Start and End are the byte offsets of the passage within the document
+	Start int
+	End   int
+}
+
+// persistedGraph is the format the graph is stored in on disk
+type persistedGraph struct {
+	FormatVersion int
+	Dimensions    int
+	Graph         *graph
+}
+
+// VectorDB stores embeddings of document passages in an HNSW graph that is kept in memory and saved to a file
+type VectorDB struct {
+	path   string
+	logger logger.Logger
+
+	// mu guards all of the fields below
+	mu    sync.RWMutex
+	graph *graph
+	// docNodes holds the live nodes of each document
+	docNodes map[string][]uint32
+	// loaded is true if the graph was read from a file rather than started empty
+	loaded bool
+	dirty  bool
+}
+
+// New opens the vector database stored at path. If there is no usable file there, an empty database is started.
+func New(logger logger.Logger, path string) (*VectorDB, error) {
+	v := &VectorDB{
+		path:     path,
+		logger:   logger,
+		graph:    newGraph(),
+		docNodes: map[string][]uint32{},
+	}
+
+	persisted, err := readGraph(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Warn("could not read vector index, it will be rebuilt", "path", path, "err", err.Error())
+		}
+		return v, nil
+	}
+	if persisted.FormatVersion != formatVersion || persisted.Dimensions != Dimensions {
+		logger.Warn("vector index was built with an older format, it will be rebuilt", "path", path, "format_version", persisted.FormatVersion)
+		return v, nil
+	}
+
+	v.graph = persisted.Graph
+	v.graph.rng = newRand(uint64(len(v.graph.Nodes)))
+	for id, node := range v.graph.Nodes {
+		if !node.Deleted {
+			v.docNodes[node.DocID] = append(v.docNodes[node.DocID], uint32(id))
+		}
+	}
+	v.loaded = true
+
+	return v, nil
+}
+
+func readGraph(path string) (*persistedGraph, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	persisted := &persistedGraph{}
+	if err := gob.NewDecoder(file).Decode(persisted); err != nil {
+		return nil, fmt.Errorf("could not decode vector index: %w", err)
+	}
+	if persisted.Graph == nil {
+		return nil, fmt.Errorf("vector index has no graph")
+	}
+
+	return persisted, nil
+}
+
+// Loaded returns true if the database was read from a file, rather than started empty
+func (v *VectorDB) Loaded() bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	return v.loaded
+}
+
+// Index embeds the passages of a document, replacing any passages it had before
+func (v *VectorDB) Index(docID string, content string) {
+	chunks := splitIntoChunks(content)
+
+	// Embedding is the slow part, so it happens before taking the lock
+	nodes := make([]*graphNode, 0, len(chunks))
+	for _, c := range chunks {
+		vector := Embed(content[c.start:c.end])
+		if isZero(vector) {
+			continue
+		}
+		nodes = append(nodes, &graphNode{Vector: vector, DocID: docID, Start: c.start, End: c.end})
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.deleteDocument(docID)
+	for _, node := range nodes {
+		v.docNodes[docID] = append(v.docNodes[docID], uint32(len(v.graph.Nodes)))
+		v.graph.insert(node)
+	}
+	v.dirty = true
+}
+
+// Delete removes the passages of documents
+func (v *VectorDB) Delete(docIDs []string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for _, docID := range docIDs {
+		v.deleteDocument(docID)
+	}
+	v.dirty = true
+}
+
+func (v *VectorDB) deleteDocument(docID string) {
+	for _, id := range v.docNodes[docID] {
+		v.graph.delete(id)
+	}
+	delete(v.docNodes, docID)
+}
+
+// Search returns up to limit documents with passages similar to a query, most similar first
+func (v *VectorDB) Search(query string, limit int) []Match {
+	vector := Embed(query)
+	if isZero(vector) || limit <= 0 {
+		return nil
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	passageCount := limit * passagesPerDocument
+	nearest := v.graph.search(vector, passageCount, max(efSearch, passageCount))
+
+	// The closest passage of each document decides its score
+	matches := make([]Match, 0, limit)
+	seen := map[string]struct{}{}
+	for _, c := range nearest {
+		node := v.graph.Nodes[c.id]
+		if _, ok := seen[node.DocID]; ok {
+			continue
+		}
+		seen[node.DocID] = struct{}{}
+		matches = append(matches, Match{DocID: node.DocID, Score: float64(1 - c.distance), Start: node.Start, End: node.End})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+
+	return matches[:min(limit, len(matches))]
+}
+
+// Save writes the database to its file if it changed. The graph is compacted first if most of it is deleted nodes.
+func (v *VectorDB) Save() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if !v.dirty {
+		return nil
+	}
+
+	if v.graph.DeletedCount > len(v.graph.Nodes)/2 {
+		v.compact()
+	}
+
+	if err := v.writeGraph(v.path); err != nil {
+		return err
+	}
+
+	v.loaded = true
+	v.dirty = false
+
+	return nil
+}
+
+// Snapshot writes a copy of the database to path, including changes that were not saved yet
+func (v *VectorDB) Snapshot(path string) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	return v.writeGraph(path)
+}
+
+// writeGraph writes the graph to a file at path. The caller must hold v.mu.
+func (v *VectorDB) writeGraph(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		v.logger.Error("could not create vector index directory", "path", path, "err", err.Error())
+		return fmt.Errorf("could not create vector index directory: %w", err)
+	}
+
+	// The graph is written to a temporary file first, so that a failed write never leaves a corrupt index behind
+	tempPath := path + ".tmp"
+	file, err := os.Create(tempPath)
+	if err != nil {
+		v.logger.Error("could not create vector index file", "path", tempPath, "err", err.Error())
+		return fmt.Errorf("could not create vector index file: %w", err)
+	}
+
+	persisted := persistedGraph{FormatVersion: formatVersion, Dimensions: Dimensions, Graph: v.graph}
+	if err := gob.NewEncoder(file).Encode(persisted); err != nil {
+		file.Close()
+		os.Remove(tempPath)
+		v.logger.Error("could not write vector index", "path", tempPath, "err", err.Error())
+		return fmt.Errorf("could not write vector index: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tempPath)
+		v.logger.Error("could not write vector index", "path", tempPath, "err", err.Error())
+		return fmt.Errorf("could not write vector index: %w", err)
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		v.logger.Error("could not replace vector index", "path", path, "err", err.Error())
+		return fmt.Errorf("could not replace vector index: %w", err)
+	}
+
+	return nil
+}
+
+func (v *VectorDB) compact() {
+	v.logger.Info("compacting vector index", "nodes", len(v.graph.Nodes), "deleted_nodes", v.graph.DeletedCount)
+
+	v.graph = v.graph.compacted()
+	v.docNodes = map[string][]uint32{}
+	for id, node := range v.graph.Nodes {
+		v.docNodes[node.DocID] = append(v.docNodes[node.DocID], uint32(id))
+	}
+}
+
+// Reset deletes all documents, along with the database file
+func (v *VectorDB) Reset() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if err := os.Remove(v.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		v.logger.Error("could not remove vector index", "path", v.path, "err", err.Error())
+		return err
+	}
+
+	v.graph = newGraph()
+	v.docNodes = map[string][]uint32{}
+	v.loaded = false
+	v.dirty = false
+
+	return nil
+}
+
+// Close saves any changes to the database
+func (v *VectorDB) Close() error {
+	return v.Save()
+}
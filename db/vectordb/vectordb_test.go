@@ -0,0 +1,72 @@
+package vectordb
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbedSimilarity(t *testing.T) {
+	assert := require.New(t)
+
+	query := Embed("where do we retry failed payments")
+	related := Embed("Charges that fail are retried with exponential backoff until the payment goes through")
+	unrelated := Embed("The cache is warmed up at startup and evicts the least recently used entries")
+
+	assert.Greater(similarity(query, related), similarity(query, unrelated), "text sharing word roots should be more similar")
+	assert.InDelta(1.0, similarity(related, related), 1e-5, "embeddings should be normalized")
+	assert.True(isZero(Embed("the and of")), "text with only stop words should have a zero embedding")
+}
+
+func TestSplitIntoChunks(t *testing.T) {
+	assert := require.New(t)
+
+	words := make([]string, 450)
+	for i := range words {
+		words[i] = "word"
+	}
+	content := strings.Join(words, " ")
+
+	chunks := splitIntoChunks(content)
+	assert.Len(chunks, 3, "chunks should overlap")
+	assert.Equal(0, chunks[0].start)
+	assert.Equal(len(content), chunks[len(chunks)-1].end, "the last chunk should end with the content")
+	assert.Empty(splitIntoChunks("  \n "))
+}
+
+func TestVectorDBPersistence(t *testing.T) {
+	assert := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "search.index.vectors")
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	vectors, err := New(logger, path)
+	assert.NoError(err)
+	assert.False(vectors.Loaded(), "a new vector database should not be loaded from a file")
+
+	vectors.Index("/docs/payments.md", "Failed payments are retried by the billing worker.")
+	vectors.Index("/docs/cache.md", "The cache evicts the least recently used entries.")
+	vectors.Index("/docs/old.md", "Failed payments used to be retried by hand.")
+	vectors.Delete([]string{"/docs/old.md"})
+	assert.NoError(vectors.Close())
+
+	reopened, err := New(logger, path)
+	assert.NoError(err)
+	assert.True(reopened.Loaded(), "the vector database should be loaded from its file")
+
+	matches := reopened.Search("retrying failed payments", 10)
+	assert.NotEmpty(matches)
+	assert.Equal("/docs/payments.md", matches[0].DocID, "the most similar document should come first")
+	for _, match := range matches {
+		assert.NotEqual("/docs/old.md", match.DocID, "deleted documents should not be returned")
+	}
+
+	assert.NoError(reopened.Reset())
+	assert.Empty(reopened.Search("retrying failed payments", 10))
+	_, err = os.Stat(path)
+	assert.ErrorIs(err, os.ErrNotExist, "resetting should remove the file")
+}
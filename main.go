@@ -19,6 +19,14 @@ func main() {
 		os.Exit(1)
 	}
 
+	if len(os.Args) > 1 {
+		if err := runCommand(cfg, os.Args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	ctx := context.Background()
 	if err := api.Run(ctx, cfg); err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
@@ -0,0 +1,333 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/meghashyamc/wheresthat/config"
+	"github.com/meghashyamc/wheresthat/db/searchdb"
+	"github.com/meghashyamc/wheresthat/logger"
+	"github.com/meghashyamc/wheresthat/services/collection"
+	"github.com/meghashyamc/wheresthat/services/index"
+)
+
+// formatVersion must be incremented whenever the layout of backup archives changes
+const formatVersion = 1
+
+// Names of the entries of a backup archive. Collections other than the default one are kept within the
+// collections directory just like they are within the collections path.
+const (
+	manifestName    = "manifest.json"
+	metadataName    = "metadata.db"
+	indexName       = "search.index"
+	collectionsName = "collections"
+)
+
+var ErrBackupInProgress = errors.New("a backup is already in progress")
+
+// Manifest describes a backup archive, so that it can be checked before it is restored
+type Manifest struct {
+	FormatVersion int `json:"format_version"`
+	// SchemaVersion is the schema version of all indexes in the archive
+	SchemaVersion int       `json:"schema_version"`
+	CreatedAt     time.Time `json:"created_at"`
+	// Collections are the collections other than the default one in the archive
+	Collections []string `json:"collections"`
+	// Files are all entries of the archive other than the manifest
+	Files []File `json:"files"`
+}
+
+type File struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Backup is a backup archive that was written
+type Backup struct {
+	Path     string   `json:"path"`
+	Size     int64    `json:"size"`
+	Manifest Manifest `json:"manifest"`
+}
+
+// Paths are where the data that is backed up and restored is kept, and where backup archives are written to
+type Paths struct {
+	KVDB        string
+	Index       string
+	Collections string
+	Backups     string
+}
+
+// MetadataStore is a metadata store that can write a consistent copy of itself while it is in use
+type MetadataStore interface {
+	Snapshot(path string) error
+}
+
+type Service struct {
+	logger        logger.Logger
+	paths         Paths
+	metadataStore MetadataStore
+	base          *searchdb.BleveDB
+	collections   *collection.Service
+	// indexer indexes into the default collection, once indexing is started
+	indexer *index.Service
+	// mu is held while a backup is taken, so that only one is taken at a time
+	mu sync.Mutex
+}
+
+// PathsFromConfig returns the paths of the data that is backed up, as set in the config
+func PathsFromConfig(cfg *config.Config) Paths {
+	return Paths{
+		KVDB:        filepath.Join(cfg.GetStoragePath(), cfg.GetKVDBPath()),
+		Index:       filepath.Join(cfg.GetStoragePath(), cfg.GetIndexPath()),
+		Collections: filepath.Join(cfg.GetStoragePath(), cfg.GetCollectionsPath()),
+		Backups:     filepath.Join(cfg.GetStoragePath(), cfg.GetBackupsPath()),
+	}
+}
+
+// New creates a service backing up the metadata store along with the index of the default collection, which
+// is base, and those of all other collections
+func New(logger logger.Logger, paths Paths, metadataStore MetadataStore, base *searchdb.BleveDB, collections *collection.Service) *Service {
+	return &Service{
+		logger:        logger,
+		paths:         paths,
+		metadataStore: metadataStore,
+		base:          base,
+		collections:   collections,
+	}
+}
+
+// Start lets backups pause indexer, which indexes into the default collection, while they are taken
+func (s *Service) Start(indexer *index.Service) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.indexer = indexer
+}
+
+// Backup writes a backup archive with copies of all indexes and the metadata store to the backups path. Index
+// requests wait for the backup, or are refused while it is copying, so that the copies are consistent with each
+// other. Searches are served meanwhile.
+func (s *Service) Backup(ctx context.Context) (*Backup, error) {
+	if !s.mu.TryLock() {
+		return nil, ErrBackupInProgress
+	}
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.paths.Backups, 0755); err != nil {
+		s.logger.Error("failed to create backups directory", "path", s.paths.Backups, "err", err.Error())
+		return nil, fmt.Errorf("failed to create backups directory: %w", err)
+	}
+
+	// Copies are gathered next to the archive, which is written once indexing is resumed
+	stagingPath, err := os.MkdirTemp(s.paths.Backups, ".backup-*")
+	if err != nil {
+		s.logger.Error("failed to create backup staging directory", "err", err.Error())
+		return nil, fmt.Errorf("failed to create backup staging directory: %w", err)
+	}
+	defer os.RemoveAll(stagingPath)
+
+	createdAt := time.Now().UTC()
+	collections, err := s.snapshot(ctx, stagingPath)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := newManifest(stagingPath, createdAt, collections)
+	if err != nil {
+		s.logger.Error("failed to create backup manifest", "err", err.Error())
+		return nil, fmt.Errorf("failed to create backup manifest: %w", err)
+	}
+
+	archivePath := filepath.Join(s.paths.Backups, fmt.Sprintf("wheresthat-backup-%s.tar.gz", createdAt.Format("20060102T150405Z")))
+	size, err := writeArchive(archivePath, stagingPath, manifest)
+	if err != nil {
+		s.logger.Error("failed to write backup archive", "path", archivePath, "err", err.Error())
+		return nil, fmt.Errorf("failed to write backup archive: %w", err)
+	}
+
+	s.logger.Info("wrote backup", "path", archivePath, "size", size, "collections", len(collections), "duration", time.Since(createdAt).String())
+
+	return &Backup{Path: archivePath, Size: size, Manifest: *manifest}, nil
+}
+
+// snapshot pauses indexing into every collection and copies their indexes and the metadata store to
+// stagingPath. It returns the collections other than the default one that were copied.
+func (s *Service) snapshot(ctx context.Context, stagingPath string) ([]string, error) {
+	if s.indexer != nil {
+		if err := s.indexer.Pause(ctx); err != nil {
+			s.logger.Warn("failed to pause indexing for backup", "err", err.Error())
+			return nil, err
+		}
+		defer s.indexer.Resume()
+	}
+
+	dbs, resume, err := s.collections.Pause(ctx)
+	if err != nil {
+		s.logger.Warn("failed to pause indexing of collections for backup", "err", err.Error())
+		return nil, err
+	}
+	defer resume()
+
+	if err := s.base.Snapshot(filepath.Join(stagingPath, indexName)); err != nil {
+		return nil, fmt.Errorf("failed to copy index: %w", err)
+	}
+
+	collections := make([]string, 0, len(dbs))
+	for _, db := range dbs {
+		if err := db.Snapshot(searchdb.CollectionIndexPath(filepath.Join(stagingPath, collectionsName), db.Name())); err != nil {
+			return nil, fmt.Errorf("failed to copy index of collection %s: %w", db.Name(), err)
+		}
+		collections = append(collections, db.Name())
+	}
+	sort.Strings(collections)
+
+	if err := s.metadataStore.Snapshot(filepath.Join(stagingPath, metadataName)); err != nil {
+		return nil, fmt.Errorf("failed to copy metadata: %w", err)
+	}
+
+	return collections, nil
+}
+
+// newManifest describes the files in stagingPath
+func newManifest(stagingPath string, createdAt time.Time, collections []string) (*Manifest, error) {
+	manifest := &Manifest{
+		FormatVersion: formatVersion,
+		SchemaVersion: searchdb.CurrentSchemaVersion(),
+		CreatedAt:     createdAt,
+		Collections:   collections,
+	}
+
+	err := filepath.WalkDir(stagingPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		relPath, err := filepath.Rel(stagingPath, path)
+		if err != nil {
+			return err
+		}
+		file, err := describeFile(path)
+		if err != nil {
+			return err
+		}
+		file.Path = filepath.ToSlash(relPath)
+		manifest.Files = append(manifest.Files, file)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// describeFile returns the size and checksum of a file
+func describeFile(path string) (File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return File{}, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return File{}, err
+	}
+
+	return File{Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// writeArchive writes the manifest followed by the files it describes to a tar.gz archive, returning its size.
+// The archive is only put at archivePath once it is complete.
+func writeArchive(archivePath string, stagingPath string, manifest *Manifest) (int64, error) {
+	tempPath := archivePath + ".tmp"
+	file, err := os.OpenFile(tempPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tempPath)
+
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err = writeArchiveEntries(tarWriter, stagingPath, manifest)
+	if closeErr := tarWriter.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := gzipWriter.Close(); err == nil {
+		err = closeErr
+	}
+	if syncErr := file.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	info, err := os.Stat(tempPath)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tempPath, archivePath); err != nil {
+		return 0, err
+	}
+
+	return info.Size(), nil
+}
+
+func writeArchiveEntries(tarWriter *tar.Writer, stagingPath string, manifest *Manifest) error {
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{Name: manifestName, Mode: 0600, Size: int64(len(manifestBytes)), ModTime: manifest.CreatedAt}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err := tarWriter.Write(manifestBytes); err != nil {
+		return err
+	}
+
+	for _, manifestFile := range manifest.Files {
+		if err := writeArchiveFile(tarWriter, stagingPath, manifestFile, manifest.CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeArchiveFile(tarWriter *tar.Writer, stagingPath string, manifestFile File, modTime time.Time) error {
+	file, err := os.Open(filepath.Join(stagingPath, filepath.FromSlash(manifestFile.Path)))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header := &tar.Header{Name: manifestFile.Path, Mode: 0600, Size: manifestFile.Size, ModTime: modTime}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tarWriter, file)
+
+	return err
+}
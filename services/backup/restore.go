@@ -0,0 +1,269 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/meghashyamc/wheresthat/db/searchdb"
+	"github.com/meghashyamc/wheresthat/logger"
+)
+
+// Data that is replaced by a restore is kept at its path with this suffix until the restore is done
+const preRestorePathSuffix = ".pre-restore"
+
+var ErrInvalidBackup = errors.New("invalid backup archive")
+
+// replacement is a path that is replaced by one from a backup. If the backup has nothing at stagedPath, what is
+// at livePath is removed.
+type replacement struct {
+	stagedPath string
+	livePath   string
+	// movedAside and restored are set once what was at livePath is moved aside and once the staged data is
+	// moved into its place
+	movedAside bool
+	restored   bool
+}
+
+// Restore replaces the indexes and the metadata store with those in a backup archive written by Backup. The
+// archive is checked against its manifest, and its indexes must have been built with the current schema. The
+// server must not be running while a backup is restored.
+func Restore(logger logger.Logger, paths Paths, archivePath string) (*Manifest, error) {
+	if err := os.MkdirAll(filepath.Dir(paths.KVDB), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	// The archive is extracted next to the data it replaces, so that it can be moved into place
+	stagingPath, err := os.MkdirTemp(filepath.Dir(paths.KVDB), ".restore-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create restore staging directory: %w", err)
+	}
+	defer os.RemoveAll(stagingPath)
+
+	manifest, err := extractArchive(archivePath, stagingPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkManifest(manifest, stagingPath); err != nil {
+		return nil, err
+	}
+
+	replacements := []replacement{
+		{stagedPath: filepath.Join(stagingPath, metadataName), livePath: paths.KVDB},
+		{stagedPath: filepath.Join(stagingPath, indexName), livePath: paths.Index},
+		{stagedPath: searchdb.VectorsPath(filepath.Join(stagingPath, indexName)), livePath: searchdb.VectorsPath(paths.Index)},
+		{stagedPath: filepath.Join(stagingPath, collectionsName), livePath: paths.Collections},
+	}
+	if err := replace(logger, replacements); err != nil {
+		return nil, err
+	}
+
+	logger.Info("restored backup", "path", archivePath, "created_at", manifest.CreatedAt, "collections", len(manifest.Collections))
+
+	return manifest, nil
+}
+
+// extractArchive extracts a backup archive to stagingPath, returning its manifest
+func extractArchive(archivePath string, stagingPath string) (*Manifest, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup archive: %w", err)
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidBackup, err.Error())
+	}
+	defer gzipReader.Close()
+
+	var manifest *Manifest
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidBackup, err.Error())
+		}
+
+		// Entries must be files within the archive, so that extracting them never writes anywhere else
+		name := path.Clean(header.Name)
+		if header.Typeflag != tar.TypeReg || !fs.ValidPath(name) {
+			return nil, fmt.Errorf("%w: unexpected entry %s", ErrInvalidBackup, header.Name)
+		}
+
+		if name == manifestName {
+			manifest = &Manifest{}
+			if err := json.NewDecoder(tarReader).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("%w: could not read manifest: %s", ErrInvalidBackup, err.Error())
+			}
+			continue
+		}
+
+		if err := extractFile(tarReader, filepath.Join(stagingPath, filepath.FromSlash(name))); err != nil {
+			return nil, fmt.Errorf("failed to extract %s: %w", name, err)
+		}
+	}
+
+	if manifest == nil {
+		return nil, fmt.Errorf("%w: no manifest", ErrInvalidBackup)
+	}
+
+	return manifest, nil
+}
+
+func extractFile(reader io.Reader, filePath string) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, reader); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// checkManifest checks that the extracted files are exactly those described by the manifest, and that the
+// indexes among them can be used with the current schema
+func checkManifest(manifest *Manifest, stagingPath string) error {
+	if manifest.FormatVersion != formatVersion {
+		return fmt.Errorf("%w: format version %d is not supported", ErrInvalidBackup, manifest.FormatVersion)
+	}
+	if manifest.SchemaVersion != searchdb.CurrentSchemaVersion() {
+		return fmt.Errorf("%w: backup schema version %d, expected %d", searchdb.ErrSchemaVersionMismatch, manifest.SchemaVersion, searchdb.CurrentSchemaVersion())
+	}
+
+	described := map[string]File{}
+	for _, file := range manifest.Files {
+		described[file.Path] = file
+	}
+
+	extracted := 0
+	err := filepath.WalkDir(stagingPath, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		relPath, err := filepath.Rel(stagingPath, filePath)
+		if err != nil {
+			return err
+		}
+		expected, ok := described[filepath.ToSlash(relPath)]
+		if !ok {
+			return fmt.Errorf("%w: %s is not in the manifest", ErrInvalidBackup, relPath)
+		}
+		actual, err := describeFile(filePath)
+		if err != nil {
+			return err
+		}
+		if actual.Size != expected.Size || actual.SHA256 != expected.SHA256 {
+			return fmt.Errorf("%w: %s does not match the manifest", ErrInvalidBackup, relPath)
+		}
+		extracted++
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if extracted != len(described) {
+		return fmt.Errorf("%w: %d files in the manifest are missing", ErrInvalidBackup, len(described)-extracted)
+	}
+
+	if _, err := os.Stat(filepath.Join(stagingPath, metadataName)); err != nil {
+		return fmt.Errorf("%w: no metadata", ErrInvalidBackup)
+	}
+
+	indexPaths := []string{filepath.Join(stagingPath, indexName)}
+	for _, name := range manifest.Collections {
+		if !fs.ValidPath(name) || strings.Contains(name, "/") {
+			return fmt.Errorf("%w: invalid collection name %s", ErrInvalidBackup, name)
+		}
+		indexPaths = append(indexPaths, searchdb.CollectionIndexPath(filepath.Join(stagingPath, collectionsName), name))
+	}
+	for _, indexPath := range indexPaths {
+		if err := searchdb.CheckSnapshot(indexPath); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+		}
+	}
+
+	return nil
+}
+
+// replace moves the data at each live path aside and the staged data into its place. If any of it can not be
+// replaced, what was replaced so far is put back. The data that was moved aside is removed once all of it is
+// replaced.
+func replace(logger logger.Logger, replacements []replacement) error {
+	for i := range replacements {
+		if err := replacements[i].replace(); err != nil {
+			logger.Error("failed to restore backup, putting back replaced data", "path", replacements[i].livePath, "err", err.Error())
+			for j := i; j >= 0; j-- {
+				if err := replacements[j].putBack(); err != nil {
+					logger.Error("failed to put back replaced data", "path", replacements[j].livePath, "err", err.Error())
+				}
+			}
+			return fmt.Errorf("failed to restore %s: %w", replacements[i].livePath, err)
+		}
+	}
+
+	for _, r := range replacements {
+		if err := os.RemoveAll(r.livePath + preRestorePathSuffix); err != nil {
+			logger.Warn("could not remove replaced data", "path", r.livePath+preRestorePathSuffix, "err", err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (r *replacement) replace() error {
+	asidePath := r.livePath + preRestorePathSuffix
+	if err := os.RemoveAll(asidePath); err != nil {
+		return err
+	}
+	err := os.Rename(r.livePath, asidePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	r.movedAside = err == nil
+
+	if err := os.MkdirAll(filepath.Dir(r.livePath), 0755); err != nil {
+		return err
+	}
+	err = os.Rename(r.stagedPath, r.livePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	r.restored = err == nil
+
+	return nil
+}
+
+// putBack undoes replace, as far as it got
+func (r *replacement) putBack() error {
+	if r.restored {
+		if err := os.RemoveAll(r.livePath); err != nil {
+			return err
+		}
+	}
+	if r.movedAside {
+		return os.Rename(r.livePath+preRestorePathSuffix, r.livePath)
+	}
+
+	return nil
+}
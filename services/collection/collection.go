@@ -0,0 +1,485 @@
+package collection
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/meghashyamc/wheresthat/db/kvdb"
+	"github.com/meghashyamc/wheresthat/db/searchdb"
+	"github.com/meghashyamc/wheresthat/logger"
+	"github.com/meghashyamc/wheresthat/services/index"
+	"github.com/meghashyamc/wheresthat/services/search"
+)
+
+var (
+	ErrNotFound           = errors.New("collection not found")
+	ErrAlreadyExists      = errors.New("a collection with this name already exists")
+	ErrPathNotInRoots     = errors.New("path is not under any of the roots of the collection")
+	ErrIndexingInProgress = errors.New("collection is being indexed")
+)
+
+// Collection is a named index of its own, with the roots that may be indexed into it and how its documents
+// are analyzed and ranked
+type Collection struct {
+	Name string `json:"name"`
+	// Roots are the directories that may be indexed into the collection, or empty if any directory may be
+	Roots []string `json:"roots"`
+	// Analyzer is searchdb.CollectionAnalyzerStandard or searchdb.CollectionAnalyzerCode
+	Analyzer string `json:"analyzer"`
+	// RankingProfile is the ranking profile used when a search does not name one, or empty for the default one
+	RankingProfile string    `json:"ranking_profile,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// openCollection is a collection along with its open index and the service indexing into it
+type openCollection struct {
+	Collection
+	db      *searchdb.BleveDB
+	indexer *index.Service
+	// stopIndexer stops the index service of the collection
+	stopIndexer context.CancelFunc
+}
+
+type Service struct {
+	logger        logger.Logger
+	base          *searchdb.BleveDB
+	metadataStore index.MetadataStore
+	// ctx and listeners are what index services of collections are created with, once indexing is started
+	ctx       context.Context
+	listeners []index.Listener
+	// mu guards collections, and is held while collections are searched or indexed so that they are not
+	// deleted meanwhile
+	mu          sync.RWMutex
+	collections map[string]*openCollection
+}
+
+// New opens the index of every collection other than the default one, which is base. The collections can
+// be searched right away, but are only indexed into once Start is called.
+func New(logger logger.Logger, base *searchdb.BleveDB, metadataStore index.MetadataStore) (*Service, error) {
+	s := &Service{
+		logger:        logger,
+		base:          base,
+		metadataStore: metadataStore,
+		collections:   map[string]*openCollection{},
+	}
+
+	names, err := metadataStore.GetAllKeys(kvdb.CollectionsBucket)
+	if err != nil {
+		logger.Error("failed to get collections", "err", err.Error())
+		return nil, fmt.Errorf("failed to get collections: %w", err)
+	}
+
+	for _, name := range names {
+		collection, err := s.get(name)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+
+		db, err := base.OpenCollection(name, settings(*collection))
+		if err != nil {
+			logger.Error("failed to open collection", "collection", name, "err", err.Error())
+			s.Close()
+			return nil, fmt.Errorf("failed to open collection %s: %w", name, err)
+		}
+		if err := metadataStore.CreateBucket(filesBucket(name)); err != nil {
+			db.Close()
+			s.Close()
+			return nil, fmt.Errorf("failed to open collection %s: %w", name, err)
+		}
+		s.collections[name] = &openCollection{Collection: *collection, db: db}
+	}
+
+	return s, nil
+}
+
+// Start starts indexing into collections, telling listeners about the outcome of every index request. Index
+// services stop once ctx is done.
+func (s *Service) Start(ctx context.Context, listeners ...index.Listener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ctx = ctx
+	s.listeners = listeners
+	for _, collection := range s.collections {
+		s.startIndexer(collection)
+	}
+}
+
+// Searcher returns a searcher that searches the collections named by search params, and otherwise the
+// default collection
+func (s *Service) Searcher() search.Searcher {
+	return &searcher{Searcher: s.base, service: s}
+}
+
+// List returns all collections other than the default one, ordered by name
+func (s *Service) List() []Collection {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	collections := make([]Collection, 0, len(s.collections))
+	for _, collection := range s.collections {
+		collections = append(collections, collection.Collection)
+	}
+	slices.SortFunc(collections, func(a, b Collection) int { return strings.Compare(a.Name, b.Name) })
+
+	return collections
+}
+
+// Get returns a collection other than the default one
+func (s *Service) Get(name string) (*Collection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	collection, ok := s.collections[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+
+	found := collection.Collection
+	return &found, nil
+}
+
+// DB returns the index of a collection, including the default one
+func (s *Service) DB(name string) (*searchdb.BleveDB, error) {
+	if name == searchdb.DefaultCollection {
+		return s.base, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	collection, ok := s.collections[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+
+	return collection.db, nil
+}
+
+// Create creates a collection along with its empty index
+func (s *Service) Create(collection Collection) (*Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.collections[collection.Name]; ok || collection.Name == searchdb.DefaultCollection {
+		return nil, fmt.Errorf("%w: %s", ErrAlreadyExists, collection.Name)
+	}
+
+	if len(collection.Analyzer) == 0 {
+		collection.Analyzer = searchdb.CollectionAnalyzerStandard
+	}
+	collection.Roots = cleanRoots(collection.Roots)
+	collection.CreatedAt = time.Now().UTC()
+
+	db, err := s.base.OpenCollection(collection.Name, settings(collection))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.metadataStore.CreateBucket(filesBucket(collection.Name)); err != nil {
+		db.Destroy()
+		return nil, fmt.Errorf("failed to create collection: %w", err)
+	}
+
+	if err := s.set(&collection); err != nil {
+		db.Destroy()
+		s.metadataStore.DeleteBucket(filesBucket(collection.Name))
+		return nil, err
+	}
+
+	created := &openCollection{Collection: collection, db: db}
+	s.collections[collection.Name] = created
+	s.startIndexer(created)
+
+	s.logger.Info("created collection", "collection", collection.Name, "roots", collection.Roots, "analyzer", collection.Analyzer)
+
+	return &collection, nil
+}
+
+// Update changes the roots and ranking profile of a collection. The analyzer of a collection can not be
+// changed, since its documents would have to be indexed again.
+func (s *Service) Update(name string, roots []string, rankingProfile string) (*Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	collection, ok := s.collections[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+
+	previousRankingProfile := collection.db.DefaultRankingProfile()
+	defaultRankingProfile := rankingProfile
+	if len(defaultRankingProfile) == 0 {
+		defaultRankingProfile = s.base.DefaultRankingProfile()
+	}
+	if err := collection.db.SetDefaultRankingProfile(defaultRankingProfile); err != nil {
+		return nil, err
+	}
+
+	updated := collection.Collection
+	updated.Roots = cleanRoots(roots)
+	updated.RankingProfile = rankingProfile
+	if err := s.set(&updated); err != nil {
+		collection.db.SetDefaultRankingProfile(previousRankingProfile)
+		return nil, err
+	}
+	collection.Collection = updated
+
+	return &updated, nil
+}
+
+// Delete removes a collection along with its index and the metadata of its indexed files. Collections that
+// are being indexed can not be deleted.
+func (s *Service) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	collection, ok := s.collections[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if collection.indexer != nil && collection.indexer.IsInProgress() {
+		return fmt.Errorf("%w: %s", ErrIndexingInProgress, name)
+	}
+
+	if err := s.metadataStore.Delete(kvdb.CollectionsBucket, name); err != nil {
+		s.logger.Error("failed to delete collection", "collection", name, "err", err.Error())
+		return fmt.Errorf("failed to delete collection: %w", err)
+	}
+	delete(s.collections, name)
+
+	if collection.stopIndexer != nil {
+		collection.stopIndexer()
+	}
+
+	// The collection is gone once it is deleted from the store, so what is left behind is only logged
+	if err := collection.db.Destroy(); err != nil {
+		s.logger.Warn("could not remove index of deleted collection", "collection", name, "err", err.Error())
+	}
+	if err := s.metadataStore.DeleteBucket(filesBucket(name)); err != nil {
+		s.logger.Warn("could not remove indexed files of deleted collection", "collection", name, "err", err.Error())
+	}
+	if err := s.metadataStore.Delete(kvdb.BoltDefaultBucket, kvdb.SchemaVersionKey(name)); err != nil {
+		s.logger.Warn("could not remove schema version of deleted collection", "collection", name, "err", err.Error())
+	}
+	if err := s.metadataStore.Delete(kvdb.StatsBucket, name); err != nil {
+		s.logger.Warn("could not remove stats of deleted collection", "collection", name, "err", err.Error())
+	}
+
+	s.logger.Info("deleted collection", "collection", name)
+
+	return nil
+}
+
+// Index indexes a directory into a collection, which must be under one of its roots if it has any. The
+// status of the request is kept along with that of other index requests.
+func (s *Service) Index(name string, rootPath string, excludeFolders []string, requestID string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	collection, ok := s.collections[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if !isUnderRoots(rootPath, collection.Roots) {
+		return fmt.Errorf("%w: %s", ErrPathNotInRoots, rootPath)
+	}
+	if collection.indexer == nil {
+		return errors.New("indexing has not been started")
+	}
+
+	return collection.indexer.Build(rootPath, excludeFolders, requestID)
+}
+
+// Search searches the collections named by params, or the default collection if there are none. Results of
+// keyword searches across several collections are merged by score.
+func (s *Service) Search(params searchdb.SearchParams) (*searchdb.Response, error) {
+	if len(params.Collections) == 0 {
+		return s.base.Search(params)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var dbs []*searchdb.BleveDB
+	for _, name := range params.Collections {
+		db := s.base
+		if name != searchdb.DefaultCollection {
+			collection, ok := s.collections[name]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+			}
+			db = collection.db
+		}
+		if !slices.Contains(dbs, db) {
+			dbs = append(dbs, db)
+		}
+	}
+
+	return searchdb.SearchCollections(dbs, params)
+}
+
+// Pause waits for index requests in progress in every collection other than the default one to be done, and
+// refuses index requests until resume is called. Collections can not be created, updated or deleted meanwhile.
+// It returns the indexes of the collections, which are not written to until resume is called.
+func (s *Service) Pause(ctx context.Context) (dbs []*searchdb.BleveDB, resume func(), err error) {
+	s.mu.RLock()
+
+	var paused []*index.Service
+	resume = func() {
+		for _, indexer := range paused {
+			indexer.Resume()
+		}
+		s.mu.RUnlock()
+	}
+
+	for _, collection := range s.collections {
+		if collection.indexer != nil {
+			if err := collection.indexer.Pause(ctx); err != nil {
+				resume()
+				return nil, nil, fmt.Errorf("failed to pause collection %s: %w", collection.Name, err)
+			}
+			paused = append(paused, collection.indexer)
+		}
+		dbs = append(dbs, collection.db)
+	}
+
+	return dbs, resume, nil
+}
+
+// Check checks the index of every collection other than the default one against the metadata of its indexed
+// files and the file system, repairing what it finds if repair is true. Reports are ordered by collection name.
+// If indexing was not started, collections are checked without starting it, so outdated indexes are not rebuilt.
+func (s *Service) Check(ctx context.Context, repair bool) ([]*index.CheckReport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.collections))
+	for name := range s.collections {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var reports []*index.CheckReport
+	for _, name := range names {
+		collection := s.collections[name]
+		indexer := collection.indexer
+		if indexer == nil {
+			indexer = index.NewChecker(s.logger, name, filesBucket(name), collection.db, s.metadataStore)
+		}
+
+		report, err := indexer.Check(ctx, repair)
+		if err != nil {
+			return reports, fmt.Errorf("failed to check collection %s: %w", name, err)
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+// Close closes the indexes of all collections other than the default one
+func (s *Service) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for _, collection := range s.collections {
+		if collection.stopIndexer != nil {
+			collection.stopIndexer()
+		}
+		if err := collection.db.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// startIndexer creates the index service of a collection, if indexing was started
+func (s *Service) startIndexer(collection *openCollection) {
+	if s.ctx == nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	collection.indexer = index.NewForCollection(ctx, s.logger, collection.Name, filesBucket(collection.Name), collection.db, s.metadataStore, s.listeners...)
+	collection.stopIndexer = cancel
+}
+
+func (s *Service) get(name string) (*Collection, error) {
+	value, err := s.metadataStore.Get(kvdb.CollectionsBucket, name)
+	var notFoundErr *kvdb.NotFoundError
+	if errors.As(err, &notFoundErr) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection: %w", err)
+	}
+
+	var collection Collection
+	if err := json.Unmarshal([]byte(value), &collection); err != nil {
+		s.logger.Error("failed to unmarshal collection", "collection", name, "err", err.Error())
+		return nil, fmt.Errorf("failed to unmarshal collection %s: %w", name, err)
+	}
+
+	return &collection, nil
+}
+
+func (s *Service) set(collection *Collection) error {
+	data, err := json.Marshal(collection)
+	if err != nil {
+		s.logger.Error("failed to marshal collection", "collection", collection.Name, "err", err.Error())
+		return fmt.Errorf("failed to marshal collection %s: %w", collection.Name, err)
+	}
+
+	if err := s.metadataStore.Set(kvdb.CollectionsBucket, collection.Name, string(data)); err != nil {
+		s.logger.Error("failed to save collection", "collection", collection.Name, "err", err.Error())
+		return fmt.Errorf("failed to save collection: %w", err)
+	}
+
+	return nil
+}
+
+// settings returns how the index of a collection analyzes and ranks its documents
+func settings(collection Collection) searchdb.CollectionSettings {
+	return searchdb.CollectionSettings{Analyzer: collection.Analyzer, RankingProfile: collection.RankingProfile}
+}
+
+// filesBucket returns the bucket the metadata of files indexed into a collection is kept in
+func filesBucket(name string) string {
+	return kvdb.FilesBucket + "_" + name
+}
+
+func cleanRoots(roots []string) []string {
+	cleaned := make([]string, 0, len(roots))
+	for _, root := range roots {
+		cleaned = append(cleaned, filepath.Clean(root))
+	}
+
+	return cleaned
+}
+
+// isUnderRoots returns true if a path is one of the roots or within one of them, or if there are no roots
+func isUnderRoots(path string, roots []string) bool {
+	if len(roots) == 0 {
+		return true
+	}
+
+	path = filepath.Clean(path)
+	for _, root := range roots {
+		if relativePath, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(relativePath, "..") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,16 @@
+package collection
+
+import (
+	"github.com/meghashyamc/wheresthat/db/searchdb"
+	"github.com/meghashyamc/wheresthat/services/search"
+)
+
+// searcher is the searcher of the default collection, except that searches naming collections search them
+type searcher struct {
+	search.Searcher
+	service *Service
+}
+
+func (s *searcher) Search(params searchdb.SearchParams) (*searchdb.Response, error) {
+	return s.service.Search(params)
+}
@@ -0,0 +1,214 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/meghashyamc/wheresthat/db/kvdb"
+	"github.com/meghashyamc/wheresthat/db/searchdb"
+)
+
+// CheckReport is what a consistency check of an index against the metadata of its indexed files and the file
+// system found, and what was done about it
+type CheckReport struct {
+	Collection string
+	// Documents and Entries are the number of documents in the index and of files in the metadata
+	Documents int
+	Entries   int
+	// Orphans are documents in the index whose files are not in the metadata
+	Orphans []string
+	// Missing are files in the metadata that still exist but are not in the index
+	Missing []string
+	// Stale are files in the metadata that no longer exist
+	Stale []string
+	// Reindexed and Removed are the files that were indexed again and those that were removed from the index
+	// and the metadata, if the check repaired what it found
+	Reindexed []string
+	Removed   []string
+	// Unrepaired are files that could not be repaired
+	Unrepaired []string
+	// Outdated is true if the index was built with an older schema, in which case it is rebuilt the next time
+	// the server starts
+	Outdated bool
+}
+
+// Consistent returns true if the check found nothing to repair
+func (r *CheckReport) Consistent() bool {
+	return len(r.Orphans) == 0 && len(r.Missing) == 0 && len(r.Stale) == 0
+}
+
+// Check compares the documents in the index with the metadata of indexed files and with the file system,
+// waiting for any index request in progress to be done first. If repair is true, files that still exist are
+// indexed again and files that no longer exist are removed from both. Missing files are indexed again under
+// the root of an indexed file above them, and are otherwise removed from the metadata so that the next index
+// request of their root indexes them.
+func (s *Service) Check(ctx context.Context, repair bool) (*CheckReport, error) {
+	if err := s.Pause(ctx); err != nil {
+		return nil, err
+	}
+	defer s.Resume()
+
+	indexedFiles, err := s.indexer.IndexedFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexed files: %w", err)
+	}
+	entries, err := s.metadataStore.GetAllKeys(s.filesBucket)
+	if err != nil {
+		s.logger.Error("failed to get all keys from database", "err", err.Error())
+		return nil, fmt.Errorf("failed to get all keys from database: %w", err)
+	}
+
+	report := &CheckReport{Collection: s.collection, Documents: len(indexedFiles), Entries: len(entries), Outdated: s.indexer.IsOutdated()}
+
+	roots := map[string]string{}
+	var knownRoots []string
+	for _, file := range indexedFiles {
+		roots[file.Path] = file.Root
+		if len(file.Root) > 0 && !slices.Contains(knownRoots, file.Root) {
+			knownRoots = append(knownRoots, file.Root)
+		}
+	}
+	inMetadata := make(map[string]struct{}, len(entries))
+	for _, path := range entries {
+		inMetadata[path] = struct{}{}
+	}
+
+	var toReindex []searchdb.IndexedFile
+	var toRemove []string
+	for _, file := range indexedFiles {
+		if _, ok := inMetadata[file.Path]; ok {
+			continue
+		}
+		report.Orphans = append(report.Orphans, file.Path)
+		if fileExists(file.Path) {
+			toReindex = append(toReindex, file)
+		} else {
+			toRemove = append(toRemove, file.Path)
+		}
+	}
+	for _, path := range entries {
+		_, indexed := roots[path]
+		switch {
+		case !fileExists(path):
+			report.Stale = append(report.Stale, path)
+			toRemove = append(toRemove, path)
+		case !indexed:
+			report.Missing = append(report.Missing, path)
+			if root, ok := rootOf(path, knownRoots); ok {
+				toReindex = append(toReindex, searchdb.IndexedFile{Path: path, Root: root})
+			} else {
+				toRemove = append(toRemove, path)
+			}
+		}
+	}
+
+	s.logger.Info("checked index", "collection", s.collection, "documents", report.Documents, "entries", report.Entries, "orphans", len(report.Orphans), "missing", len(report.Missing), "stale", len(report.Stale), "outdated", report.Outdated)
+
+	if !repair || report.Consistent() {
+		return report, nil
+	}
+
+	if err := s.removeFiles(toRemove, report); err != nil {
+		return report, err
+	}
+	if err := s.reindexFiles(ctx, toReindex, report); err != nil {
+		return report, err
+	}
+	if err := s.indexer.Flush(); err != nil {
+		s.logger.Error("failed to save search index", "err", err.Error())
+		return report, fmt.Errorf("failed to save search index: %w", err)
+	}
+
+	s.logger.Info("repaired index", "collection", s.collection, "reindexed", len(report.Reindexed), "removed", len(report.Removed), "unrepaired", len(report.Unrepaired))
+
+	return report, nil
+}
+
+// removeFiles removes files from both the index and the metadata, whether they are in either or not
+func (s *Service) removeFiles(paths []string, report *CheckReport) error {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	if err := s.indexer.DeleteDocuments(paths); err != nil {
+		s.logger.Error("failed to delete documents from search index", "err", err.Error())
+		return fmt.Errorf("failed to delete documents from search index: %w", err)
+	}
+
+	if err := s.metadataStore.DeleteMany(s.filesBucket, paths); err != nil {
+		s.logger.Error("failed to delete file metadata", "err", err.Error())
+		report.Unrepaired = append(report.Unrepaired, paths...)
+		return nil
+	}
+	report.Removed = append(report.Removed, paths...)
+
+	return nil
+}
+
+// reindexFiles indexes files again in batches, recording them in the metadata once each batch is indexed
+func (s *Service) reindexFiles(ctx context.Context, files []searchdb.IndexedFile, report *CheckReport) error {
+	indexTime := time.Now().UTC()
+
+	for i := 0; i < len(files); i += searchdb.IndexingBatchSize {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var filesInBatch []FileInfo
+		for _, file := range files[i:min(i+searchdb.IndexingBatchSize, len(files))] {
+			fileInfo, err := newFileInfo(file.Path, file.Root)
+			if err != nil {
+				s.logger.Warn("could not index file again", "path", file.Path, "err", err.Error())
+				report.Unrepaired = append(report.Unrepaired, file.Path)
+				continue
+			}
+			filesInBatch = append(filesInBatch, fileInfo)
+		}
+
+		documents, processedFiles := s.extractDocuments(filesInBatch, 0)
+		for _, file := range filesInBatch {
+			if !slices.ContainsFunc(processedFiles, func(processed FileInfo) bool { return processed.Path == file.Path }) {
+				report.Unrepaired = append(report.Unrepaired, file.Path)
+			}
+		}
+		if err := s.indexer.BuildIndex(documents); err != nil {
+			s.logger.Error("failed to index files again", "err", err.Error())
+			return fmt.Errorf("failed to index files again: %w", err)
+		}
+
+		paths := make([]string, 0, len(processedFiles))
+		for _, file := range processedFiles {
+			paths = append(paths, file.Path)
+		}
+		if err := s.setFilesMetadata(paths, kvdb.FileMetadata{LastIndexed: indexTime}); err != nil {
+			report.Unrepaired = append(report.Unrepaired, paths...)
+			continue
+		}
+		report.Reindexed = append(report.Reindexed, paths...)
+	}
+
+	return nil
+}
+
+// rootOf returns the longest of roots that path is under
+func rootOf(path string, roots []string) (string, bool) {
+	var found string
+	for _, root := range roots {
+		if (path == root || strings.HasPrefix(path, root+string(filepath.Separator))) && len(root) > len(found) {
+			found = root
+		}
+	}
+
+	return found, len(found) > 0
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return !errors.Is(err, os.ErrNotExist)
+}
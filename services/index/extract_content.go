@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/meghashyamc/wheresthat/db/searchdb"
@@ -24,6 +26,14 @@ func extractContent(fileInfo FileInfo) (*searchdb.Document, error) {
 		Name:    fileInfo.Name,
 		Size:    fileInfo.Size,
 		ModTime: fileInfo.ModTime,
+		Type:    searchdb.DocumentTypeText,
+		Root:    fileInfo.Root,
+		Ext:     strings.ToLower(filepath.Ext(fileInfo.Name)),
+		Dir:     filepath.Dir(fileInfo.Path),
+	}
+
+	if fileInfo.IsSourceCode {
+		doc.Type = searchdb.DocumentTypeCode
 	}
 
 	if fileInfo.IsText {
@@ -38,6 +48,11 @@ func extractContent(fileInfo FileInfo) (*searchdb.Document, error) {
 			return nil, err
 		}
 		doc.Content = string(content)
+
+		// Code is mostly keywords and identifiers, so only the language of other text is detected
+		if doc.Type == searchdb.DocumentTypeText {
+			doc.Lang = searchdb.DetectLanguage(doc.Content)
+		}
 	}
 
 	return doc, nil
@@ -2,6 +2,7 @@ package index
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -16,15 +17,48 @@ type FileInfo struct {
 	Size    int64
 	ModTime time.Time
 	IsText  bool
+	// IsSourceCode is true for files whose identifiers should be split into words when indexed
+	IsSourceCode bool
+	// Root is the directory whose indexing found the file
+	Root string
+}
+
+// newFileInfo returns the details of a file that was found under a root
+func newFileInfo(path string, root string) (FileInfo, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	fileInfo := FileInfo{
+		Path:         path,
+		Name:         info.Name(),
+		Size:         info.Size(),
+		ModTime:      info.ModTime(),
+		IsText:       isTextFile(path),
+		IsSourceCode: isSourceCodeFile(path),
+	}
+	if len(root) > 0 {
+		fileInfo.Root = filepath.Clean(root)
+	}
+
+	return fileInfo, nil
 }
 
 func (s *Service) discoverModifiedFiles(rootPath string, excludeFolders []string) ([]FileInfo, error) {
+	// Metadata of indexed files is read all at once rather than file by file as they are walked
+	filesMetadata, err := s.getFilesMetadata()
+	if err != nil {
+		s.logger.Error("failed to get metadata of indexed files", "err", err.Error())
+		return nil, fmt.Errorf("failed to get metadata of indexed files: %w", err)
+	}
+
 	var modifiedFiles []FileInfo
 	excludeSet := make(map[string]struct{}, len(excludeFolders))
 	for _, folder := range excludeFolders {
 		excludeSet[folder] = struct{}{}
 	}
-	err := filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
+	err = filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			s.logger.Error("could not walk through file or directory", "err", err.Error())
 			if !errors.Is(err, os.ErrPermission) {
@@ -49,15 +83,17 @@ func (s *Service) discoverModifiedFiles(rootPath string, excludeFolders []string
 
 		fileModTime := info.ModTime()
 
-		if s.shouldFileBeIndexed(path, fileModTime) {
+		if shouldFileBeIndexed(filesMetadata, path, fileModTime) {
 			fileInfo := FileInfo{
 				Path:    path,
 				Name:    info.Name(),
 				Size:    info.Size(),
 				ModTime: fileModTime,
+				Root:    filepath.Clean(rootPath),
 			}
 
 			fileInfo.IsText = isTextFile(path)
+			fileInfo.IsSourceCode = isSourceCodeFile(path)
 			modifiedFiles = append(modifiedFiles, fileInfo)
 		}
 
@@ -67,36 +103,14 @@ func (s *Service) discoverModifiedFiles(rootPath string, excludeFolders []string
 	return modifiedFiles, err
 }
 
-func (s *Service) shouldFileBeIndexed(path string, fileModTime time.Time) bool {
-
-	// Check if this file was indexed before
-	metadata, err := s.getFileMetadata(path)
-	if err != nil {
-		var notFoundErr *kvdb.NotFoundError
-		var invalidKeyErr *kvdb.InvalidKeyError
-
-		switch {
-		// File not found in database, should be indexed
-		case errors.As(err, &notFoundErr):
-			return true
-			// Invalid key, log error and index
-		case errors.As(err, &invalidKeyErr):
-			s.logger.Error("invalid key for file path", "key", path, "err", err.Error())
-			return true
-		// Unknown error, log error and index
-		default:
-			s.logger.Error("failed to get metadata", "path", path, "err", err.Error())
-			return true
-		}
-	}
-
-	// File was indexed before, check if it was modified since
-	if fileModTime.After(metadata.LastIndexed) {
+// shouldFileBeIndexed returns true if a file was not indexed before, or was modified since it was
+func shouldFileBeIndexed(filesMetadata map[string]kvdb.FileMetadata, path string, fileModTime time.Time) bool {
+	metadata, ok := filesMetadata[path]
+	if !ok {
 		return true
 	}
 
-	// File is already indexed and has not been modified after indexing
-	return false
+	return fileModTime.After(metadata.LastIndexed)
 }
 
 func isTextFile(path string) bool {
@@ -113,6 +127,20 @@ func isTextFile(path string) bool {
 	return textExtensions[ext]
 }
 
+func isSourceCodeFile(path string) bool {
+	sourceCodeExtensions := map[string]bool{
+		".go": true, ".js": true, ".jsx": true, ".ts": true,
+		".tsx": true, ".py": true, ".java": true, ".kt": true,
+		".scala": true, ".c": true, ".h": true, ".cpp": true,
+		".cc": true, ".hpp": true, ".cs": true, ".rs": true,
+		".rb": true, ".php": true, ".swift": true, ".sh": true,
+		".sql": true, ".css": true, ".html": true,
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	return sourceCodeExtensions[ext]
+}
+
 // Assumes current path and root path are clean
 func isInExcludedPath(currentPath string, excludeSet map[string]struct{}) bool {
 
@@ -9,6 +9,7 @@ import (
 	"os"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/meghashyamc/wheresthat/db/kvdb"
@@ -20,9 +21,39 @@ import (
 type Indexer interface {
 	BuildIndex(documents []*searchdb.Document) error
 	DeleteDocuments(documentIDs []string) error
+	IsOutdated() bool
+	Recreate() error
+	SchemaVersion() int
+	GetDocCount() (uint64, error)
+	// IndexedFiles and StartRebuild are used to rebuild an index with the current schema from the files in it
+	IndexedFiles() ([]searchdb.IndexedFile, error)
+	StartRebuild() (*searchdb.Rebuild, error)
+	// Flush saves index data kept in memory, once an indexing request is done
+	Flush() error
 	Close() error
 }
 
+// Listener is told about the outcome of every index request, before its final status is set
+type Listener interface {
+	IndexDone(result JobResult)
+}
+
+// JobResult is the outcome of an index request
+type JobResult struct {
+	RequestID string
+	// Collection is the name of the collection that was indexed
+	Collection string
+	RootPath   string
+	// Status is ProgressStatusComplete or ProgressStatusFailed
+	Status int
+	// FilesIndexed is the number of new or modified files that were indexed
+	FilesIndexed int
+	// FilesDeleted is the number of deleted files that were removed from the index
+	FilesDeleted int
+	StartedAt    time.Time
+	FinishedAt   time.Time
+}
+
 const (
 	ProgressStatusStep1    = 10
 	ProgressStatusStep2    = 20
@@ -31,13 +62,21 @@ const (
 
 	maxGoRoutinesForFileProcessing = 50
 	maxIndexBuildingTime           = 2 * time.Hour
+	// pausePollInterval is how often Pause checks whether the index request in progress is done
+	pausePollInterval = 100 * time.Millisecond
 )
 
 type Service struct {
-	logger        logger.Logger
+	logger logger.Logger
+	// collection is the name of the collection indexed into, whose indexed files are kept in filesBucket
+	collection    string
+	filesBucket   string
 	indexer       Indexer
 	metadataStore MetadataStore
 	buildIndexC   chan indexRequest
+	listeners     []Listener
+	// inProgress is true from the time an index request is accepted till its final status is known
+	inProgress atomic.Bool
 }
 
 type indexRequest struct {
@@ -46,57 +85,117 @@ type indexRequest struct {
 	requestID      string
 }
 
-func New(ctx context.Context, logger logger.Logger, indexer Indexer, metadataStore MetadataStore) *Service {
+func New(ctx context.Context, logger logger.Logger, indexer Indexer, metadataStore MetadataStore, listeners ...Listener) *Service {
+	return NewForCollection(ctx, logger, searchdb.DefaultCollection, kvdb.FilesBucket, indexer, metadataStore, listeners...)
+}
+
+// NewForCollection creates a service indexing into a collection, which keeps the metadata of its indexed files
+// in its own bucket. The service stops once ctx is done.
+func NewForCollection(ctx context.Context, logger logger.Logger, collection string, filesBucket string, indexer Indexer, metadataStore MetadataStore, listeners ...Listener) *Service {
 	indexService := &Service{
 		logger:        logger,
+		collection:    collection,
+		filesBucket:   filesBucket,
 		indexer:       indexer,
 		metadataStore: metadataStore,
-		buildIndexC:   make(chan indexRequest),
+		buildIndexC:   make(chan indexRequest, 1),
+		listeners:     listeners,
 	}
 
-	go indexService.build(ctx)
+	rebuild := indexService.checkSchemaVersion()
+	go indexService.build(ctx, rebuild)
 	return indexService
 }
 
+// NewChecker creates a service that only checks the index of a collection, for when no index service is running.
+// It does not work on index requests, and unlike NewForCollection it leaves an index built with an older schema
+// as it is instead of rebuilding it.
+func NewChecker(logger logger.Logger, collection string, filesBucket string, indexer Indexer, metadataStore MetadataStore) *Service {
+	return &Service{
+		logger:        logger,
+		collection:    collection,
+		filesBucket:   filesBucket,
+		indexer:       indexer,
+		metadataStore: metadataStore,
+	}
+}
+
 // Create builds an index or incrementally updates it if it already exists
 func (s *Service) Build(rootPath string, excludeFolders []string, requestID string) error {
 
-	s.setRequestStatus(requestID, 0)
+	if s.buildIndexC == nil {
+		return errors.New("index service only checks the index")
+	}
 
-	select {
-	// This leads to s.buildIndex being called
-	case s.buildIndexC <- indexRequest{rootPath: rootPath, excludeFolders: excludeFolders, requestID: requestID}:
-		return nil
-	default:
+	if !s.inProgress.CompareAndSwap(false, true) {
 		s.logger.Warn("request to index while indexing is already in progress")
 		return errors.New("indexing already in progress")
 	}
+
+	s.setRequestStatus(requestID, 0)
+
+	// This leads to s.buildIndex being called
+	s.buildIndexC <- indexRequest{rootPath: rootPath, excludeFolders: excludeFolders, requestID: requestID}
+	return nil
 }
 
-// GetStatus retrieves the progress status for index creation
-func (s *Service) GetStatus(requestID string) (int, error) {
-	value, err := s.metadataStore.Get(kvdb.RequestsBucket, requestID)
-	if err != nil {
-		return 0, fmt.Errorf("request not found: %w", err)
-	}
+// IsInProgress returns true if an index request is being worked on
+func (s *Service) IsInProgress() bool {
+	return s.inProgress.Load()
+}
 
-	status, err := strconv.Atoi(value)
-	if err != nil {
-		return 0, fmt.Errorf("invalid status value: %w", err)
+// Pause waits for the index request in progress to be done, and refuses index requests until Resume is called.
+// It returns an error if ctx is done first.
+func (s *Service) Pause(ctx context.Context) error {
+	ticker := time.NewTicker(pausePollInterval)
+	defer ticker.Stop()
+
+	for !s.inProgress.CompareAndSwap(false, true) {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("indexing could not be paused: %w", ctx.Err())
+		}
 	}
 
-	return status, nil
+	return nil
+}
+
+// Resume accepts index requests again after Pause
+func (s *Service) Resume() {
+	s.inProgress.Store(false)
 }
 
-func (s *Service) build(ctx context.Context) {
+// build works on index requests one at a time, after rebuilding the index first if rebuild is true
+func (s *Service) build(ctx context.Context, rebuild bool) {
+
+	if rebuild {
+		if err := s.rebuildIndex(ctx); err != nil {
+			s.logger.Error("failed to rebuild index with the current schema, it will be rebuilt again at startup", "collection", s.collection, "err", err.Error())
+		}
+		s.inProgress.Store(false)
+	}
 
 	for {
 		select {
 		case req := <-s.buildIndexC:
+			result := JobResult{RequestID: req.requestID, Collection: s.collection, RootPath: req.rootPath, StartedAt: time.Now().UTC()}
 			indexTimeoutCtx, cancel := context.WithTimeout(ctx, maxIndexBuildingTime)
-			defer cancel()
+			result.Status = s.buildIndex(indexTimeoutCtx, req.rootPath, req.excludeFolders, req.requestID, &result)
+			cancel()
+			result.FinishedAt = time.Now().UTC()
+
+			// Listeners are told before the final status is set, so that clients waiting for the request see
+			// what follows from it. Listeners must return quickly, since new requests are refused until they
+			// do, so slow work like running saved searches or delivering webhooks is done in the background.
+			for _, listener := range s.listeners {
+				listener.IndexDone(result)
+			}
 
-			s.buildIndex(indexTimeoutCtx, req.rootPath, req.excludeFolders, req.requestID)
+			// The final status is set only after new index requests can be accepted, so that
+			// a client that sees the request complete can immediately send another one
+			s.inProgress.Store(false)
+			s.setRequestStatus(req.requestID, result.Status)
 		case <-ctx.Done():
 			s.logger.Info("index service stopped", "reason", ctx.Err())
 			return
@@ -104,12 +203,13 @@ func (s *Service) build(ctx context.Context) {
 	}
 }
 
-func (s *Service) buildIndex(ctx context.Context, rootPath string, excludeFolders []string, requestID string) {
+// buildIndex indexes the new and modified files under a root path and removes deleted files from the index,
+// counting them in result. It returns the final status of the request.
+func (s *Service) buildIndex(ctx context.Context, rootPath string, excludeFolders []string, requestID string, result *JobResult) int {
 	files, err := s.getFilesToIndex(rootPath, excludeFolders)
 	if err != nil {
 		s.logger.Error("failed to create index", "request_id", requestID, "err", err.Error())
-		s.setRequestStatus(requestID, ProgressStatusFailed)
-		return
+		return ProgressStatusFailed
 	}
 
 	// Update progress to ProgressStatusStep1% after getFilesToIndex completes
@@ -119,21 +219,139 @@ func (s *Service) buildIndex(ctx context.Context, rootPath string, excludeFolder
 	deletedFiles, err := s.getDeletedFiles()
 	if err != nil {
 		s.logger.Error("failed to create index", "request_id", requestID, "err", err.Error())
-		s.setRequestStatus(requestID, ProgressStatusFailed)
-		return
+		return ProgressStatusFailed
 	}
 
 	if err := s.removeDeletedFiles(deletedFiles); err != nil {
 		s.logger.Error("failed to create index", "request_id", requestID, "err", err.Error())
-		s.setRequestStatus(requestID, ProgressStatusFailed)
-		return
+		return ProgressStatusFailed
 	}
+	result.FilesDeleted = len(deletedFiles)
 
 	// Update progress to ProgressStatusStep2% after getDeletedFiles and removeDeletedFiles complete
 	s.setRequestStatus(requestID, ProgressStatusStep2)
 
-	s.doBuildIndex(ctx, files, requestID)
+	status := s.doBuildIndex(ctx, files, requestID, &result.FilesIndexed)
+
+	if err := s.indexer.Flush(); err != nil {
+		s.logger.Error("failed to save search index", "request_id", requestID, "err", err.Error())
+		return ProgressStatusFailed
+	}
+
+	return status
+}
+
+// checkSchemaVersion checks whether the index was built with the current schema. It returns true if the index
+// has to be rebuilt, in which case index requests are refused until it is. The index records the schema it was
+// built with itself, so the version recorded in the metadata store is only brought up to date with it, which
+// it is not yet for stores from before it was recorded there.
+func (s *Service) checkSchemaVersion() bool {
+	currentVersion := strconv.Itoa(s.indexer.SchemaVersion())
+	if !s.indexer.IsOutdated() {
+		storedVersion, err := s.metadataStore.Get(kvdb.BoltDefaultBucket, kvdb.SchemaVersionKey(s.collection))
+		var notFoundErr *kvdb.NotFoundError
+		if err != nil && !errors.As(err, &notFoundErr) {
+			s.logger.Warn("could not get schema version of index", "collection", s.collection, "err", err.Error())
+		}
+		if storedVersion != currentVersion {
+			s.setSchemaVersion()
+		}
+		return false
+	}
+
+	// An empty index has nothing to rebuild, so it is recreated with the current schema right away
+	if docCount, err := s.indexer.GetDocCount(); err == nil && docCount == 0 {
+		if err := s.recreateEmptyIndex(); err != nil {
+			s.logger.Error("failed to recreate empty index", "collection", s.collection, "err", err.Error())
+		}
+		return false
+	}
+
+	s.logger.Warn("index was built with a different schema and will be rebuilt in the background", "collection", s.collection, "schema_version", currentVersion)
+	s.inProgress.Store(true)
+
+	return true
+}
+
+// rebuildIndex indexes the files in the index again into a new index with the current schema, which replaces
+// the index once it is done. Searches are served from the old index meanwhile.
+func (s *Service) rebuildIndex(ctx context.Context) error {
+	startedAt := time.Now()
+
+	files, err := s.indexer.IndexedFiles()
+	if err != nil {
+		return fmt.Errorf("failed to list indexed files: %w", err)
+	}
+
+	rebuild, err := s.indexer.StartRebuild()
+	if err != nil {
+		return fmt.Errorf("failed to start rebuilding index: %w", err)
+	}
+
+	for i := 0; i < len(files); i += searchdb.IndexingBatchSize {
+		if ctx.Err() != nil {
+			rebuild.Abort()
+			return ctx.Err()
+		}
+
+		var filesInBatch []FileInfo
+		for _, file := range files[i:min(i+searchdb.IndexingBatchSize, len(files))] {
+			fileInfo, err := newFileInfo(file.Path, file.Root)
+			if err != nil {
+				// Files that are gone are removed from the index by the next index request
+				s.logger.Warn("could not rebuild index of file", "path", file.Path, "err", err.Error())
+				continue
+			}
+			filesInBatch = append(filesInBatch, fileInfo)
+		}
+
+		documents, _ := s.extractDocuments(filesInBatch, 0)
+		if err := rebuild.BuildIndex(documents); err != nil {
+			rebuild.Abort()
+			return fmt.Errorf("failed to rebuild index: %w", err)
+		}
+	}
+
+	if err := rebuild.Commit(); err != nil {
+		return fmt.Errorf("failed to switch to rebuilt index: %w", err)
+	}
+	s.setSchemaVersion()
+
+	s.logger.Info("rebuilt index with the current schema", "collection", s.collection, "files", len(files), "duration", time.Since(startedAt).String())
+
+	return nil
+}
+
+// setSchemaVersion records that the index was built with the current schema
+func (s *Service) setSchemaVersion() {
+	if err := s.metadataStore.Set(kvdb.BoltDefaultBucket, kvdb.SchemaVersionKey(s.collection), strconv.Itoa(s.indexer.SchemaVersion())); err != nil {
+		s.logger.Error("failed to set schema version of index", "collection", s.collection, "err", err.Error())
+	}
+}
+
+// recreateEmptyIndex replaces an empty index built with an older schema with one built with the current schema.
+// File metadata is cleared as well, so that any file it still lists is indexed again instead of being skipped as
+// unmodified. Indexes with documents are rebuilt in the background instead, see rebuildIndex.
+func (s *Service) recreateEmptyIndex() error {
+	s.logger.Info("recreating empty index created with an older schema", "collection", s.collection)
+	if err := s.indexer.Recreate(); err != nil {
+		s.logger.Error("failed to recreate search index", "err", err.Error())
+		return fmt.Errorf("failed to recreate search index: %w", err)
+	}
+
+	indexedFiles, err := s.metadataStore.GetAllKeys(s.filesBucket)
+	if err != nil {
+		s.logger.Error("failed to get all keys from database", "err", err.Error())
+		return fmt.Errorf("failed to get all keys from database: %w", err)
+	}
 
+	if err := s.metadataStore.DeleteMany(s.filesBucket, indexedFiles); err != nil {
+		s.logger.Error("failed to delete file metadata", "err", err.Error())
+		return fmt.Errorf("failed to delete file metadata: %w", err)
+	}
+	s.setSchemaVersion()
+
+	return nil
 }
 
 func (s *Service) removeDeletedFiles(deletedFiles []string) error {
@@ -147,22 +365,19 @@ func (s *Service) removeDeletedFiles(deletedFiles []string) error {
 	}
 
 	// Remove metadata for deleted files
-	for _, filePath := range deletedFiles {
-		if err := s.metadataStore.Delete(kvdb.FilesBucket, filePath); err != nil {
-			s.logger.Error("failed to delete file metadata", "path", filePath, "err", err.Error())
-		}
+	if err := s.metadataStore.DeleteMany(s.filesBucket, deletedFiles); err != nil {
+		s.logger.Error("failed to delete file metadata", "err", err.Error())
 	}
 	return nil
 }
 
-func (s *Service) doBuildIndex(ctx context.Context, files []FileInfo, requestID string) {
+func (s *Service) doBuildIndex(ctx context.Context, files []FileInfo, requestID string, indexedCount *int) int {
 	s.logger.Info("building index of files...")
 	indexTime := time.Now().UTC()
 
 	if len(files) == 0 {
-		s.setRequestStatus(requestID, ProgressStatusComplete)
 		s.logger.Info("no files to index")
-		return
+		return ProgressStatusComplete
 	}
 
 	numGoroutines := min(maxGoRoutinesForFileProcessing, len(files))
@@ -201,7 +416,7 @@ func (s *Service) doBuildIndex(ctx context.Context, files []FileInfo, requestID
 
 	// This is primarily so that future index requests don't lead to reindexing files that
 	// are already indexed. This go routine terminates when `processedFilesChan` is closed.
-	go s.updateMetadata(indexCtx, indexTime, requestID, len(files), processedFilesChan, &metadataWG)
+	go s.updateMetadata(indexCtx, indexTime, requestID, len(files), processedFilesChan, &metadataWG, indexedCount)
 
 	go func() {
 		indexWG.Wait()
@@ -210,33 +425,33 @@ func (s *Service) doBuildIndex(ctx context.Context, files []FileInfo, requestID
 
 	metadataWG.Wait()
 	if ctx.Err() != nil {
-		s.setRequestStatus(requestID, ProgressStatusFailed)
 		s.logger.Error("indexing cancelled", "request_id", requestID, "err", ctx.Err())
+		return ProgressStatusFailed
 	}
 
-	// Update progress to 100% after index building and metadata updation completes
-	s.setRequestStatus(requestID, ProgressStatusComplete)
-
+	// Progress is 100% after index building and metadata updation completes
+	return ProgressStatusComplete
 }
 
-func (s *Service) updateMetadata(ctx context.Context, indexTime time.Time, requestID string, totalFilesCount int, processedFilesChan chan []FileInfo, wg *sync.WaitGroup) {
+// updateMetadata records when processed files were indexed, and counts them in updatedCount
+func (s *Service) updateMetadata(ctx context.Context, indexTime time.Time, requestID string, totalFilesCount int, processedFilesChan chan []FileInfo, wg *sync.WaitGroup, updatedCount *int) {
 	defer wg.Done()
 	s.logger.Info("updating file metadata...")
-	updatedCount := 0
 
+	metadata := kvdb.FileMetadata{
+		LastIndexed: indexTime,
+	}
 	for processedFiles := range processedFilesChan {
+		paths := make([]string, 0, len(processedFiles))
 		for _, file := range processedFiles {
-			metadata := kvdb.FileMetadata{
-				LastIndexed: indexTime,
-			}
-			if err := s.setFileMetadata(file.Path, metadata); err == nil {
-				updatedCount++
-			}
-
+			paths = append(paths, file.Path)
+		}
+		if err := s.setFilesMetadata(paths, metadata); err == nil {
+			*updatedCount += len(paths)
 		}
-		if updatedCount%1000 == 0 {
-			s.logger.Info("updated metadata for files:", "count", fmt.Sprintf("%d/%d", updatedCount, totalFilesCount))
-			status := getProgressPercentage(updatedCount, totalFilesCount, ProgressStatusStep2, ProgressStatusComplete)
+		if *updatedCount%1000 == 0 {
+			s.logger.Info("updated metadata for files:", "count", fmt.Sprintf("%d/%d", *updatedCount, totalFilesCount))
+			status := getProgressPercentage(*updatedCount, totalFilesCount, ProgressStatusStep2, ProgressStatusComplete)
 			s.setRequestStatus(requestID, status)
 		}
 	}
@@ -244,7 +459,7 @@ func (s *Service) updateMetadata(ctx context.Context, indexTime time.Time, reque
 		s.logger.Error("metadata update cancelled", "request_id", requestID, "err", ctx.Err())
 		return
 	}
-	s.logger.Info("finished updating metadata successfully!", "count", fmt.Sprintf("%d/%d", updatedCount, totalFilesCount))
+	s.logger.Info("finished updating metadata successfully!", "count", fmt.Sprintf("%d/%d", *updatedCount, totalFilesCount))
 
 }
 
@@ -260,44 +475,48 @@ func (s *Service) getFilesToIndex(rootPath string, excludeFolders []string) ([]F
 	return files, nil
 }
 
-func (s *Service) setFileMetadata(filepath string, metadata kvdb.FileMetadata) error {
-	if filepath == "" {
-		s.logger.Error("filepath cannot be empty", "filepath", filepath)
-		return fmt.Errorf("filepath cannot be empty")
-	}
-
+// setFilesMetadata sets the same metadata for several files in a single write to the metadata store
+func (s *Service) setFilesMetadata(paths []string, metadata kvdb.FileMetadata) error {
 	data, err := json.Marshal(metadata)
 	if err != nil {
-		s.logger.Error("failed to marshal metadata", "filepath", filepath, "err", err.Error())
-		return fmt.Errorf("failed to marshal metadata for %s: %w", filepath, err)
+		s.logger.Error("failed to marshal metadata", "err", err.Error())
+		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
-	if err := s.metadataStore.Set(kvdb.FilesBucket, filepath, string(data)); err != nil {
-		s.logger.Error("failed to set file metadata", "filepath", filepath, "err", err.Error())
+	entries := make(map[string]string, len(paths))
+	for _, path := range paths {
+		entries[path] = string(data)
+	}
+	if err := s.metadataStore.SetMany(s.filesBucket, entries); err != nil {
+		s.logger.Error("failed to set file metadata", "files", len(paths), "err", err.Error())
 		return err
 	}
 
 	return nil
 }
 
-func (s *Service) getFileMetadata(filepath string) (*kvdb.FileMetadata, error) {
-
-	value, err := s.metadataStore.Get(kvdb.FilesBucket, filepath)
+// getFilesMetadata returns the metadata of every indexed file by path, leaving out any that can not be read
+func (s *Service) getFilesMetadata() (map[string]kvdb.FileMetadata, error) {
+	entries, err := s.metadataStore.GetAll(s.filesBucket)
 	if err != nil {
 		return nil, err
 	}
 
-	var metadata kvdb.FileMetadata
-	if err := json.Unmarshal([]byte(value), &metadata); err != nil {
-		s.logger.Error("failed to unmarshal metadata", "filepath", filepath, "err", err.Error())
-		return nil, fmt.Errorf("failed to unmarshal metadata for %s: %w", filepath, err)
+	filesMetadata := make(map[string]kvdb.FileMetadata, len(entries))
+	for path, value := range entries {
+		var metadata kvdb.FileMetadata
+		if err := json.Unmarshal([]byte(value), &metadata); err != nil {
+			s.logger.Error("failed to unmarshal metadata", "filepath", path, "err", err.Error())
+			continue
+		}
+		filesMetadata[path] = metadata
 	}
 
-	return &metadata, nil
+	return filesMetadata, nil
 }
 
 func (s *Service) getDeletedFiles() ([]string, error) {
-	allKeys, err := s.metadataStore.GetAllKeys(kvdb.FilesBucket)
+	allKeys, err := s.metadataStore.GetAllKeys(s.filesBucket)
 	if err != nil {
 		s.logger.Error("failed to get all keys from database", "err", err.Error())
 		return nil, fmt.Errorf("failed to get all keys from database: %w", err)
@@ -314,12 +533,6 @@ func (s *Service) getDeletedFiles() ([]string, error) {
 	return deletedFiles, nil
 }
 
-func (s *Service) setRequestStatus(requestID string, status int) {
-	if err := s.metadataStore.Set(kvdb.RequestsBucket, requestID, strconv.Itoa(status)); err != nil {
-		s.logger.Error("failed to update request status", "requestID", requestID, "progress", ProgressStatusStep1, "err", err.Error())
-	}
-}
-
 func (s *Service) doBuildIndexForFilesPortion(ctx context.Context, filesPortion []FileInfo, goroutineID int, processedFilesChan chan []FileInfo, wg *sync.WaitGroup) {
 	defer wg.Done()
 	numOfFiles := len(filesPortion)
@@ -343,10 +556,24 @@ func (s *Service) doBuildIndexForFilesPortion(ctx context.Context, filesPortion
 
 func (s *Service) doBuildIndexForSingleBatchOfFiles(filesInBatch []FileInfo, goroutineID int) []FileInfo {
 
+	documents, processedFiles := s.extractDocuments(filesInBatch, goroutineID)
+
+	if err := s.indexer.BuildIndex(documents); err != nil {
+		s.logger.Error("failed to build index for goroutine", "goroutine_id", goroutineID, "err", err.Error())
+		return make([]FileInfo, 0)
+	}
+
+	return processedFiles
+
+}
+
+// extractDocuments extracts the content of files into documents, returning them along with the files they
+// were extracted from. Files whose content could not be extracted are skipped.
+func (s *Service) extractDocuments(files []FileInfo, goroutineID int) ([]*searchdb.Document, []FileInfo) {
 	var documents []*searchdb.Document
 	var processedFiles []FileInfo
 
-	for _, file := range filesInBatch {
+	for _, file := range files {
 
 		doc, err := extractContent(file)
 		if err != nil {
@@ -357,13 +584,7 @@ func (s *Service) doBuildIndexForSingleBatchOfFiles(filesInBatch []FileInfo, gor
 		processedFiles = append(processedFiles, file)
 	}
 
-	if err := s.indexer.BuildIndex(documents); err != nil {
-		s.logger.Error("failed to build index for goroutine", "goroutine_id", goroutineID, "err", err.Error())
-		return make([]FileInfo, 0)
-	}
-
-	return processedFiles
-
+	return documents, processedFiles
 }
 
 func getProgressPercentage(done int, total int, initial int, final int) int {
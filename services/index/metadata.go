@@ -5,5 +5,12 @@ type MetadataStore interface {
 	Get(bucket, key string) (string, error)
 	Delete(bucket, key string) error
 	GetAllKeys(bucket string) ([]string, error)
+	// SetMany, DeleteMany and GetAll set, delete and get several keys of a bucket at once, in a single
+	// transaction, which is much faster than doing so one key at a time
+	SetMany(bucket string, entries map[string]string) error
+	DeleteMany(bucket string, keys []string) error
+	GetAll(bucket string) (map[string]string, error)
+	CreateBucket(bucket string) error
+	DeleteBucket(bucket string) error
 	Close() error
 }
@@ -0,0 +1,166 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/meghashyamc/wheresthat/db/kvdb"
+)
+
+// Retention is how long the statuses of finished index requests are kept. Statuses of requests that are
+// queued or in progress are always kept.
+type Retention struct {
+	// MaxAge is how long after it finished a request is kept, or 0 to keep requests however old they are
+	MaxAge time.Duration
+	// MaxCount is how many of the latest finished requests are kept, or 0 to keep any number of them
+	MaxCount int
+	// Interval is how often requests past the retention are removed, or 0 to never remove them
+	Interval time.Duration
+}
+
+// requestRecord is how the status of an index request is stored, under its ID in kvdb.RequestsBucket
+type requestRecord struct {
+	Status int `json:"status"`
+	// UpdatedAt is when the status was last set, which is when the request finished once it has
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (r requestRecord) isFinished() bool {
+	return r.Status == ProgressStatusComplete || r.Status == ProgressStatusFailed
+}
+
+// GetStatus retrieves the progress status for index creation
+func (s *Service) GetStatus(requestID string) (int, error) {
+	value, err := s.metadataStore.Get(kvdb.RequestsBucket, requestID)
+	if err != nil {
+		return 0, fmt.Errorf("request not found: %w", err)
+	}
+
+	record, err := parseRequestRecord(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid status value: %w", err)
+	}
+
+	return record.Status, nil
+}
+
+func (s *Service) setRequestStatus(requestID string, status int) {
+	data, err := json.Marshal(requestRecord{Status: status, UpdatedAt: time.Now().UTC()})
+	if err != nil {
+		s.logger.Error("failed to marshal request status", "requestID", requestID, "err", err.Error())
+		return
+	}
+
+	if err := s.metadataStore.Set(kvdb.RequestsBucket, requestID, string(data)); err != nil {
+		s.logger.Error("failed to update request status", "requestID", requestID, "progress", status, "err", err.Error())
+	}
+}
+
+// StartJanitor removes the statuses of finished index requests past the retention every retention interval,
+// starting right away, until ctx is done. Statuses of index requests into every collection are kept in the
+// same bucket, so only one service needs to be started.
+func (s *Service) StartJanitor(ctx context.Context, retention Retention) {
+	if retention.Interval <= 0 || (retention.MaxAge <= 0 && retention.MaxCount <= 0) {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(retention.Interval)
+		defer ticker.Stop()
+
+		for {
+			now := time.Now().UTC()
+			removed, err := s.removeFinishedRequests(func(i int, record requestRecord) bool {
+				return (retention.MaxCount > 0 && i >= retention.MaxCount) || (retention.MaxAge > 0 && now.Sub(record.UpdatedAt) > retention.MaxAge)
+			})
+			if err == nil && removed > 0 {
+				s.logger.Info("removed statuses of old index requests", "removed", removed)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// PurgeFinishedRequests removes the statuses of all finished index requests, returning how many were removed
+func (s *Service) PurgeFinishedRequests() (int, error) {
+	removed, err := s.removeFinishedRequests(func(int, requestRecord) bool { return true })
+	if err != nil {
+		return 0, err
+	}
+
+	s.logger.Info("purged statuses of finished index requests", "removed", removed)
+
+	return removed, nil
+}
+
+// removeFinishedRequests removes the statuses of the finished index requests that shouldRemove returns true
+// for. It is called with the finished requests latest first, along with the position of each.
+func (s *Service) removeFinishedRequests(shouldRemove func(i int, record requestRecord) bool) (int, error) {
+	entries, err := s.metadataStore.GetAll(kvdb.RequestsBucket)
+	if err != nil {
+		s.logger.Error("failed to get index requests", "err", err.Error())
+		return 0, fmt.Errorf("failed to get index requests: %w", err)
+	}
+
+	type finishedRequest struct {
+		id     string
+		record requestRecord
+	}
+	var finished []finishedRequest
+	for id, value := range entries {
+		record, err := parseRequestRecord(value)
+		if err != nil {
+			s.logger.Warn("could not read status of index request", "requestID", id, "err", err.Error())
+			continue
+		}
+		// Finished requests never change again, while requests queued or in progress must be kept
+		if record.isFinished() {
+			finished = append(finished, finishedRequest{id: id, record: record})
+		}
+	}
+	slices.SortFunc(finished, func(a, b finishedRequest) int {
+		if c := b.record.UpdatedAt.Compare(a.record.UpdatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.id, b.id)
+	})
+
+	var toRemove []string
+	for i, request := range finished {
+		if shouldRemove(i, request.record) {
+			toRemove = append(toRemove, request.id)
+		}
+	}
+
+	if err := s.metadataStore.DeleteMany(kvdb.RequestsBucket, toRemove); err != nil {
+		s.logger.Error("failed to remove index requests", "err", err.Error())
+		return 0, fmt.Errorf("failed to remove index requests: %w", err)
+	}
+
+	return len(toRemove), nil
+}
+
+// parseRequestRecord reads the stored status of an index request. Statuses used to be stored as just a number,
+// in which case the time they were set is not known and is left as zero, so they are the first to go.
+func parseRequestRecord(value string) (requestRecord, error) {
+	if status, err := strconv.Atoi(value); err == nil {
+		return requestRecord{Status: status}, nil
+	}
+
+	var record requestRecord
+	if err := json.Unmarshal([]byte(value), &record); err != nil {
+		return requestRecord{}, err
+	}
+
+	return record, nil
+}
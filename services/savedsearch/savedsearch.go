@@ -0,0 +1,336 @@
+package savedsearch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/meghashyamc/wheresthat/db/kvdb"
+	"github.com/meghashyamc/wheresthat/db/searchdb"
+	"github.com/meghashyamc/wheresthat/logger"
+	"github.com/meghashyamc/wheresthat/services/index"
+	"github.com/meghashyamc/wheresthat/services/search"
+)
+
+var (
+	ErrNotFound      = errors.New("saved search not found")
+	ErrAlreadyExists = errors.New("a saved search with this name already exists")
+)
+
+const (
+	// Only this many matches of a saved search are tracked
+	maxMatches = 1000
+	// Only this many of the latest changes to the matches of a saved search are kept
+	maxChanges = 100
+)
+
+// SavedSearch is a query, along with its filters, that is run again whenever the index is updated. Saved
+// searches are run against the default collection only, so indexing other collections never changes their
+// matches.
+type SavedSearch struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Query string `json:"query"`
+	Under string `json:"under,omitempty"`
+	Mode  string `json:"mode,omitempty"`
+	Lang  string `json:"lang,omitempty"`
+	// CreatedAt is when the search was saved, and LastRunAt is when it was last run
+	CreatedAt time.Time `json:"created_at"`
+	LastRunAt time.Time `json:"last_run_at"`
+	// MatchCount is the number of files that matched the last run
+	MatchCount int `json:"match_count"`
+}
+
+// Change holds the files that started or stopped matching a saved search in one of its runs
+type Change struct {
+	RequestID string    `json:"request_id"`
+	RunAt     time.Time `json:"run_at"`
+	Added     []string  `json:"added"`
+	Removed   []string  `json:"removed"`
+}
+
+// record is how a saved search is stored
+type record struct {
+	SavedSearch
+	// Matches holds the paths of the files that matched the last run, in order
+	Matches []string `json:"matches"`
+	// Changes holds the changes to the matches, with the latest first
+	Changes []Change `json:"changes"`
+}
+
+type Service struct {
+	logger        logger.Logger
+	searcher      search.Searcher
+	metadataStore index.MetadataStore
+	// mu serializes changes to saved searches, so that a run does not bring back a deleted one
+	mu sync.Mutex
+
+	// pendingMu guards the fields below
+	pendingMu sync.Mutex
+	// pending is the latest index request that saved searches have yet to be run for
+	pending *index.JobResult
+	// running is true while saved searches are being run in the background
+	running bool
+}
+
+func New(logger logger.Logger, searcher search.Searcher, metadataStore index.MetadataStore) *Service {
+	return &Service{
+		logger:        logger,
+		searcher:      searcher,
+		metadataStore: metadataStore,
+	}
+}
+
+// List returns all saved searches, ordered by name
+func (s *Service) List() ([]SavedSearch, error) {
+	records, err := s.getAll()
+	if err != nil {
+		return nil, err
+	}
+
+	savedSearches := make([]SavedSearch, 0, len(records))
+	for _, record := range records {
+		savedSearches = append(savedSearches, record.SavedSearch)
+	}
+
+	return savedSearches, nil
+}
+
+// Create saves a search and runs it, so that its changes are relative to the files matching it now
+func (s *Service) Create(savedSearch SavedSearch) (*SavedSearch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.getAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, existing := range records {
+		if existing.Name == savedSearch.Name {
+			return nil, fmt.Errorf("%w: %s", ErrAlreadyExists, savedSearch.Name)
+		}
+	}
+
+	matches, err := s.run(savedSearch)
+	if err != nil {
+		return nil, err
+	}
+
+	savedSearch.ID = uuid.New().String()
+	savedSearch.CreatedAt = time.Now().UTC()
+	savedSearch.LastRunAt = savedSearch.CreatedAt
+	savedSearch.MatchCount = len(matches)
+
+	newRecord := &record{SavedSearch: savedSearch, Matches: matches, Changes: []Change{}}
+	if err := s.set(newRecord); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("saved search", "id", newRecord.ID, "name", newRecord.Name, "matches", len(matches))
+
+	return &newRecord.SavedSearch, nil
+}
+
+// Delete removes a saved search along with its changes
+func (s *Service) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.get(id); err != nil {
+		return err
+	}
+
+	if err := s.metadataStore.Delete(kvdb.SavedSearchesBucket, id); err != nil {
+		s.logger.Error("failed to delete saved search", "id", id, "err", err.Error())
+		return fmt.Errorf("failed to delete saved search: %w", err)
+	}
+
+	return nil
+}
+
+// Changes returns a saved search along with the latest changes to its matches, with the latest first
+func (s *Service) Changes(id string) (*SavedSearch, []Change, error) {
+	record, err := s.get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &record.SavedSearch, record.Changes, nil
+}
+
+// IndexDone runs every saved search again in the background once an index request of the default collection
+// completes, so that the index service can go on to the next request meanwhile. Requests that complete while
+// saved searches are running are run for together once they are done, with their changes recorded under the
+// latest of them.
+func (s *Service) IndexDone(result index.JobResult) {
+	// Saved searches are run against the default collection, so other collections do not change their matches
+	if result.Status != index.ProgressStatusComplete || result.Collection != searchdb.DefaultCollection {
+		return
+	}
+
+	s.pendingMu.Lock()
+	defer s.pendingMu.Unlock()
+
+	s.pending = &result
+	if !s.running {
+		s.running = true
+		go s.runPending()
+	}
+}
+
+// runPending runs saved searches until no index request is left to run them for
+func (s *Service) runPending() {
+	for {
+		s.pendingMu.Lock()
+		result := s.pending
+		s.pending = nil
+		if result == nil {
+			s.running = false
+			s.pendingMu.Unlock()
+			return
+		}
+		s.pendingMu.Unlock()
+
+		s.runAll(*result)
+	}
+}
+
+// runAll runs every saved search and records the files that started or stopped matching it since its last run
+func (s *Service) runAll(result index.JobResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.getAll()
+	if err != nil {
+		return
+	}
+
+	for _, record := range records {
+		matches, err := s.run(record.SavedSearch)
+		if err != nil {
+			// A search failing now does not mean its matches are gone, so they are left as they were
+			continue
+		}
+
+		runAt := time.Now().UTC()
+		added, removed := diffMatches(record.Matches, matches)
+		if len(added) > 0 || len(removed) > 0 {
+			change := Change{RequestID: result.RequestID, RunAt: runAt, Added: added, Removed: removed}
+			record.Changes = append([]Change{change}, record.Changes[:min(len(record.Changes), maxChanges-1)]...)
+		}
+		record.Matches = matches
+		record.LastRunAt = runAt
+		record.MatchCount = len(matches)
+
+		if err := s.set(record); err != nil {
+			continue
+		}
+
+		s.logger.Info("ran saved search", "id", record.ID, "name", record.Name, "added", len(added), "removed", len(removed))
+	}
+}
+
+// run returns the sorted paths of the files matching a saved search
+func (s *Service) run(savedSearch SavedSearch) ([]string, error) {
+	results, err := s.searcher.Search(searchdb.SearchParams{
+		Query:     savedSearch.Query,
+		Limit:     maxMatches,
+		Under:     savedSearch.Under,
+		Fuzziness: searchdb.FuzzinessAuto,
+		Mode:      savedSearch.Mode,
+		Lang:      savedSearch.Lang,
+	})
+	if err != nil {
+		s.logger.Error("failed to run saved search", "id", savedSearch.ID, "name", savedSearch.Name, "err", err.Error())
+		return nil, fmt.Errorf("failed to run saved search: %w", err)
+	}
+
+	matches := make([]string, 0, len(results.Results))
+	for _, result := range results.Results {
+		matches = append(matches, result.Path)
+	}
+	slices.Sort(matches)
+
+	return matches, nil
+}
+
+// diffMatches returns the paths in the sorted current matches but not in the sorted previous ones, and the
+// other way around
+func diffMatches(previous []string, current []string) ([]string, []string) {
+	added, removed := []string{}, []string{}
+	i, j := 0, 0
+	for i < len(previous) || j < len(current) {
+		switch {
+		case j == len(current) || (i < len(previous) && previous[i] < current[j]):
+			removed = append(removed, previous[i])
+			i++
+		case i == len(previous) || current[j] < previous[i]:
+			added = append(added, current[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+
+	return added, removed
+}
+
+func (s *Service) get(id string) (*record, error) {
+	value, err := s.metadataStore.Get(kvdb.SavedSearchesBucket, id)
+	var notFoundErr *kvdb.NotFoundError
+	if errors.As(err, &notFoundErr) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get saved search: %w", err)
+	}
+
+	var savedRecord record
+	if err := json.Unmarshal([]byte(value), &savedRecord); err != nil {
+		s.logger.Error("failed to unmarshal saved search", "id", id, "err", err.Error())
+		return nil, fmt.Errorf("failed to unmarshal saved search %s: %w", id, err)
+	}
+
+	return &savedRecord, nil
+}
+
+func (s *Service) getAll() ([]*record, error) {
+	ids, err := s.metadataStore.GetAllKeys(kvdb.SavedSearchesBucket)
+	if err != nil {
+		s.logger.Error("failed to get saved searches", "err", err.Error())
+		return nil, fmt.Errorf("failed to get saved searches: %w", err)
+	}
+
+	records := make([]*record, 0, len(ids))
+	for _, id := range ids {
+		record, err := s.get(id)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	slices.SortFunc(records, func(a, b *record) int { return strings.Compare(a.Name, b.Name) })
+
+	return records, nil
+}
+
+func (s *Service) set(savedRecord *record) error {
+	data, err := json.Marshal(savedRecord)
+	if err != nil {
+		s.logger.Error("failed to marshal saved search", "id", savedRecord.ID, "err", err.Error())
+		return fmt.Errorf("failed to marshal saved search %s: %w", savedRecord.ID, err)
+	}
+
+	if err := s.metadataStore.Set(kvdb.SavedSearchesBucket, savedRecord.ID, string(data)); err != nil {
+		s.logger.Error("failed to save saved search", "id", savedRecord.ID, "err", err.Error())
+		return fmt.Errorf("failed to save saved search: %w", err)
+	}
+
+	return nil
+}
@@ -7,7 +7,13 @@ import (
 
 // Searcher represents the search database operations needed for search functionality
 type Searcher interface {
-	Search(queryString string, limit int, offset int) (*searchdb.Response, error)
+	Search(params searchdb.SearchParams) (*searchdb.Response, error)
+	FindFiles(pattern string, limit int) (*searchdb.Response, error)
+	SuggestQueries(query string) ([]string, error)
+	FindSimilar(params searchdb.SimilarParams) (*searchdb.Response, error)
+	Complete(prefix string, limit int) (*searchdb.Completions, error)
+	GetSynonyms() [][]string
+	SetSynonyms(sets [][]string) error
 }
 
 type Service struct {
@@ -22,17 +28,118 @@ func New(logger logger.Logger, searcher Searcher) *Service {
 	}
 }
 
-func (s *Service) Search(query string, limit int, offset int) (*searchdb.Response, error) {
-	s.logger.Info("performing search", "query", query, "limit", limit, "offset", offset)
+func (s *Service) Search(params searchdb.SearchParams) (*searchdb.Response, error) {
+	s.logger.Info("performing search", "query", params.Query, "limit", params.Limit, "offset", params.Offset, "under", params.Under, "fuzziness", params.Fuzziness, "auto_correct", params.AutoCorrect, "mode", params.Mode, "ranking_profile", params.RankingProfile, "group_by", params.GroupBy, "lang", params.Lang, "collections", params.Collections)
 
 	// Perform search
-	results, err := s.searcher.Search(query, limit, offset)
+	results, err := s.searcher.Search(params)
 	if err != nil {
 		s.logger.Error("search failed", "err", err.Error())
 		return nil, err
 	}
 
+	// Patterns are matched exactly, so there is nothing to correct
+	if results.Total == 0 && params.Mode != searchdb.SearchModeRegex && params.Mode != searchdb.SearchModeLiteral {
+		results = s.searchSuggestions(params, results)
+	}
+
 	s.logger.Info("search completed", "total_results", results.Total, "returned_results", len(results.Results))
 
 	return results, nil
 }
+
+// searchSuggestions adds spelling suggestions to the results of a query that had none. If auto correction
+// is enabled, the results of the best suggestion are returned instead, unless it has no results either.
+func (s *Service) searchSuggestions(params searchdb.SearchParams, results *searchdb.Response) *searchdb.Response {
+	suggestions, err := s.searcher.SuggestQueries(params.Query)
+	if err != nil {
+		// Suggestions are only a hint, so the search itself still succeeds
+		s.logger.Warn("could not suggest queries", "query", params.Query, "err", err.Error())
+		return results
+	}
+	results.Suggestions = suggestions
+
+	if !params.AutoCorrect || len(suggestions) == 0 {
+		return results
+	}
+
+	correctedParams := params
+	correctedParams.Query = suggestions[0]
+	s.logger.Info("searching for corrected query", "query", params.Query, "corrected_query", correctedParams.Query)
+
+	correctedResults, err := s.searcher.Search(correctedParams)
+	if err != nil {
+		s.logger.Warn("search for corrected query failed", "corrected_query", correctedParams.Query, "err", err.Error())
+		return results
+	}
+	if correctedResults.Total == 0 {
+		return results
+	}
+
+	correctedResults.Suggestions = suggestions
+	correctedResults.CorrectedQuery = correctedParams.Query
+
+	return correctedResults
+}
+
+// FindFiles looks up files whose names match a pattern, for quickly jumping to a file as the pattern is typed
+func (s *Service) FindFiles(pattern string, limit int) (*searchdb.Response, error) {
+	s.logger.Debug("finding files", "pattern", pattern, "limit", limit)
+
+	results, err := s.searcher.FindFiles(pattern, limit)
+	if err != nil {
+		s.logger.Error("finding files failed", "err", err.Error())
+		return nil, err
+	}
+
+	s.logger.Debug("finding files completed", "total_results", results.Total, "returned_results", len(results.Results))
+
+	return results, nil
+}
+
+// FindSimilar looks up files whose content is similar to that of an indexed file
+func (s *Service) FindSimilar(params searchdb.SimilarParams) (*searchdb.Response, error) {
+	s.logger.Info("finding similar files", "path", params.Path, "limit", params.Limit, "offset", params.Offset, "same_root", params.SameRoot, "same_ext", params.SameExt)
+
+	results, err := s.searcher.FindSimilar(params)
+	if err != nil {
+		s.logger.Error("finding similar files failed", "path", params.Path, "err", err.Error())
+		return nil, err
+	}
+
+	s.logger.Info("finding similar files completed", "total_results", results.Total, "returned_results", len(results.Results))
+
+	return results, nil
+}
+
+// Complete looks up terms and file names starting with a prefix, for suggestions as a query is typed
+func (s *Service) Complete(prefix string, limit int) (*searchdb.Completions, error) {
+	s.logger.Debug("completing prefix", "prefix", prefix, "limit", limit)
+
+	completions, err := s.searcher.Complete(prefix, limit)
+	if err != nil {
+		s.logger.Error("completing prefix failed", "err", err.Error())
+		return nil, err
+	}
+
+	s.logger.Debug("completing prefix completed", "terms", len(completions.Terms), "files", len(completions.Files))
+
+	return completions, nil
+}
+
+// ListSynonyms returns the synonym sets that query terms are expanded with
+func (s *Service) ListSynonyms() [][]string {
+	return s.searcher.GetSynonyms()
+}
+
+// UpdateSynonyms replaces the synonym sets that query terms are expanded with
+func (s *Service) UpdateSynonyms(sets [][]string) error {
+	s.logger.Info("updating synonyms", "sets", len(sets))
+
+	if err := s.searcher.SetSynonyms(sets); err != nil {
+		s.logger.Error("updating synonyms failed", "err", err.Error())
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/meghashyamc/wheresthat/db/kvdb"
+	"github.com/meghashyamc/wheresthat/db/searchdb"
+	"github.com/meghashyamc/wheresthat/logger"
+	"github.com/meghashyamc/wheresthat/services/collection"
+	"github.com/meghashyamc/wheresthat/services/index"
+)
+
+// MetadataStore is where the outcomes of index requests are kept, and whose size on disk is reported
+type MetadataStore interface {
+	index.MetadataStore
+	Size() (int64, error)
+}
+
+// Stats describes a collection: what is in its index, how much space it takes up on disk and how its index
+// requests went
+type Stats struct {
+	Collection string `json:"collection"`
+	searchdb.IndexStats
+	Disk DiskUsage `json:"disk"`
+	Jobs JobStats  `json:"jobs"`
+}
+
+// DiskUsage holds sizes in bytes. The metadata store is shared by all collections.
+type DiskUsage struct {
+	IndexBytes    int64 `json:"index_bytes"`
+	MetadataBytes int64 `json:"metadata_bytes"`
+}
+
+// JobStats counts the index requests into a collection that completed and failed
+type JobStats struct {
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+	// LastIndexedByRoot holds when an index request of each root last completed
+	LastIndexedByRoot map[string]time.Time `json:"last_indexed_by_root"`
+}
+
+type Service struct {
+	logger        logger.Logger
+	metadataStore MetadataStore
+	collections   *collection.Service
+	// mu serializes updates to job stats
+	mu sync.Mutex
+}
+
+func New(logger logger.Logger, metadataStore MetadataStore, collections *collection.Service) *Service {
+	return &Service{
+		logger:        logger,
+		metadataStore: metadataStore,
+		collections:   collections,
+	}
+}
+
+// Get returns the stats of a collection. The stats of its index are kept up to date as it changes and its job
+// stats as index requests are done, so only sizes on disk are read each time.
+func (s *Service) Get(name string) (*Stats, error) {
+	db, err := s.collections.DB(name)
+	if err != nil {
+		return nil, err
+	}
+
+	indexBytes, err := db.DiskUsage()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get size of index: %w", err)
+	}
+	metadataBytes, err := s.metadataStore.Size()
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := s.getJobStats(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Stats{
+		Collection: name,
+		IndexStats: db.Stats(),
+		Disk:       DiskUsage{IndexBytes: indexBytes, MetadataBytes: metadataBytes},
+		Jobs:       *jobs,
+	}, nil
+}
+
+// IndexDone counts an index request among those of its collection, recording when its root was last indexed
+// if it completed
+func (s *Service) IndexDone(result index.JobResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs, err := s.getJobStats(result.Collection)
+	if err != nil {
+		return
+	}
+
+	if result.Status == index.ProgressStatusComplete {
+		jobs.Completed++
+		jobs.LastIndexedByRoot[result.RootPath] = result.FinishedAt
+	} else {
+		jobs.Failed++
+	}
+
+	s.setJobStats(result.Collection, jobs)
+}
+
+func (s *Service) getJobStats(name string) (*JobStats, error) {
+	jobs := &JobStats{LastIndexedByRoot: map[string]time.Time{}}
+
+	value, err := s.metadataStore.Get(kvdb.StatsBucket, name)
+	var notFoundErr *kvdb.NotFoundError
+	if errors.As(err, &notFoundErr) {
+		return jobs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job stats: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(value), jobs); err != nil {
+		s.logger.Error("failed to unmarshal job stats", "collection", name, "err", err.Error())
+		return nil, fmt.Errorf("failed to unmarshal job stats of %s: %w", name, err)
+	}
+	if jobs.LastIndexedByRoot == nil {
+		jobs.LastIndexedByRoot = map[string]time.Time{}
+	}
+
+	return jobs, nil
+}
+
+func (s *Service) setJobStats(name string, jobs *JobStats) {
+	data, err := json.Marshal(jobs)
+	if err != nil {
+		s.logger.Error("failed to marshal job stats", "collection", name, "err", err.Error())
+		return
+	}
+
+	if err := s.metadataStore.Set(kvdb.StatsBucket, name, string(data)); err != nil {
+		s.logger.Error("failed to save job stats", "collection", name, "err", err.Error())
+	}
+}
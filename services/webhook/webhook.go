@@ -0,0 +1,395 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/meghashyamc/wheresthat/db/kvdb"
+	"github.com/meghashyamc/wheresthat/logger"
+	"github.com/meghashyamc/wheresthat/services/index"
+)
+
+// Events that webhooks can subscribe to. Test events are sent on request to any webhook.
+const (
+	EventIndexCompleted = "index.completed"
+	EventIndexFailed    = "index.failed"
+	EventTest           = "test"
+)
+
+// Headers sent along with every delivery
+const (
+	HeaderEvent     = "X-Wheresthat-Event"
+	HeaderDelivery  = "X-Wheresthat-Delivery"
+	HeaderSignature = "X-Wheresthat-Signature"
+)
+
+var ErrNotFound = errors.New("webhook not found")
+
+// Only this many of the latest deliveries to a webhook are kept
+const maxDeliveries = 50
+
+// Webhook is a URL that events are posted to
+type Webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Delivery is the outcome of sending an event to a webhook, including any retries
+type Delivery struct {
+	ID        string `json:"id"`
+	Event     string `json:"event"`
+	RequestID string `json:"request_id,omitempty"`
+	Attempts  int    `json:"attempts"`
+	// StatusCode is the status of the response to the last attempt, if there was one
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Success    bool      `json:"success"`
+	CreatedAt  time.Time `json:"created_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// Payload is the JSON body posted to webhooks
+type Payload struct {
+	// DeliveryID is the same for all attempts of a delivery, so receivers can ignore repeats
+	DeliveryID string    `json:"delivery_id"`
+	Event      string    `json:"event"`
+	CreatedAt  time.Time `json:"created_at"`
+	// Job is the index request the event is about, if any
+	Job *Job `json:"job,omitempty"`
+}
+
+type Job struct {
+	RequestID    string    `json:"request_id"`
+	Collection   string    `json:"collection"`
+	RootPath     string    `json:"root_path"`
+	Status       string    `json:"status"`
+	FilesIndexed int       `json:"files_indexed"`
+	FilesDeleted int       `json:"files_deleted"`
+	StartedAt    time.Time `json:"started_at"`
+	FinishedAt   time.Time `json:"finished_at"`
+	DurationMS   int64     `json:"duration_ms"`
+}
+
+// record is how a webhook is stored. The secret is never returned once a webhook is created.
+type record struct {
+	Webhook
+	Secret string `json:"secret"`
+	// Deliveries holds the latest deliveries, with the latest first
+	Deliveries []Delivery `json:"deliveries"`
+}
+
+type Options struct {
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, which doubles with each retry after it
+	InitialBackoff time.Duration
+	// Timeout is how long a webhook has to respond to each attempt
+	Timeout time.Duration
+}
+
+type Service struct {
+	ctx           context.Context
+	logger        logger.Logger
+	metadataStore index.MetadataStore
+	client        *http.Client
+	options       Options
+	// mu serializes changes to webhooks, so that recording a delivery does not bring back a deleted one
+	mu sync.Mutex
+}
+
+// New creates a service posting events to webhooks. Deliveries in progress stop when ctx is done.
+func New(ctx context.Context, logger logger.Logger, metadataStore index.MetadataStore, options Options) *Service {
+	return &Service{
+		ctx:           ctx,
+		logger:        logger,
+		metadataStore: metadataStore,
+		client:        &http.Client{Timeout: options.Timeout},
+		options:       options,
+	}
+}
+
+// Sign returns the signature sent with a payload, which is the hex encoded HMAC-SHA256 of the body, keyed by
+// the webhook's secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// List returns all webhooks, oldest first
+func (s *Service) List() ([]Webhook, error) {
+	records, err := s.getAll()
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks := make([]Webhook, 0, len(records))
+	for _, record := range records {
+		webhooks = append(webhooks, record.Webhook)
+	}
+
+	return webhooks, nil
+}
+
+// Create adds a webhook that events of the given types are posted to, signed with secret
+func (s *Service) Create(url string, events []string, secret string) (*Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slices.Sort(events)
+	newRecord := &record{
+		Webhook: Webhook{
+			ID:        uuid.New().String(),
+			URL:       url,
+			Events:    slices.Compact(events),
+			CreatedAt: time.Now().UTC(),
+		},
+		Secret:     secret,
+		Deliveries: []Delivery{},
+	}
+	if err := s.set(newRecord); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("created webhook", "id", newRecord.ID, "url", newRecord.URL, "events", strings.Join(newRecord.Events, ","))
+
+	return &newRecord.Webhook, nil
+}
+
+// Delete removes a webhook along with its deliveries
+func (s *Service) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.get(id); err != nil {
+		return err
+	}
+
+	if err := s.metadataStore.Delete(kvdb.WebhooksBucket, id); err != nil {
+		s.logger.Error("failed to delete webhook", "id", id, "err", err.Error())
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	return nil
+}
+
+// Deliveries returns the latest deliveries to a webhook, with the latest first
+func (s *Service) Deliveries(id string) ([]Delivery, error) {
+	record, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return record.Deliveries, nil
+}
+
+// SendTestEvent posts a test event to a webhook and waits for the response. It is only attempted once, since
+// whoever sent it is waiting for the outcome and can send it again.
+func (s *Service) SendTestEvent(id string) (*Delivery, error) {
+	record, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := s.deliver(record, Payload{Event: EventTest}, 1)
+
+	return &delivery, nil
+}
+
+// IndexDone posts the outcome of an index request to the webhooks subscribed to it. Deliveries happen in the
+// background, so that slow webhooks do not hold up indexing.
+func (s *Service) IndexDone(result index.JobResult) {
+	records, err := s.getAll()
+	if err != nil {
+		return
+	}
+
+	event := EventIndexCompleted
+	status := "completed"
+	if result.Status != index.ProgressStatusComplete {
+		event = EventIndexFailed
+		status = "failed"
+	}
+
+	job := &Job{
+		RequestID:    result.RequestID,
+		Collection:   result.Collection,
+		RootPath:     result.RootPath,
+		Status:       status,
+		FilesIndexed: result.FilesIndexed,
+		FilesDeleted: result.FilesDeleted,
+		StartedAt:    result.StartedAt,
+		FinishedAt:   result.FinishedAt,
+		DurationMS:   result.FinishedAt.Sub(result.StartedAt).Milliseconds(),
+	}
+
+	for _, record := range records {
+		if slices.Contains(record.Events, event) {
+			go s.deliver(record, Payload{Event: event, Job: job}, s.options.MaxAttempts)
+		}
+	}
+}
+
+// deliver posts a payload to a webhook up to maxAttempts times, retrying with exponential backoff when the
+// webhook can not be reached or fails with a server error, and records the outcome in the webhook's delivery log
+func (s *Service) deliver(webhook *record, payload Payload, maxAttempts int) Delivery {
+	payload.DeliveryID = uuid.New().String()
+	payload.CreatedAt = time.Now().UTC()
+
+	delivery := Delivery{ID: payload.DeliveryID, Event: payload.Event, CreatedAt: payload.CreatedAt}
+	if payload.Job != nil {
+		delivery.RequestID = payload.Job.RequestID
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		s.logger.Error("failed to marshal webhook payload", "id", webhook.ID, "err", err.Error())
+		delivery.Error = err.Error()
+		return s.recordDelivery(webhook.ID, delivery)
+	}
+
+	backoff := s.options.InitialBackoff
+	for delivery.Attempts < max(maxAttempts, 1) {
+		if delivery.Attempts > 0 {
+			select {
+			case <-time.After(backoff):
+				backoff *= 2
+			case <-s.ctx.Done():
+				delivery.Error = s.ctx.Err().Error()
+				return s.recordDelivery(webhook.ID, delivery)
+			}
+		}
+
+		delivery.Attempts++
+		statusCode, retry, err := s.post(webhook, payload, body)
+		delivery.StatusCode = statusCode
+		if err == nil {
+			delivery.Success = true
+			delivery.Error = ""
+			break
+		}
+		delivery.Error = err.Error()
+		s.logger.Warn("failed to deliver webhook event", "id", webhook.ID, "event", payload.Event, "attempt", delivery.Attempts, "err", err.Error())
+		if !retry {
+			break
+		}
+	}
+
+	return s.recordDelivery(webhook.ID, delivery)
+}
+
+// post makes a single delivery attempt. It returns the response status, if there was one, and whether a
+// failed attempt is worth retrying.
+func (s *Service) post(webhook *record, payload Payload, body []byte) (int, bool, error) {
+	request, err := http.NewRequestWithContext(s.ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, fmt.Errorf("could not create request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderEvent, payload.Event)
+	request.Header.Set(HeaderDelivery, payload.DeliveryID)
+	request.Header.Set(HeaderSignature, Sign(webhook.Secret, body))
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return 0, true, fmt.Errorf("could not reach webhook: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return response.StatusCode, false, nil
+	}
+
+	// Other client errors mean the webhook will not accept the event however many times it is sent
+	retry := response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests
+	return response.StatusCode, retry, fmt.Errorf("webhook responded with status %d", response.StatusCode)
+}
+
+// recordDelivery adds a delivery to the log of a webhook, unless the webhook was deleted in the meantime
+func (s *Service) recordDelivery(id string, delivery Delivery) Delivery {
+	delivery.FinishedAt = time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhook, err := s.get(id)
+	if err != nil {
+		return delivery
+	}
+
+	webhook.Deliveries = append([]Delivery{delivery}, webhook.Deliveries[:min(len(webhook.Deliveries), maxDeliveries-1)]...)
+	if err := s.set(webhook); err != nil {
+		return delivery
+	}
+
+	s.logger.Info("delivered webhook event", "id", id, "event", delivery.Event, "success", delivery.Success, "attempts", delivery.Attempts)
+
+	return delivery
+}
+
+func (s *Service) get(id string) (*record, error) {
+	value, err := s.metadataStore.Get(kvdb.WebhooksBucket, id)
+	var notFoundErr *kvdb.NotFoundError
+	if errors.As(err, &notFoundErr) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+
+	var webhook record
+	if err := json.Unmarshal([]byte(value), &webhook); err != nil {
+		s.logger.Error("failed to unmarshal webhook", "id", id, "err", err.Error())
+		return nil, fmt.Errorf("failed to unmarshal webhook %s: %w", id, err)
+	}
+
+	return &webhook, nil
+}
+
+func (s *Service) getAll() ([]*record, error) {
+	ids, err := s.metadataStore.GetAllKeys(kvdb.WebhooksBucket)
+	if err != nil {
+		s.logger.Error("failed to get webhooks", "err", err.Error())
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+
+	records := make([]*record, 0, len(ids))
+	for _, id := range ids {
+		record, err := s.get(id)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	slices.SortFunc(records, func(a, b *record) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	return records, nil
+}
+
+func (s *Service) set(webhook *record) error {
+	data, err := json.Marshal(webhook)
+	if err != nil {
+		s.logger.Error("failed to marshal webhook", "id", webhook.ID, "err", err.Error())
+		return fmt.Errorf("failed to marshal webhook %s: %w", webhook.ID, err)
+	}
+
+	if err := s.metadataStore.Set(kvdb.WebhooksBucket, webhook.ID, string(data)); err != nil {
+		s.logger.Error("failed to save webhook", "id", webhook.ID, "err", err.Error())
+		return fmt.Errorf("failed to save webhook: %w", err)
+	}
+
+	return nil
+}
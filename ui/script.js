@@ -377,13 +377,36 @@ function createResultElement(result) {
     if (result.snippet && result.snippet.trim() !== '') {
         const snippetDiv = document.createElement('div');
         snippetDiv.className = 'result-snippet';
-        snippetDiv.textContent = result.snippet;
+        appendHighlightedText(snippetDiv, result.snippet, result.highlights || []);
         resultDiv.appendChild(snippetDiv);
     }
     
     return resultDiv;
 }
 
+// Append text to an element, marking the highlighted parts. Highlight offsets count characters, not UTF-16 code units.
+function appendHighlightedText(element, text, highlights) {
+    const characters = Array.from(text);
+    let position = 0;
+    
+    highlights.forEach(highlight => {
+        if (highlight.start < position || highlight.end > characters.length) {
+            return;
+        }
+        element.appendChild(document.createTextNode(characters.slice(position, highlight.start).join('')));
+        
+        const mark = document.createElement('mark');
+        mark.className = 'synonym-highlight';
+        mark.title = `Synonym: ${highlight.term}`;
+        mark.textContent = characters.slice(highlight.start, highlight.end).join('');
+        element.appendChild(mark);
+        
+        position = highlight.end;
+    });
+    
+    element.appendChild(document.createTextNode(characters.slice(position).join('')));
+}
+
 function copyFilePath(filePath, buttonElement) {
     navigator.clipboard.writeText(filePath).then(() => {
         // Save the original text and disable the button
@@ -53,6 +53,9 @@ func (v *Validator) Validate(i any) error {
 			case "min", "max":
 				return fmt.Errorf("value or length of field '%s' is not in the expected range", validationErrs[0].Field())
 
+			case "oneof":
+				return fmt.Errorf("value of field '%s' is not one of the allowed values", validationErrs[0].Field())
+
 			}
 		}
 		return err
@@ -62,9 +65,10 @@ func (v *Validator) Validate(i any) error {
 func (v *Validator) getTagValidationDetails() map[string]tagValidationDetails {
 	v.tagValidationDetailsOnce.Do(func() {
 		v.tagValidationDetailsMap = map[string]tagValidationDetails{
-			"valid_path":  {validatorFunc: v.isValidPath, err: errors.New("invalid path")},
-			"valid_query": {validatorFunc: v.isValidQuery, err: errors.New("invalid query")},
-			"valid_paths": {validatorFunc: v.areValidPaths, err: errors.New("invalid exclude path(s)")},
+			"valid_path":        {validatorFunc: v.isValidPath, err: errors.New("invalid path")},
+			"valid_path_format": {validatorFunc: v.isValidPathFormat, err: errors.New("invalid path")},
+			"valid_query":       {validatorFunc: v.isValidQuery, err: errors.New("invalid query")},
+			"valid_paths":       {validatorFunc: v.areValidPaths, err: errors.New("invalid exclude path(s)")},
 		}
 	})
 	return v.tagValidationDetailsMap
@@ -95,6 +99,12 @@ func (v *Validator) isValidPath(fl validator.FieldLevel) bool {
 	return v.isValidPathStr(fl.Field().String())
 }
 
+// isValidPathFormat checks a path the way isValidPath does, except that it need not exist, for paths that are
+// looked up in the index rather than on disk
+func (v *Validator) isValidPathFormat(fl validator.FieldLevel) bool {
+	return v.isValidPathFormatStr(fl.Field().String())
+}
+
 func (v *Validator) isValidQuery(fl validator.FieldLevel) bool {
 	query := fl.Field().String()
 	if len(query) == 0 {
@@ -136,6 +146,22 @@ func (v *Validator) areValidPaths(fl validator.FieldLevel) bool {
 }
 
 func (v *Validator) isValidPathStr(inputPath string) bool {
+	if len(inputPath) == 0 {
+		return true
+	}
+	if !v.isValidPathFormatStr(inputPath) {
+		return false
+	}
+
+	if _, err := os.Stat(inputPath); err != nil {
+		v.logger.Info("path does not exist", "path", inputPath)
+		return false
+	}
+
+	return true
+}
+
+func (v *Validator) isValidPathFormatStr(inputPath string) bool {
 	if len(inputPath) == 0 {
 		return true
 	}
@@ -154,10 +180,5 @@ func (v *Validator) isValidPathStr(inputPath string) bool {
 		return false
 	}
 
-	if _, err := os.Stat(inputPath); err != nil {
-		v.logger.Info("path does not exist", "path", inputPath)
-		return false
-	}
-
 	return true
 }